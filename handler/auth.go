@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"errors"
+	"etalert-backend/middlewares"
 	"etalert-backend/service"
+	"etalert-backend/validators"
 	"net/http"
 	"strings"
 
@@ -13,18 +16,38 @@ type authHandler struct {
 	authsrv service.AuthService
 }
 
+type loginRequest struct {
+	IdToken    string `json:"idToken" validate:"required"`
+	DeviceName string `json:"deviceName"`
+}
+
 func NewAuthHandler(authService service.AuthService) *authHandler {
 	return &authHandler{authsrv: authService}
 }
 
 func (h *authHandler) Login(c *fiber.Ctx) error {
-	var req service.LoginInput
+	var req loginRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
 	}
 
-	loginResponse, err := h.authsrv.Login(&req)
+	if err := validators.ValidateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	loginResponse, err := h.authsrv.Login(&service.LoginInput{
+		IdToken:    req.IdToken,
+		DeviceName: req.DeviceName,
+		UserAgent:  c.Get("User-Agent"),
+		IpAddress:  c.IP(),
+	})
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidIdToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid ID token"})
+		}
+		if err == service.ErrUserNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to login"})
 	}
 
@@ -47,12 +70,36 @@ func (h *authHandler) RefreshToken(c *fiber.Ctx) error {
 
     refreshResponse, err := h.authsrv.RefreshToken(refreshToken)
     if err != nil {
+        if err == service.ErrRefreshTokenReused {
+            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token reuse detected, please login again"})
+        }
         return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
     }
 
     return c.Status(http.StatusOK).JSON(refreshResponse)
 }
 
+func (h *authHandler) Logout(c *fiber.Ctx) error {
+    sessionId := middlewares.CurrentSessionId(c)
+    if sessionId == "" {
+        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired access token"})
+    }
+
+    if err := h.authsrv.Logout(sessionId); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to logout"})
+    }
+
+    return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Logged out successfully"})
+}
+
+func (h *authHandler) LogoutAll(c *fiber.Ctx) error {
+    if err := h.authsrv.LogoutAll(middlewares.CurrentGoogleId(c)); err != nil {
+        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to logout"})
+    }
+
+    return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Logged out from all devices successfully"})
+}
+
 func (h *authHandler) CheckUserSession(c *fiber.Ctx) error {
     // Extract the access token from the Authorization header
     accessToken := c.Get("Authorization")
@@ -88,5 +135,27 @@ func (h *authHandler) CheckUserSession(c *fiber.Ctx) error {
     return c.Status(http.StatusOK).JSON(fiber.Map{"message": "User session is valid", "userId": userId})
 }
 
+func (h *authHandler) GetSessions(c *fiber.Ctx) error {
+	sessions, err := h.authsrv.GetSessions(middlewares.CurrentGoogleId(c), middlewares.CurrentSessionId(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get sessions"})
+	}
+	if len(sessions) == 0 {
+		return c.JSON([]interface{}{})
+	}
+	return c.JSON(sessions)
+}
 
+func (h *authHandler) RevokeSession(c *fiber.Ctx) error {
+	id := c.Params("id")
 
+	err := h.authsrv.RevokeSession(middlewares.CurrentGoogleId(c), id)
+	if err != nil {
+		if err == service.ErrResourceNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Session not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke session"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Session revoked successfully"})
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"etalert-backend/service"
+	"etalert-backend/validators"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CalendarHandler struct {
+	calendarsrv service.CalendarService
+}
+
+type createDayOffRequest struct {
+	GoogleId string `json:"googleId" validate:"required"`
+	Date     string `json:"date" validate:"required"`
+	Name     string `json:"name"`
+}
+
+type createDayOffResponse struct {
+	Id      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func NewCalendarHandler(calendarService service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{calendarsrv: calendarService}
+}
+
+func (h *CalendarHandler) GetCalendar(c *fiber.Ctx) error {
+	googleId := c.Params("googleId")
+
+	year, err := strconv.Atoi(c.Params("year"))
+	if err != nil || year < 1 || year > 9999 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid year"})
+	}
+
+	calendar, err := h.calendarsrv.GetCalendar(googleId, year)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get calendar"})
+	}
+
+	return c.JSON(calendar)
+}
+
+func (h *CalendarHandler) CreateDayOff(c *fiber.Ctx) error {
+	var req createDayOffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+
+	if err := validators.ValidateStruct(req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	id, err := h.calendarsrv.AddDayOff(&service.DayOffInput{
+		GoogleId: req.GoogleId,
+		Date:     req.Date,
+		Name:     req.Name,
+	})
+	if err != nil {
+		if err == service.ErrInvalidDayOff {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid date"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to insert day off"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(createDayOffResponse{Id: id, Message: "Day off created successfully"})
+}
+
+func (h *CalendarHandler) DeleteDayOff(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	err := h.calendarsrv.DeleteDayOff(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete day off"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Day off deleted successfully"})
+}
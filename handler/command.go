@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"etalert-backend/service"
+	"etalert-backend/validators"
+	"etalert-backend/websocket"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Commands clients can send over the websocket.
+const (
+	CommandRoutineStart   = "routine.start"
+	CommandRoutineFinish  = "routine.finish"
+	CommandScheduleSnooze = "schedule.snooze"
+	CommandScheduleLeave  = "schedule.leave"
+	CommandScheduleArrive = "schedule.arrive"
+)
+
+// CommandHandler serves websocket commands the way the other handlers serve
+// REST routes: it decodes and validates the payload, checks ownership and
+// calls the service.
+type CommandHandler struct {
+	routineLogsrv service.RoutineLogService
+	schedulesrv   service.ScheduleService
+	ownershipsrv  service.OwnershipService
+
+	travelObservationsrv service.TravelObservationService
+}
+
+type routineStartCommand struct {
+	RoutineId string `json:"routineId" validate:"required"`
+	Date      string `json:"date" validate:"required"`
+	StartTime string `json:"startTime" validate:"required"`
+	EndTime   string `json:"endTime" validate:"required"`
+}
+
+type routineFinishCommand struct {
+	RoutineLogId  string `json:"routineLogId" validate:"required"`
+	ActualEndTime string `json:"actualEndTime" validate:"required"`
+}
+
+type scheduleSnoozeCommand struct {
+	ScheduleId string `json:"scheduleId" validate:"required"`
+	Minutes    int    `json:"minutes" validate:"required,min=1,max=240"`
+}
+
+type scheduleLeaveCommand struct {
+	ScheduleId string `json:"scheduleId" validate:"required"`
+}
+
+type scheduleArriveCommand struct {
+	ScheduleId string `json:"scheduleId" validate:"required"`
+	ArrivedAt  string `json:"arrivedAt" validate:"required"`
+}
+
+func NewCommandHandler(routineLogService service.RoutineLogService, scheduleService service.ScheduleService, ownershipService service.OwnershipService, travelObservationService service.TravelObservationService) *CommandHandler {
+	return &CommandHandler{routineLogsrv: routineLogService, schedulesrv: scheduleService, ownershipsrv: ownershipService, travelObservationsrv: travelObservationService}
+}
+
+func (h *CommandHandler) Register(hub *websocket.Hub) {
+	hub.HandleCommand(CommandRoutineStart, h.StartRoutine)
+	hub.HandleCommand(CommandRoutineFinish, h.FinishRoutine)
+	hub.HandleCommand(CommandScheduleSnooze, h.SnoozeSchedule)
+	hub.HandleCommand(CommandScheduleLeave, h.LeaveNow)
+	hub.HandleCommand(CommandScheduleArrive, h.ReportArrival)
+}
+
+func (h *CommandHandler) StartRoutine(googleId string, payload json.RawMessage) (interface{}, error) {
+	var req routineStartCommand
+	if err := decodeCommand(payload, &req); err != nil {
+		return nil, err
+	}
+	if err := h.requireOwner(googleId, req.RoutineId, h.ownershipsrv.GetRoutineOwner); err != nil {
+		return nil, err
+	}
+
+	id, err := h.routineLogsrv.StartRoutineLog(&service.RoutineLogInput{
+		RoutineId: req.RoutineId,
+		GoogleId:  googleId,
+		Date:      req.Date,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{"routineLogId": id}, nil
+}
+
+func (h *CommandHandler) FinishRoutine(googleId string, payload json.RawMessage) (interface{}, error) {
+	var req routineFinishCommand
+	if err := decodeCommand(payload, &req); err != nil {
+		return nil, err
+	}
+	if err := h.requireOwner(googleId, req.RoutineLogId, h.ownershipsrv.GetRoutineLogOwner); err != nil {
+		return nil, err
+	}
+
+	routineLog, err := h.routineLogsrv.FinishRoutineLog(req.RoutineLogId, req.ActualEndTime)
+	if err != nil {
+		return nil, commandError(err)
+	}
+	return fiber.Map{"routineLogId": routineLog.Id, "skewness": routineLog.Skewness}, nil
+}
+
+func (h *CommandHandler) SnoozeSchedule(googleId string, payload json.RawMessage) (interface{}, error) {
+	var req scheduleSnoozeCommand
+	if err := decodeCommand(payload, &req); err != nil {
+		return nil, err
+	}
+	if err := h.requireOwner(googleId, req.ScheduleId, h.ownershipsrv.GetScheduleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := h.schedulesrv.SnoozeSchedule(req.ScheduleId, req.Minutes); err != nil {
+		return nil, commandError(err)
+	}
+	return nil, nil
+}
+
+func (h *CommandHandler) LeaveNow(googleId string, payload json.RawMessage) (interface{}, error) {
+	var req scheduleLeaveCommand
+	if err := decodeCommand(payload, &req); err != nil {
+		return nil, err
+	}
+	if err := h.requireOwner(googleId, req.ScheduleId, h.ownershipsrv.GetScheduleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := h.schedulesrv.LeaveNow(req.ScheduleId); err != nil {
+		return nil, commandError(err)
+	}
+	return nil, nil
+}
+
+func (h *CommandHandler) ReportArrival(googleId string, payload json.RawMessage) (interface{}, error) {
+	var req scheduleArriveCommand
+	if err := decodeCommand(payload, &req); err != nil {
+		return nil, err
+	}
+	if err := h.requireOwner(googleId, req.ScheduleId, h.ownershipsrv.GetScheduleOwner); err != nil {
+		return nil, err
+	}
+
+	observation, err := h.travelObservationsrv.ReportArrival(req.ScheduleId, req.ArrivedAt)
+	if err != nil {
+		return nil, commandError(err)
+	}
+	return observation, nil
+}
+
+func decodeCommand(payload json.RawMessage, req interface{}) error {
+	if err := json.Unmarshal(payload, req); err != nil {
+		return websocket.NewCommandError(websocket.CodeInvalidPayload, "Cannot parse payload")
+	}
+	if err := validators.ValidateStruct(req); err != nil {
+		return websocket.NewCommandError(websocket.CodeInvalidPayload, err.Error())
+	}
+	return nil
+}
+
+// requireOwner is the command counterpart of middlewares.RequireOwner.
+func (h *CommandHandler) requireOwner(googleId string, id string, getOwner func(string) (string, error)) error {
+	owner, err := getOwner(id)
+	if err != nil {
+		return commandError(err)
+	}
+	if owner != googleId {
+		return websocket.NewCommandError(websocket.CodeForbidden, "Access denied")
+	}
+	return nil
+}
+
+func commandError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrResourceNotFound):
+		return websocket.NewCommandError(websocket.CodeNotFound, "Resource not found")
+	case errors.Is(err, service.ErrRoutineLogFinished):
+		return websocket.NewCommandError(websocket.CodeConflict, "Routine already finished")
+	case errors.Is(err, service.ErrInvalidArrival):
+		return websocket.NewCommandError(websocket.CodeInvalidPayload, "Invalid arrival time")
+	case errors.Is(err, service.ErrArrivalReported):
+		return websocket.NewCommandError(websocket.CodeConflict, "Arrival already reported")
+	}
+	return err
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"etalert-backend/service"
+	"etalert-backend/validators"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ICalendarHandler struct {
+	icalendarsrv service.ICalendarService
+}
+
+type importSchedulesRequest struct {
+	GoogleId string `json:"googleId" validate:"required"`
+	// The .ics file's content
+	Calendar string `json:"calendar" validate:"required"`
+	// Convert the events and report on them without creating schedules
+	DryRun bool `json:"dryRun"`
+	// Events get the tag they were exported with, the one tags gives for
+	// one of their categories or their summary, the tag named like one of
+	// their categories, or else tagId. Routines are added before them
+	TagId string            `json:"tagId"`
+	Tags  map[string]string `json:"tags"`
+	// Where events with a location travel from unless they say otherwise
+	OriName        string  `json:"oriName"`
+	OriLatitude    float64 `json:"oriLatitude"`
+	OriLongitude   float64 `json:"oriLongitude"`
+	Transportation string  `json:"transportation"`
+}
+
+type createFeedResponse struct {
+	Url       string    `json:"url"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func NewICalendarHandler(icalendarService service.ICalendarService) *ICalendarHandler {
+	return &ICalendarHandler{icalendarsrv: icalendarService}
+}
+
+func (h *ICalendarHandler) ExportSchedules(c *fiber.Ctx) error {
+	googleId := c.Params("googleId")
+
+	calendar, err := h.icalendarsrv.ExportSchedules(googleId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export schedules"})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="etalert.ics"`)
+	return c.SendString(calendar)
+}
+
+func (h *ICalendarHandler) ImportSchedules(c *fiber.Ctx) error {
+	var req importSchedulesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+
+	if err := validators.ValidateStruct(req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	response, err := h.icalendarsrv.ImportSchedules(&service.ICalendarImportInput{
+		GoogleId:       req.GoogleId,
+		Calendar:       req.Calendar,
+		DryRun:         req.DryRun,
+		TagId:          req.TagId,
+		Tags:           req.Tags,
+		OriName:        req.OriName,
+		OriLatitude:    req.OriLatitude,
+		OriLongitude:   req.OriLongitude,
+		Transportation: req.Transportation,
+	})
+	if err != nil {
+		switch err {
+		case service.ErrInvalidCalendar:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid calendar"})
+		case service.ErrTooManyEvents:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Calendar has too many events"})
+		case service.ErrUnknownTag:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tag"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to import schedules"})
+	}
+
+	if req.DryRun {
+		return c.JSON(response)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+func (h *ICalendarHandler) CreateFeed(c *fiber.Ctx) error {
+	googleId := c.Params("googleId")
+
+	feed, err := h.icalendarsrv.CreateFeed(googleId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create feed"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(createFeedResponse{
+		Url:       c.BaseURL() + "/feeds/" + feed.Token + ".ics",
+		CreatedAt: feed.CreatedAt,
+	})
+}
+
+func (h *ICalendarHandler) GetFeedInfo(c *fiber.Ctx) error {
+	googleId := c.Params("googleId")
+
+	feed, err := h.icalendarsrv.GetFeedInfo(googleId)
+	if err != nil {
+		if err == service.ErrResourceNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No feed"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get feed"})
+	}
+
+	return c.JSON(feed)
+}
+
+func (h *ICalendarHandler) RevokeFeed(c *fiber.Ctx) error {
+	googleId := c.Params("googleId")
+
+	err := h.icalendarsrv.RevokeFeed(googleId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to revoke feed"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Feed revoked successfully"})
+}
+
+// GetFeed serves a feed to calendar apps, which authenticate with the token
+// in its URL alone. Clients that send the ETag they have get a 304 while
+// nothing changed.
+func (h *ICalendarHandler) GetFeed(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	calendar, etag, err := h.icalendarsrv.GetFeed(token)
+	if err != nil {
+		if err == service.ErrResourceNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Feed not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get feed"})
+	}
+
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, "private, no-cache")
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	return c.SendString(calendar)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, compared
+// weakly as RFC 9110 asks.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
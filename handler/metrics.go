@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"etalert-backend/repository"
+	"etalert-backend/websocket"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MetricsHandler struct {
+	hub        *websocket.Hub
+	cacheStats *repository.CacheStats
+}
+
+func NewMetricsHandler(hub *websocket.Hub, cacheStats *repository.CacheStats) *MetricsHandler {
+	return &MetricsHandler{hub: hub, cacheStats: cacheStats}
+}
+
+func (h *MetricsHandler) GetMetrics(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"websocket": h.hub.Stats(),
+		"cache":     h.cacheStats.Snapshot(),
+	})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"etalert-backend/service"
 	"etalert-backend/validators"
 	"fmt"
@@ -31,8 +32,18 @@ type createScheduleRequest struct {
 	IsHaveLocation  bool    `json:"isHaveLocation"`
 	IsFirstSchedule bool    `json:"isFirstSchedule"`
 	TagId           string  `json:"tagId"`
-	Recurrence      string  `json:"recurrence"`
-	RecurrenceId    int     `json:"recurrenceId"`
+	// "none", one of daily, weekly, monthly and yearly, or an RRULE such as
+	// "FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2"
+	Recurrence   string `json:"recurrence"`
+	RecurrenceId int    `json:"recurrenceId"`
+	// Leave out occurrences on public holidays and days off, and with
+	// workingDaysOnly on weekends as well
+	SkipHolidays    bool `json:"skipHolidays"`
+	WorkingDaysOnly bool `json:"workingDaysOnly"`
+}
+
+type addRecurrenceDateRequest struct {
+	Date string `json:"date" validate:"required"`
 }
 
 type updateScheduleRequest struct {
@@ -80,11 +91,19 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 		IsFirstSchedule: req.IsFirstSchedule,
 		TagId:           req.TagId,
 		Recurrence:      req.Recurrence,
+		SkipHolidays:    req.SkipHolidays,
+		WorkingDaysOnly: req.WorkingDaysOnly,
 	}
 
 	if schedule.Recurrence != "none" {
 		str, err := h.schedulesrv.InsertRecurrenceSchedule(schedule)
 		if err != nil {
+			if errors.Is(err, service.ErrInvalidRecurrence) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+			}
+			if err == service.ErrUnknownTag {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tag"})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to insert schedule"})
 		}
 		if str != "" {
@@ -96,6 +115,9 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 
 	str, err := h.schedulesrv.InsertSchedule(schedule)
 	if err != nil {
+		if err == service.ErrUnknownTag {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown tag"})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to insert schedule"})
 	}
 
@@ -208,8 +230,14 @@ func (h *ScheduleHandler) UpdateScheduleByRecurrenceId(c *fiber.Ctx) error {
 		IsHaveEndTime: req.IsHaveEndTime,
 	}
 
-	err := h.schedulesrv.UpdateScheduleByRecurrenceId(recurrenceId, schedule, date)
+	err := h.schedulesrv.UpdateScheduleByRecurrenceId(recurrenceId, schedule, date, c.Query("mode"))
 	if err != nil {
+		switch err {
+		case service.ErrResourceNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Occurrence not found"})
+		case service.ErrInvalidRecurrenceMode, service.ErrInvalidOccurrence:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		fmt.Println(err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update schedule"})
 	}
@@ -232,10 +260,41 @@ func (h *ScheduleHandler) DeleteScheduleByRecurrenceId(c *fiber.Ctx) error {
 	recurrenceId := c.Params("recurrenceId")
 	date := c.Params("date", "")
 
-	err := h.schedulesrv.DeleteScheduleByRecurrenceId(recurrenceId, date)
+	err := h.schedulesrv.DeleteScheduleByRecurrenceId(recurrenceId, date, c.Query("mode"))
 	if err != nil {
+		switch err {
+		case service.ErrResourceNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Occurrence not found"})
+		case service.ErrInvalidRecurrenceMode, service.ErrInvalidOccurrence:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete schedule"})
 	}
 
 	return c.JSON(createScheduleResponse{Message: "Schedule deleted successfully"})
 }
+
+func (h *ScheduleHandler) AddRecurrenceDate(c *fiber.Ctx) error {
+	recurrenceId := c.Params("recurrenceId")
+	var req addRecurrenceDateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+
+	if err := validators.ValidateStruct(req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err := h.schedulesrv.AddRecurrenceDate(recurrenceId, req.Date)
+	if err != nil {
+		switch err {
+		case service.ErrResourceNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Recurrence series not found"})
+		case service.ErrInvalidOccurrence:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add occurrence"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(createScheduleResponse{Message: "Occurrence added successfully"})
+}
@@ -38,6 +38,9 @@ func (h *TagHandler) CreateTag(c *fiber.Ctx) error {
 
 	err := h.tagsrv.InsertTag(tag)
 	if err != nil {
+		if err == service.ErrUnknownRoutine {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown routine"})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to insert tag"})
 	}
 
@@ -89,6 +92,9 @@ func (h *TagHandler) UpdateTag(c *fiber.Ctx) error {
 
 	err := h.tagsrv.UpdateTag(id, tag)
 	if err != nil {
+		if err == service.ErrUnknownRoutine {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Unknown routine"})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update tag"})
 	}
 
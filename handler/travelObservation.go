@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"etalert-backend/service"
+	"etalert-backend/validators"
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultAccuracyDays = 28
+	maxAccuracyDays     = 365
+)
+
+type TravelObservationHandler struct {
+	travelObservationsrv service.TravelObservationService
+}
+
+type reportArrivalRequest struct {
+	ArrivedAt string `json:"arrivedAt" validate:"required"`
+}
+
+func NewTravelObservationHandler(travelObservationService service.TravelObservationService) *TravelObservationHandler {
+	return &TravelObservationHandler{travelObservationsrv: travelObservationService}
+}
+
+func (h *TravelObservationHandler) ReportArrival(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req reportArrivalRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+	}
+
+	if err := validators.ValidateStruct(req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	observation, err := h.travelObservationsrv.ReportArrival(id, req.ArrivedAt)
+	if err != nil {
+		switch err {
+		case service.ErrResourceNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "No travel prediction for this schedule"})
+		case service.ErrInvalidArrival:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid arrival time"})
+		case service.ErrArrivalReported:
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Arrival already reported"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to report arrival"})
+	}
+
+	return c.JSON(observation)
+}
+
+func (h *TravelObservationHandler) GetTravelAccuracy(c *fiber.Ctx) error {
+	googleId := c.Params("googleId")
+
+	days := defaultAccuracyDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxAccuracyDays {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid days"})
+		}
+		days = parsed
+	}
+
+	accuracy, err := h.travelObservationsrv.GetTravelAccuracy(googleId, days)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to get travel accuracy"})
+	}
+
+	return c.JSON(accuracy)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"etalert-backend/service"
 	"etalert-backend/validators"
 	"github.com/gofiber/fiber/v2"
@@ -9,18 +10,20 @@ import (
 
 type userHandler struct {
 	usersrv service.UserService
+	authsrv service.AuthService
 }
 
 type createUserRequest struct {
-	Name     string `json:"name" validate:"required"`
-	Image    string `json:"image" validate:"required"`
-	Email    string `json:"email" validate:"required"`
-	GoogleId string `json:"googleId" validate:"required"`
+	IdToken  string `json:"idToken" validate:"required"`
+	TimeZone string `json:"timeZone"`
+	Country  string `json:"country"`
 }
 
 type updateUserRequest struct {
-	Name  string `json:"name" validate:"required"`
-	Image string `json:"image" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Image    string `json:"image" validate:"required"`
+	TimeZone string `json:"timeZone"`
+	Country  string `json:"country"`
 }
 
 type createUserResponse struct {
@@ -28,8 +31,8 @@ type createUserResponse struct {
 	IsExist bool   `json:"isExist"`
 }
 
-func NewUserHandler(userService service.UserService) *userHandler {
-	return &userHandler{usersrv: userService}
+func NewUserHandler(userService service.UserService, authService service.AuthService) *userHandler {
+	return &userHandler{usersrv: userService, authsrv: authService}
 }
 
 func (h *userHandler) CreateUser(c *fiber.Ctx) error {
@@ -42,11 +45,21 @@ func (h *userHandler) CreateUser(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	identity, err := h.authsrv.VerifyIdToken(req.IdToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidIdToken) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid ID token"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify ID token"})
+	}
+
 	user := &service.UserInput{
-		Name:     req.Name,
-		Image:    req.Image,
-		Email:    req.Email,
-		GoogleId: req.GoogleId,
+		Name:     identity.Name,
+		Image:    identity.Image,
+		Email:    identity.Email,
+		GoogleId: identity.GoogleId,
+		TimeZone: req.TimeZone,
+		Country:  req.Country,
 	}
 
 	insertResponse, err := h.usersrv.InsertUser(user)
@@ -57,6 +70,12 @@ func (h *userHandler) CreateUser(c *fiber.Ctx) error {
 				IsExist: true,
 			})
 		}
+		if err == service.ErrInvalidTimeZone {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid time zone"})
+		}
+		if err == service.ErrInvalidCountry {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid country"})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to insert user"})
 	}
 
@@ -92,12 +111,20 @@ func (h *userHandler) UpdateUser(c *fiber.Ctx) error {
 	}
 
 	user := &service.UserUpdater{
-		Name:  req.Name,
-		Image: req.Image,
+		Name:     req.Name,
+		Image:    req.Image,
+		TimeZone: req.TimeZone,
+		Country:  req.Country,
 	}
 
 	err := h.usersrv.UpdateUser(googleId, user)
 	if err != nil {
+		if err == service.ErrInvalidTimeZone {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid time zone"})
+		}
+		if err == service.ErrInvalidCountry {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid country"})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update user"})
 	}
 
@@ -0,0 +1,135 @@
+package keyring
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is one entry of the ring. SignKey is nil for keys that are only kept
+// to verify tokens issued before a rotation.
+type Key struct {
+	Id        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+	NotAfter  time.Time
+}
+
+func (k *Key) isExpired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && now.After(k.NotAfter)
+}
+
+// KeyRing signs tokens with its current key and verifies them with whichever
+// key their kid header names.
+type KeyRing struct {
+	mu        sync.RWMutex
+	keys      map[string]*Key
+	current   string
+	legacyKid string
+}
+
+func New() *KeyRing {
+	return &KeyRing{keys: make(map[string]*Key)}
+}
+
+func (r *KeyRing) Add(key *Key) error {
+	if key.Id == "" {
+		return fmt.Errorf("key id is required")
+	}
+	if key.Method == nil || key.VerifyKey == nil {
+		return fmt.Errorf("key %s has no signing method or verification key", key.Id)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[key.Id]; ok {
+		return fmt.Errorf("duplicate key id: %s", key.Id)
+	}
+	r.keys[key.Id] = key
+	return nil
+}
+
+// SetCurrent selects the key new tokens are signed with.
+func (r *KeyRing) SetCurrent(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[kid]
+	if !ok {
+		return fmt.Errorf("unknown key id: %s", kid)
+	}
+	if key.SignKey == nil {
+		return fmt.Errorf("key %s cannot sign", kid)
+	}
+	r.current = kid
+	return nil
+}
+
+// SetLegacy names the key used for tokens issued before kid headers existed.
+func (r *KeyRing) SetLegacy(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[kid]; !ok {
+		return fmt.Errorf("unknown key id: %s", kid)
+	}
+	r.legacyKid = kid
+	return nil
+}
+
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	key, ok := r.keys[r.current]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("no current signing key")
+	}
+	if key.isExpired(time.Now()) {
+		return "", fmt.Errorf("current signing key %s has expired", key.Id)
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Id
+	return token.SignedString(key.SignKey)
+}
+
+// Keyfunc resolves the verification key for a token and can be passed
+// straight to jwt.Parse.
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	r.mu.RLock()
+	if kid == "" {
+		kid = r.legacyKid
+	}
+	key, ok := r.keys[kid]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	if key.isExpired(time.Now()) {
+		return nil, fmt.Errorf("key %s has expired", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %s does not accept %s", kid, token.Method.Alg())
+	}
+	return key.VerifyKey, nil
+}
+
+// Methods lists the algorithms of every key in the ring, for jwt.WithValidMethods.
+func (r *KeyRing) Methods() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range r.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,202 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"googleId": "1234567890",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func hmacKey(kid string, secret string) *Key {
+	return &Key{Id: kid, Method: jwt.SigningMethodHS256, SignKey: []byte(secret), VerifyKey: []byte(secret)}
+}
+
+func verify(ring *KeyRing, token string) error {
+	_, err := jwt.Parse(token, ring.Keyfunc, jwt.WithValidMethods(ring.Methods()))
+	return err
+}
+
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key interface{}) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOldKeyVerifiesAfterRotation(t *testing.T) {
+	ring := New()
+	if err := ring.Add(hmacKey("old", "old-secret")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Add(hmacKey("new", "new-secret")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.SetCurrent("old"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := ring.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ring.SetCurrent("new"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := ring.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verify(ring, before); err != nil {
+		t.Errorf("token signed with the old key rejected: %v", err)
+	}
+	if err := verify(ring, after); err != nil {
+		t.Errorf("token signed with the new key rejected: %v", err)
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(after, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := token.Header["kid"]; kid != "new" {
+		t.Errorf("signed with kid %v, want new", kid)
+	}
+}
+
+func TestKeyfuncRejects(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring := New()
+	if err := ring.Add(hmacKey("hs", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Add(&Key{Id: "ed", Method: jwt.SigningMethodEdDSA, SignKey: edKey, VerifyKey: edKey.Public()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Add(&Key{
+		Id:        "expired",
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   []byte("expired-secret"),
+		VerifyKey: []byte("expired-secret"),
+		NotAfter:  time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.SetCurrent("hs"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", signWith(t, jwt.SigningMethodHS256, "missing", []byte("secret"))},
+		{"kid and alg mismatch", signWith(t, jwt.SigningMethodHS256, "ed", []byte("secret"))},
+		{"wrong key for kid", signWith(t, jwt.SigningMethodHS256, "hs", []byte("other-secret"))},
+		{"expired key", signWith(t, jwt.SigningMethodHS256, "expired", []byte("expired-secret"))},
+		{"no kid without legacy key", signWith(t, jwt.SigningMethodHS256, "", []byte("secret"))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := verify(ring, test.token); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+}
+
+func TestLoadFromEnvKeysFile(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "ed.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := json.Marshal(Config{
+		Current: "ed-1",
+		Keys: []KeyConfig{
+			{Id: "ed-1", Algorithm: "EdDSA", PrivateKeyFile: keyFile},
+			{Id: "hs-old", Algorithm: "HS256", SecretEnv: "TEST_OLD_JWT_SECRET"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(configFile, config, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("JWT_KEYS_FILE", configFile)
+	t.Setenv("JWT_SECRET", "legacy-secret")
+	t.Setenv("TEST_OLD_JWT_SECRET", "old-secret")
+
+	ring, err := LoadFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load key ring: %v", err)
+	}
+
+	signed, err := ring.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Parse(signed, ring.Keyfunc, jwt.WithValidMethods(ring.Methods()))
+	if err != nil {
+		t.Fatalf("token signed with the current key rejected: %v", err)
+	}
+	if token.Method.Alg() != "EdDSA" || token.Header["kid"] != "ed-1" {
+		t.Errorf("signed with %s kid %v, want EdDSA kid ed-1", token.Method.Alg(), token.Header["kid"])
+	}
+
+	if err := verify(ring, signWith(t, jwt.SigningMethodHS256, "hs-old", []byte("old-secret"))); err != nil {
+		t.Errorf("token signed with the secretEnv key rejected: %v", err)
+	}
+	if err := verify(ring, signWith(t, jwt.SigningMethodHS256, "", []byte("legacy-secret"))); err != nil {
+		t.Errorf("token without kid rejected with JWT_SECRET set: %v", err)
+	}
+}
+
+func TestLoadFromEnvRejectsBadKeysFile(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(configFile, []byte(`{"current":"missing","keys":[{"kid":"hs","alg":"HS256","secret":"secret"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_KEYS_FILE", configFile)
+	t.Setenv("JWT_SECRET", "")
+
+	if _, err := LoadFromEnv(); err == nil {
+		t.Error("key file with an unknown current key loaded")
+	}
+}
@@ -0,0 +1,184 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const legacyKeyId = "default"
+
+// KeyConfig describes one key in the JWT_KEYS_FILE document. HMAC keys take
+// their secret from Secret or from the environment variable named by
+// SecretEnv. RS256 and EdDSA keys are read from PEM files; a key with only a
+// public key file can verify but never sign.
+type KeyConfig struct {
+	Id             string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret"`
+	SecretEnv      string `json:"secretEnv"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	PublicKeyFile  string `json:"publicKeyFile"`
+	NotAfter       string `json:"notAfter"`
+}
+
+type Config struct {
+	Current string      `json:"current"`
+	Keys    []KeyConfig `json:"keys"`
+}
+
+// LoadFromEnv builds the ring from JWT_KEYS_FILE when set. Otherwise it falls
+// back to a single HS256 key from JWT_SECRET. In both cases JWT_SECRET, if
+// present, still verifies tokens issued without a kid header.
+func LoadFromEnv() (*KeyRing, error) {
+	secret := os.Getenv("JWT_SECRET")
+	path := os.Getenv("JWT_KEYS_FILE")
+
+	if path == "" {
+		if secret == "" {
+			return nil, fmt.Errorf("JWT_SECRET or JWT_KEYS_FILE must be set")
+		}
+		return Load(&Config{
+			Current: legacyKeyId,
+			Keys:    []KeyConfig{{Id: legacyKeyId, Algorithm: "HS256", Secret: secret}},
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %v", err)
+	}
+
+	if secret != "" && !config.hasKey(legacyKeyId) {
+		config.Keys = append(config.Keys, KeyConfig{Id: legacyKeyId, Algorithm: "HS256", Secret: secret})
+	}
+	return Load(&config)
+}
+
+func (c *Config) hasKey(kid string) bool {
+	for _, key := range c.Keys {
+		if key.Id == kid {
+			return true
+		}
+	}
+	return false
+}
+
+func Load(config *Config) (*KeyRing, error) {
+	ring := New()
+	for _, keyConfig := range config.Keys {
+		key, err := loadKey(keyConfig)
+		if err != nil {
+			return nil, err
+		}
+		if err := ring.Add(key); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ring.SetCurrent(config.Current); err != nil {
+		return nil, err
+	}
+	if config.hasKey(legacyKeyId) {
+		if err := ring.SetLegacy(legacyKeyId); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+func loadKey(config KeyConfig) (*Key, error) {
+	key := &Key{Id: config.Id}
+
+	if config.NotAfter != "" {
+		notAfter, err := time.Parse(time.RFC3339, config.NotAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notAfter for key %s: %v", config.Id, err)
+		}
+		key.NotAfter = notAfter
+	}
+
+	switch config.Algorithm {
+	case "HS256", "HS384", "HS512":
+		secret := config.Secret
+		if config.SecretEnv != "" {
+			secret = os.Getenv(config.SecretEnv)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("key %s has no secret", config.Id)
+		}
+		key.Method = jwt.GetSigningMethod(config.Algorithm)
+		key.SignKey = []byte(secret)
+		key.VerifyKey = []byte(secret)
+
+	case "RS256":
+		key.Method = jwt.SigningMethodRS256
+		if config.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(config.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key for %s: %v", config.Id, err)
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key for %s: %v", config.Id, err)
+			}
+			key.SignKey = privateKey
+			key.VerifyKey = &privateKey.PublicKey
+		} else if config.PublicKeyFile != "" {
+			pem, err := os.ReadFile(config.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key for %s: %v", config.Id, err)
+			}
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key for %s: %v", config.Id, err)
+			}
+			key.VerifyKey = publicKey
+		}
+
+	case "EdDSA":
+		key.Method = jwt.SigningMethodEdDSA
+		if config.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(config.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key for %s: %v", config.Id, err)
+			}
+			privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key for %s: %v", config.Id, err)
+			}
+			signer, ok := privateKey.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("key %s is not an Ed25519 key", config.Id)
+			}
+			key.SignKey = signer
+			key.VerifyKey = signer.Public()
+		} else if config.PublicKeyFile != "" {
+			pem, err := os.ReadFile(config.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key for %s: %v", config.Id, err)
+			}
+			publicKey, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key for %s: %v", config.Id, err)
+			}
+			key.VerifyKey = publicKey
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q for key %s", config.Algorithm, config.Id)
+	}
+
+	if key.VerifyKey == nil {
+		return nil, fmt.Errorf("key %s has no key file", config.Id)
+	}
+	return key, nil
+}
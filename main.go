@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"etalert-backend/handler"
+	"etalert-backend/keyring"
 	"etalert-backend/middlewares"
 	"etalert-backend/repository"
 	"etalert-backend/service"
@@ -10,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	_ "time/tzdata"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -37,16 +39,51 @@ func main() {
 	fmt.Println("Connected to MongoDB!")
 
 	userRepository := repository.NewUserRepositoryDB(client, "etalert", "user")
-	userService := service.NewUserService(userRepository)
-	userHandler := handler.NewUserHandler(userService)
+	scheduleRepository := repository.NewScheduleRepositoryDB(client, "etalert", "schedule")
+	scheduleLogRepository := repository.NewScheduleLogRepositoryDB(client, "etalert", "scheduleLog")
+	userService := service.NewUserService(userRepository, scheduleRepository, scheduleLogRepository)
+
+	googleKeySource := repository.NewJWKSKeySource(repository.GoogleCertsURL)
+	if path := os.Getenv("G_JWKS_FILE"); path != "" {
+		jwks, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		googleKeySource, err = repository.NewStaticKeySource(jwks)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sessionRepository := repository.NewSessionRepositoryDB(client, "etalert", "session")
+	keyRing, err := keyring.LoadFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	authService := service.NewAuthService(userRepository)
+	authService := service.NewAuthService(userRepository, sessionRepository, keyRing, googleKeySource, os.Getenv("G_CLIENT_ID"))
 	authHandler := handler.NewAuthHandler(authService)
+	userHandler := handler.NewUserHandler(userService, authService)
 
 	bedtimeRepository := repository.NewBedtimeRepositoryDB(client, "etalert", "bedtime")
 	bedtimeService := service.NewBedtimeService(bedtimeRepository)
 	bedtimeHandler := handler.NewBedtimeHandler(bedtimeService)
 
+	eventLogRepository := repository.NewEventLogRepositoryDB(client, "etalert", "event")
+	backplane := etalert_websocket.NewLocalBackplane()
+	if os.Getenv("WS_BACKPLANE") == "mongo" {
+		backplane, err = etalert_websocket.NewMongoBackplane(client, "etalert", "websocketBackplane")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	wsConfig, err := etalert_websocket.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	hub := etalert_websocket.NewHub(eventLogRepository, sessionRepository, backplane, wsConfig)
+	go hub.Run()
+
 	routineLogRepository := repository.NewRoutineLogRepositoryDB(client, "etalert", "routineLog")
 	routineLogService := service.NewRoutineLogService(routineLogRepository)
 	routineLogHandler := handler.NewRoutineLogHandler(routineLogService)
@@ -54,7 +91,7 @@ func main() {
 	routineRepository := repository.NewRoutineRepositoryDB(client, "etalert", "routine")
 	tagRepository := repository.NewTagRepositoryDB(client, "etalert", "tag")
 	
-	routineService := service.NewRoutineService(routineRepository, tagRepository)
+	routineService := service.NewRoutineService(routineRepository, tagRepository, hub)
 	routineHandler := handler.NewRoutineHandler(routineService)
 
 	tagService := service.NewTagService(tagRepository, routineRepository)
@@ -65,19 +102,75 @@ func main() {
 	weeklyReportListHandler := handler.NewWeeklyReportListHandler(weeklyReportListService)
 
 	weeklyReportRepository := repository.NewWeeklyReportRepositoryDB(client, "etalert", "weeklyReport")
-	weeklyReportService := service.NewWeeklyReportService(weeklyReportRepository, userRepository, routineRepository, weeklyReportListRepository, routineLogRepository, tagRepository)
+	weeklyReportService := service.NewWeeklyReportService(weeklyReportRepository, userRepository, routineRepository, weeklyReportListRepository, routineLogRepository, tagRepository, hub)
 	weeklyReportHandler := handler.NewWeeklyReportHandler(weeklyReportService)
 
-	scheduleLogRepository := repository.NewScheduleLogRepositoryDB(client, "etalert", "scheduleLog")
+	travelTimeProvider, err := repository.NewTravelTimeProvider(os.Getenv("TRAVEL_TIME_PROVIDER"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	scheduleRepository := repository.NewScheduleRepositoryDB(client, "etalert", "schedule")
-	scheduleService := service.NewScheduleService(scheduleRepository, scheduleLogRepository, routineRepository, bedtimeRepository, tagRepository)
+	cacheTTL, err := repository.CacheTTLFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cache := repository.NewLRUCache(10000)
+	if os.Getenv("CACHE_SHARED") == "mongo" {
+		cache = repository.NewLayeredCache(cache, repository.NewCacheRepositoryDB(client, "etalert", "cache"))
+	}
+	cacheStats := repository.NewCacheStats()
+	travelTimeProvider = repository.NewCachedTravelTimeProvider(travelTimeProvider, cache, cacheTTL.TravelTime, cacheStats)
+	cachedScheduleRepository := repository.NewCachedScheduleRepository(scheduleRepository, cache, cacheTTL, cacheStats)
+
+	travelAdjuster := service.NewRuleTravelAdjuster()
+	if apiKey := os.Getenv("GEMINI_API_KEY"); apiKey != "" {
+		geminiAdjuster, err := service.NewGeminiTravelAdjuster(context.Background(), apiKey, travelAdjuster)
+		if err != nil {
+			log.Printf("Falling back to rule-based travel adjustment: %v", err)
+		} else {
+			travelAdjuster = geminiAdjuster
+		}
+	}
+
+	travelObservationRepository := repository.NewTravelObservationRepositoryDB(client, "etalert", "travelObservation")
+	travelObservationService := service.NewTravelObservationService(travelObservationRepository, scheduleRepository, userRepository)
+	travelObservationHandler := handler.NewTravelObservationHandler(travelObservationService)
+
+	recurrenceSeriesRepository := repository.NewRecurrenceSeriesRepositoryDB(client, "etalert", "recurrenceSeries")
+	horizonWeeks, err := service.RecurrenceHorizonFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	holidayCalendar, err := repository.NewBundledHolidayCalendar()
+	if err != nil {
+		log.Fatal(err)
+	}
+	dayOffRepository := repository.NewDayOffRepositoryDB(client, "etalert", "dayOff")
+	calendarService := service.NewCalendarService(holidayCalendar, dayOffRepository, userRepository)
+	calendarHandler := handler.NewCalendarHandler(calendarService)
+
+	scheduleService := service.NewScheduleService(cachedScheduleRepository, scheduleLogRepository, routineRepository, bedtimeRepository, tagRepository, userRepository, travelTimeProvider, travelAdjuster, travelObservationRepository, recurrenceSeriesRepository, horizonWeeks, calendarService, hub)
 	scheduleHandler := handler.NewScheduleHandler(scheduleService)
 
+	feedTokenRepository := repository.NewFeedTokenRepositoryDB(client, "etalert", "feedToken")
+	icalendarService := service.NewICalendarService(scheduleRepository, recurrenceSeriesRepository, userRepository, tagRepository, feedTokenRepository, scheduleService, calendarService)
+	icalendarHandler := handler.NewICalendarHandler(icalendarService)
+
 	feedbackRepository := repository.NewFeedbackRepositoryDB(client, "etalert", "feedback")
 	feedbackService := service.NewFeedbackService(feedbackRepository)
 	feedbackHandler := handler.NewFeedbackHandler(feedbackService)
 
+	ownershipService := service.NewOwnershipService(scheduleRepository, routineRepository, routineLogRepository, tagRepository, dayOffRepository)
+
+	commandHandler := handler.NewCommandHandler(routineLogService, scheduleService, ownershipService, travelObservationService)
+	commandHandler.Register(hub)
+
+	go func() {
+		if err := scheduleService.MigrateScheduleInstants(); err != nil {
+			log.Printf("Failed to migrate schedules: %v", err)
+		}
+	}()
 	scheduleService.StartCronJob()
 	weeklyReportService.StartCronJob()
 
@@ -85,63 +178,104 @@ func main() {
 	server := fiber.New()
 	server.Use(recover.New())
 
-	server.Get("/ws", websocket.New(func(c *websocket.Conn) {
-		etalert_websocket.HandleConnections(c)
-	}))
+	validateSession := middlewares.ValidateSession(authService)
+
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		metricsHandler := handler.NewMetricsHandler(hub, cacheStats)
+		server.Get("/metrics", middlewares.RequireMetricsToken(token), metricsHandler.GetMetrics)
+	}
+
+	server.Get("/ws", middlewares.ValidateWebSocket(authService), websocket.New(hub.HandleConnections))
 
 	server.Post("/login", authHandler.Login)
 	server.Post("/refresh-token", authHandler.RefreshToken)
+	server.Post("/logout", validateSession, authHandler.Logout)
+	server.Post("/logout-all", validateSession, authHandler.LogoutAll)
 	server.Post("/create-user", userHandler.CreateUser)
 
-	protected := server.Group("/users", middlewares.ValidateSession(authService))
+	// Calendar apps subscribe with the secret token alone
+	server.Get("/feeds/:token.ics", icalendarHandler.GetFeed)
+
+	protected := server.Group("/users", validateSession)
 
 	// Protected routes
+	self := middlewares.RequireSelf()
+	selfBody := middlewares.RequireSelfBody()
+	scheduleOwner := middlewares.RequireOwner("id", ownershipService.GetScheduleOwner)
+	groupOwner := middlewares.RequireOwner("groupId", ownershipService.GetGroupOwner)
+	recurrenceOwner := middlewares.RequireOwner("recurrenceId", ownershipService.GetRecurrenceOwner)
+	routineOwner := middlewares.RequireOwner("id", ownershipService.GetRoutineOwner)
+	routineLogOwner := middlewares.RequireOwner("id", ownershipService.GetRoutineLogOwner)
+	tagOwner := middlewares.RequireOwner("id", ownershipService.GetTagOwner)
+	dayOffOwner := middlewares.RequireOwner("id", ownershipService.GetDayOffOwner)
+
+	//Session routes
+	protected.Get("/sessions", authHandler.GetSessions)
+	protected.Delete("/sessions/:id", authHandler.RevokeSession)
+
 	//User routes
-	protected.Patch("/:googleId", userHandler.UpdateUser)
-	protected.Get("/info/:googleId", userHandler.GetUserInfo)
+	protected.Patch("/:googleId", self, userHandler.UpdateUser)
+	protected.Get("/info/:googleId", self, userHandler.GetUserInfo)
 
 	//Bedtime routes
-	protected.Post("/bedtimes", bedtimeHandler.CreateBedtime)
-	protected.Patch("/bedtimes/:googleId", bedtimeHandler.UpdateBedtime)
-	protected.Get("/bedtimes/info/:googleId", bedtimeHandler.GetBedtimeInfo)
+	protected.Post("/bedtimes", selfBody, bedtimeHandler.CreateBedtime)
+	protected.Patch("/bedtimes/:googleId", self, bedtimeHandler.UpdateBedtime)
+	protected.Get("/bedtimes/info/:googleId", self, bedtimeHandler.GetBedtimeInfo)
 
 	//Routine routes
-	protected.Post("/routines", routineHandler.CreateRoutine)
-	protected.Get("/routines/:googleId", routineHandler.GetAllRoutines)
-	protected.Patch("/routines/edit/:id", routineHandler.UpdateRoutine)
-	protected.Delete("/routines/:id", routineHandler.DeleteRoutine)
+	protected.Post("/routines", selfBody, routineHandler.CreateRoutine)
+	protected.Get("/routines/:googleId", self, routineHandler.GetAllRoutines)
+	protected.Patch("/routines/edit/:id", routineOwner, routineHandler.UpdateRoutine)
+	protected.Delete("/routines/:id", routineOwner, routineHandler.DeleteRoutine)
 
 	//RoutineLog routes
-	protected.Post("/routine-logs", routineLogHandler.InsertRoutineLog)
-	protected.Get("/routine-logs/:googleId/:date?", routineLogHandler.GetRoutineLogs)
-	protected.Delete("/routine-logs/:id", routineLogHandler.DeleteRoutineLog)
+	protected.Post("/routine-logs", selfBody, routineLogHandler.InsertRoutineLog)
+	protected.Get("/routine-logs/:googleId/:date?", self, routineLogHandler.GetRoutineLogs)
+	protected.Delete("/routine-logs/:id", routineLogOwner, routineLogHandler.DeleteRoutineLog)
 
 	//Tag routes
-	protected.Post("/tags", tagHandler.CreateTag)
-	protected.Get("/tags/:googleId", tagHandler.GetAllTags)
-	protected.Get("/tags/routines/:id", tagHandler.GetRoutinesByTagId)
-	protected.Patch("/tags/:id", tagHandler.UpdateTag)
-	protected.Delete("/tags/:id", tagHandler.DeleteTag)
+	protected.Post("/tags", selfBody, tagHandler.CreateTag)
+	protected.Get("/tags/:googleId", self, tagHandler.GetAllTags)
+	protected.Get("/tags/routines/:id", tagOwner, tagHandler.GetRoutinesByTagId)
+	protected.Patch("/tags/:id", tagOwner, tagHandler.UpdateTag)
+	protected.Delete("/tags/:id", tagOwner, tagHandler.DeleteTag)
 
 	//WeeklyReport routes
-	protected.Get("/weekly-reports/:googleId/:date", weeklyReportHandler.GetWeeklyReports)
+	protected.Get("/weekly-reports/:googleId/:date", self, weeklyReportHandler.GetWeeklyReports)
 
 	//WeeklyReportList routes
-	protected.Get("/weekly-report-lists/:googleId", weeklyReportListHandler.GetWeeklyReportLists)
+	protected.Get("/weekly-report-lists/:googleId", self, weeklyReportListHandler.GetWeeklyReportLists)
 
 	//Schedule routes
-	protected.Post("/schedules", scheduleHandler.CreateSchedule)
-	protected.Get("/schedules/all/:googleId/:date?", scheduleHandler.GetAllSchedules)
-	protected.Get("/schedules/:id", scheduleHandler.GetScheduleById)
-	protected.Get("/schedules/group/:groupId", scheduleHandler.GetSchedulesByGroupId)
-	protected.Get("/schedules/recurrence/:recurrenceId/:date?", scheduleHandler.GetSchedulesIdByRecurrenceId)
-	protected.Patch("/schedules/:id", scheduleHandler.UpdateSchedule)
-	protected.Patch(("/schedules/recurrence/:recurrenceId/:date?"), scheduleHandler.UpdateScheduleByRecurrenceId)
-	protected.Delete("/schedules/:groupId", scheduleHandler.DeleteSchedule)
-	protected.Delete("/schedules/recurrence/:recurrenceId/:date?", scheduleHandler.DeleteScheduleByRecurrenceId)
+	protected.Post("/schedules", selfBody, scheduleHandler.CreateSchedule)
+	protected.Get("/schedules/all/:googleId/:date?", self, scheduleHandler.GetAllSchedules)
+	protected.Get("/schedules/:googleId/export.ics", self, icalendarHandler.ExportSchedules)
+	protected.Post("/schedules/import", selfBody, icalendarHandler.ImportSchedules)
+	protected.Get("/schedules/:id", scheduleOwner, scheduleHandler.GetScheduleById)
+	protected.Get("/schedules/group/:groupId", groupOwner, scheduleHandler.GetSchedulesByGroupId)
+	protected.Get("/schedules/recurrence/:recurrenceId/:date?", recurrenceOwner, scheduleHandler.GetSchedulesIdByRecurrenceId)
+	protected.Patch("/schedules/:id", scheduleOwner, scheduleHandler.UpdateSchedule)
+	protected.Patch(("/schedules/recurrence/:recurrenceId/:date?"), recurrenceOwner, scheduleHandler.UpdateScheduleByRecurrenceId)
+	protected.Delete("/schedules/:groupId", groupOwner, scheduleHandler.DeleteSchedule)
+	protected.Delete("/schedules/recurrence/:recurrenceId/:date?", recurrenceOwner, scheduleHandler.DeleteScheduleByRecurrenceId)
+	protected.Post("/schedules/recurrence/:recurrenceId/dates", recurrenceOwner, scheduleHandler.AddRecurrenceDate)
+
+	//Feed routes
+	protected.Post("/feeds/:googleId", self, icalendarHandler.CreateFeed)
+	protected.Get("/feeds/:googleId", self, icalendarHandler.GetFeedInfo)
+	protected.Delete("/feeds/:googleId", self, icalendarHandler.RevokeFeed)
+
+	//Travel observation routes
+	protected.Post("/schedules/:id/arrival", scheduleOwner, travelObservationHandler.ReportArrival)
+	protected.Get("/travel-accuracy/:googleId", self, travelObservationHandler.GetTravelAccuracy)
+
+	//Calendar routes
+	protected.Get("/calendar/:googleId/:year", self, calendarHandler.GetCalendar)
+	protected.Post("/days-off", selfBody, calendarHandler.CreateDayOff)
+	protected.Delete("/days-off/:id", dayOffOwner, calendarHandler.DeleteDayOff)
 
 	//Feedback routes
-	protected.Post("/create-feedbacks", feedbackHandler.CreateFeedback)
+	protected.Post("/create-feedbacks", selfBody, feedbackHandler.CreateFeedback)
 
 	// listen to port 3000
 	log.Fatal(server.Listen(":3000"))
@@ -1,14 +0,0 @@
-package middlewares
-
-import (
-	jwtware "github.com/gofiber/contrib/jwt"
-	"github.com/gofiber/fiber/v2"
-	"os"
-)
-
-func NewAuthMiddleware() fiber.Handler {
-	secret := os.Getenv("JWT_SECRET")
-	return jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte(secret)},
-	})
-}
@@ -26,8 +26,9 @@ func ValidateSession(authService service.AuthService) fiber.Handler {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired access token"})
 		}
 
-		// Store the user ID in the context for further use in handlers
+		// Store the user ID and session ID in the context for further use in handlers
 		c.Locals("userId", claims["googleId"])
+		c.Locals("sessionId", claims["sid"])
 
 		// Proceed to the next middleware/handler
 		return c.Next()
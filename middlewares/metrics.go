@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireMetricsToken guards operational endpoints with a static bearer token.
+func RequireMetricsToken(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get("Authorization")
+		given := strings.TrimPrefix(header, "Bearer ")
+		if given == header || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package middlewares
+
+import (
+	"etalert-backend/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CurrentGoogleId returns the googleId that ValidateSession stored for the request.
+func CurrentGoogleId(c *fiber.Ctx) string {
+	googleId, _ := c.Locals("userId").(string)
+	return googleId
+}
+
+// CurrentSessionId returns the session ID that ValidateSession stored for the request.
+func CurrentSessionId(c *fiber.Ctx) string {
+	sessionId, _ := c.Locals("sessionId").(string)
+	return sessionId
+}
+
+// RequireSelf rejects requests whose :googleId path parameter is not the session user.
+func RequireSelf() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Params("googleId") != CurrentGoogleId(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+		}
+		return c.Next()
+	}
+}
+
+// RequireSelfBody rejects requests whose JSON body carries a googleId other than the session user.
+func RequireSelfBody() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			GoogleId string `json:"googleId"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+		if body.GoogleId != CurrentGoogleId(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+		}
+		return c.Next()
+	}
+}
+
+// RequireOwner looks up the owner of the resource named by the given path
+// parameter and only lets the request through when it is the session user.
+func RequireOwner(param string, getOwner func(string) (string, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		owner, err := getOwner(c.Params(param))
+		if err != nil {
+			if err == service.ErrResourceNotFound {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Resource not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check resource owner"})
+		}
+		if owner != CurrentGoogleId(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Access denied"})
+		}
+		return c.Next()
+	}
+}
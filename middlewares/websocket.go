@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"etalert-backend/service"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// ValidateWebSocket authenticates the /ws upgrade request. Browsers cannot set
+// headers on a WebSocket handshake, so the access token may also be passed as
+// the token query parameter.
+func ValidateWebSocket(authService service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		accessToken := c.Query("token")
+		if header := c.Get("Authorization"); header != "" {
+			parts := strings.Split(header, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid authorization header format"})
+			}
+			accessToken = parts[1]
+		}
+		if accessToken == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Missing access token"})
+		}
+
+		claims, err := authService.ValidateAccessToken(accessToken)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired access token"})
+		}
+
+		expiresAt, err := claims.GetExpirationTime()
+		if err != nil || expiresAt == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid or expired access token"})
+		}
+
+		c.Locals("userId", claims["googleId"])
+		c.Locals("sessionId", claims["sid"])
+		c.Locals("tokenExpiresAt", expiresAt.Time)
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores encoded values until expiresAt. Caches are best effort: a
+// backend failure is logged and reads as a miss.
+type Cache interface {
+	Get(key string) (value []byte, expiresAt time.Time, ok bool)
+	Set(key string, value []byte, expiresAt time.Time)
+}
+
+// CacheTTL is how long each kind of external data stays fresh.
+type CacheTTL struct {
+	TravelTime time.Duration
+	Traffic    time.Duration
+	Weather    time.Duration
+}
+
+func DefaultCacheTTL() CacheTTL {
+	return CacheTTL{
+		TravelTime: 30 * time.Minute,
+		Traffic:    5 * time.Minute,
+		Weather:    15 * time.Minute,
+	}
+}
+
+// CacheTTLFromEnv overrides the defaults with CACHE_TTL_TRAVEL_TIME,
+// CACHE_TTL_TRAFFIC and CACHE_TTL_WEATHER, given as Go durations. A zero
+// duration turns caching off for that kind.
+func CacheTTLFromEnv() (CacheTTL, error) {
+	ttl := DefaultCacheTTL()
+	settings := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"CACHE_TTL_TRAVEL_TIME", &ttl.TravelTime},
+		{"CACHE_TTL_TRAFFIC", &ttl.Traffic},
+		{"CACHE_TTL_WEATHER", &ttl.Weather},
+	}
+	for _, setting := range settings {
+		raw := os.Getenv(setting.name)
+		if raw == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(raw)
+		if err != nil || duration < 0 {
+			return CacheTTL{}, fmt.Errorf("invalid %s: %q", setting.name, raw)
+		}
+		*setting.value = duration
+	}
+	return ttl, nil
+}
+
+// layeredCache reads through its layers in order, e.g. an in-memory LRU in
+// front of a shared Mongo cache, and copies hits into the faster layers.
+type layeredCache struct {
+	layers []Cache
+}
+
+func NewLayeredCache(layers ...Cache) Cache {
+	return &layeredCache{layers: layers}
+}
+
+func (c *layeredCache) Get(key string) ([]byte, time.Time, bool) {
+	for i, layer := range c.layers {
+		value, expiresAt, ok := layer.Get(key)
+		if !ok {
+			continue
+		}
+		for _, faster := range c.layers[:i] {
+			faster.Set(key, value, expiresAt)
+		}
+		return value, expiresAt, true
+	}
+	return nil, time.Time{}, false
+}
+
+func (c *layeredCache) Set(key string, value []byte, expiresAt time.Time) {
+	for _, layer := range c.layers {
+		layer.Set(key, value, expiresAt)
+	}
+}
+
+const (
+	CacheKindTravelTime = "travelTime"
+	CacheKindTraffic    = "traffic"
+	CacheKindWeather    = "weather"
+)
+
+type CacheCounts struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+type cacheCounter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// CacheStats counts hits and misses per kind of cached data.
+type CacheStats struct {
+	mu       sync.Mutex
+	counters map[string]*cacheCounter
+}
+
+func NewCacheStats() *CacheStats {
+	return &CacheStats{counters: make(map[string]*cacheCounter)}
+}
+
+func (s *CacheStats) counter(kind string) *cacheCounter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counter, ok := s.counters[kind]
+	if !ok {
+		counter = &cacheCounter{}
+		s.counters[kind] = counter
+	}
+	return counter
+}
+
+func (s *CacheStats) record(kind string, hit bool) {
+	if hit {
+		s.counter(kind).hits.Add(1)
+	} else {
+		s.counter(kind).misses.Add(1)
+	}
+}
+
+func (s *CacheStats) Snapshot() map[string]CacheCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]CacheCounts, len(s.counters))
+	for kind, counter := range s.counters {
+		snapshot[kind] = CacheCounts{Hits: counter.hits.Load(), Misses: counter.misses.Load()}
+	}
+	return snapshot
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type cacheEntry struct {
+	Key       string    `bson:"_id"`
+	Value     []byte    `bson:"value"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// cacheRepositoryDB is shared by every instance, so a lookup made by one is
+// reused by the others.
+type cacheRepositoryDB struct {
+	collection *mongo.Collection
+}
+
+func NewCacheRepositoryDB(client *mongo.Client, dbName string, collName string) Cache {
+	collection := client.Database(dbName).Collection(collName)
+
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create cache expiry index: %v", err)
+	}
+
+	return &cacheRepositoryDB{collection: collection}
+}
+
+func (r *cacheRepositoryDB) Get(key string) ([]byte, time.Time, bool) {
+	ctx := context.Background()
+	var entry cacheEntry
+	// The TTL monitor runs about once a minute, so skip entries it has not removed yet
+	filter := bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}}
+	err := r.collection.FindOne(ctx, filter).Decode(&entry)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to read cache entry %s: %v", key, err)
+		}
+		return nil, time.Time{}, false
+	}
+	return entry.Value, entry.ExpiresAt, true
+}
+
+func (r *cacheRepositoryDB) Set(key string, value []byte, expiresAt time.Time) {
+	ctx := context.Background()
+	entry := cacheEntry{Key: key, Value: value, ExpiresAt: expiresAt}
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": key}, entry, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to write cache entry %s: %v", key, err)
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// lruCache keeps at most capacity entries in memory and evicts the least
+// recently used one when full.
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+func NewLRUCache(capacity int) Cache {
+	return &lruCache{capacity: capacity, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *lruCache) Get(key string) ([]byte, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return nil, time.Time{}, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, entry.expiresAt, true
+}
+
+func (c *lruCache) Set(key string, value []byte, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Lookups departing in the same slot of the day share a cache entry
+const cacheBucketSize = 15 * time.Minute
+
+// cachedTravelTimeProvider answers repeated lookups for the same route, mode
+// and departure slot from the cache.
+type cachedTravelTimeProvider struct {
+	provider TravelTimeProvider
+	cache    Cache
+	ttl      time.Duration
+	stats    *CacheStats
+}
+
+func NewCachedTravelTimeProvider(provider TravelTimeProvider, cache Cache, ttl time.Duration, stats *CacheStats) TravelTimeProvider {
+	return &cachedTravelTimeProvider{provider: provider, cache: cache, ttl: ttl, stats: stats}
+}
+
+func (p *cachedTravelTimeProvider) GetTravelTime(oriLat float64, oriLong float64, destLat float64, destLong float64, mode string, departAt time.Time) (*TravelTime, error) {
+	departure := departAt
+	if departure.IsZero() {
+		departure = time.Now()
+	}
+	key := fmt.Sprintf("travelTime:%s:%s,%s:%s,%s:%d", mode,
+		roundCoordinate(oriLat), roundCoordinate(oriLong), roundCoordinate(destLat), roundCoordinate(destLong),
+		timeBucket(departure))
+
+	var travelTime *TravelTime
+	err := cached(p.cache, p.stats, CacheKindTravelTime, key, p.ttl, &travelTime, func() error {
+		var err error
+		travelTime, err = p.provider.GetTravelTime(oriLat, oriLong, destLat, destLong, mode, departAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return travelTime, nil
+}
+
+// cachedScheduleRepository caches the traffic and weather lookups of a
+// ScheduleRepository and passes every other method through.
+type cachedScheduleRepository struct {
+	ScheduleRepository
+	cache Cache
+	ttl   CacheTTL
+	stats *CacheStats
+}
+
+func NewCachedScheduleRepository(repo ScheduleRepository, cache Cache, ttl CacheTTL, stats *CacheStats) ScheduleRepository {
+	return &cachedScheduleRepository{ScheduleRepository: repo, cache: cache, ttl: ttl, stats: stats}
+}
+
+func (r *cachedScheduleRepository) GetTraffic(minLat float64, minLong float64, maxLat float64, maxLong float64) (TrafficResponse, error) {
+	key := fmt.Sprintf("traffic:%s,%s:%s,%s:%d",
+		roundCoordinate(minLat), roundCoordinate(minLong), roundCoordinate(maxLat), roundCoordinate(maxLong),
+		timeBucket(time.Now()))
+
+	var traffic TrafficResponse
+	err := cached(r.cache, r.stats, CacheKindTraffic, key, r.ttl.Traffic, &traffic, func() error {
+		var err error
+		traffic, err = r.ScheduleRepository.GetTraffic(minLat, minLong, maxLat, maxLong)
+		return err
+	})
+	return traffic, err
+}
+
+func (r *cachedScheduleRepository) GetWeather(oriLat string, oriLong string, destLat string, destLong string, travelTime string) (Forecast, error) {
+	key := fmt.Sprintf("weather:%s,%s:%s,%s:%s:%d",
+		roundCoordinateText(oriLat), roundCoordinateText(oriLong), roundCoordinateText(destLat), roundCoordinateText(destLong),
+		travelTime, timeBucket(time.Now()))
+
+	var forecast Forecast
+	err := cached(r.cache, r.stats, CacheKindWeather, key, r.ttl.Weather, &forecast, func() error {
+		var err error
+		forecast, err = r.ScheduleRepository.GetWeather(oriLat, oriLong, destLat, destLong, travelTime)
+		return err
+	})
+	return forecast, err
+}
+
+// cached decodes the entry for key into value, or calls load to fill value
+// and stores the result for ttl. Failed loads are not cached.
+func cached(cache Cache, stats *CacheStats, kind string, key string, ttl time.Duration, value interface{}, load func() error) error {
+	if ttl <= 0 {
+		return load()
+	}
+
+	if data, _, ok := cache.Get(key); ok && json.Unmarshal(data, value) == nil {
+		stats.record(kind, true)
+		return nil
+	}
+	stats.record(kind, false)
+
+	if err := load(); err != nil {
+		return err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		cache.Set(key, data, time.Now().Add(ttl))
+	}
+	return nil
+}
+
+// roundCoordinate keeps three decimals, about 110 meters, so nearby points
+// share an entry.
+func roundCoordinate(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1000)/1000, 'f', 3, 64)
+}
+
+func roundCoordinateText(value string) string {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return roundCoordinate(parsed)
+}
+
+// timeBucket is the slot of the day, in UTC, that at falls in.
+func timeBucket(at time.Time) int64 {
+	return (at.Unix() % 86400) / int64(cacheBucketSize/time.Second)
+}
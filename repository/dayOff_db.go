@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type dayOffRepositoryDB struct {
+	collection *mongo.Collection
+}
+
+func NewDayOffRepositoryDB(client *mongo.Client, dbName string, collName string) DayOffRepository {
+	collection := client.Database(dbName).Collection(collName)
+	return &dayOffRepositoryDB{collection: collection}
+}
+
+func (r *dayOffRepositoryDB) InsertDayOff(dayOff *DayOff) error {
+	ctx := context.Background()
+	result, err := r.collection.InsertOne(ctx, dayOff)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		dayOff.Id = oid.Hex()
+	}
+	return nil
+}
+
+func (r *dayOffRepositoryDB) GetDayOffById(id string) (*DayOff, error) {
+	ctx := context.Background()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var dayOff DayOff
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&dayOff)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &dayOff, nil
+}
+
+func (r *dayOffRepositoryDB) GetDaysOff(googleId string, from time.Time, to time.Time) ([]*DayOff, error) {
+	ctx := context.Background()
+	var daysOff []*DayOff
+
+	filter := bson.M{
+		"googleId": googleId,
+		"date":     bson.M{"$gte": from, "$lte": to},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var dayOff DayOff
+		if err := cursor.Decode(&dayOff); err != nil {
+			return nil, err
+		}
+		daysOff = append(daysOff, &dayOff)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return daysOff, nil
+}
+
+func (r *dayOffRepositoryDB) DeleteDayOff(id string) error {
+	ctx := context.Background()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": objectId})
+	return err
+}
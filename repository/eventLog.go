@@ -0,0 +1,21 @@
+package repository
+
+import "time"
+
+// EventLog is one websocket event as it was pushed to a user, kept for a
+// short while so a reconnecting client can catch up on what it missed.
+type EventLog struct {
+	Id        string    `bson:"_id,omitempty"`
+	GoogleId  string    `bson:"googleId"`
+	Seq       int       `bson:"seq"`
+	Message   string    `bson:"message"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+type EventLogRepository interface {
+	GetNextSequence(googleId string) (int, error)
+	GetCurrentSequence(googleId string) (int, error)
+	InsertEvent(event *EventLog) error
+	GetEventsAfter(googleId string, seq int, limit int64) ([]*EventLog, error)
+}
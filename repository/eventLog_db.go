@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type eventLogRepositoryDB struct {
+	collection *mongo.Collection
+	counters   *mongo.Collection
+}
+
+// NewEventLogRepositoryDB keeps the per-user sequence counters in a sibling
+// collection, since they must outlive the events that expire from collName.
+func NewEventLogRepositoryDB(client *mongo.Client, dbName string, collName string) EventLogRepository {
+	database := client.Database(dbName)
+	collection := database.Collection(collName)
+
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "googleId", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create event log indexes: %v", err)
+	}
+
+	return &eventLogRepositoryDB{collection: collection, counters: database.Collection(collName + "Sequence")}
+}
+
+func (r *eventLogRepositoryDB) GetNextSequence(googleId string) (int, error) {
+	var counter Counter
+	ctx := context.Background()
+	err := r.counters.FindOneAndUpdate(ctx, bson.M{"_id": googleId}, bson.M{"$inc": bson.M{"seq": 1}}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&counter)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next event sequence: %v", err)
+	}
+
+	return counter.Seq, nil
+}
+
+func (r *eventLogRepositoryDB) GetCurrentSequence(googleId string) (int, error) {
+	var counter Counter
+	ctx := context.Background()
+	err := r.counters.FindOne(ctx, bson.M{"_id": googleId}).Decode(&counter)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get event sequence: %v", err)
+	}
+
+	return counter.Seq, nil
+}
+
+func (r *eventLogRepositoryDB) InsertEvent(event *EventLog) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+func (r *eventLogRepositoryDB) GetEventsAfter(googleId string, seq int, limit int64) ([]*EventLog, error) {
+	ctx := context.Background()
+	var events []*EventLog
+
+	filter := bson.M{
+		"googleId": googleId,
+		"seq":      bson.M{"$gt": seq},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var event EventLog
+		if err := cursor.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
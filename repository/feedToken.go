@@ -0,0 +1,19 @@
+package repository
+
+import "time"
+
+// FeedToken lets calendar apps read a user's schedules without a session.
+// Only the SHA-256 hash of the token is kept, as the Id, so the token itself
+// is only known when it is created.
+type FeedToken struct {
+	Id        string    `bson:"_id"`
+	GoogleId  string    `bson:"googleId"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+type FeedTokenRepository interface {
+	InsertFeedToken(token *FeedToken) error
+	GetFeedToken(hash string) (*FeedToken, error)
+	GetFeedTokenByGoogleId(googleId string) (*FeedToken, error)
+	DeleteFeedToken(googleId string) error
+}
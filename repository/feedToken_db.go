@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type feedTokenRepositoryDB struct {
+	collection *mongo.Collection
+}
+
+func NewFeedTokenRepositoryDB(client *mongo.Client, dbName string, collName string) FeedTokenRepository {
+	collection := client.Database(dbName).Collection(collName)
+
+	// A user has one feed at a time
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "googleId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create feed token index: %v", err)
+	}
+
+	return &feedTokenRepositoryDB{collection: collection}
+}
+
+func (r *feedTokenRepositoryDB) InsertFeedToken(token *FeedToken) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *feedTokenRepositoryDB) GetFeedToken(hash string) (*FeedToken, error) {
+	ctx := context.Background()
+	var token FeedToken
+	err := r.collection.FindOne(ctx, bson.M{"_id": hash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *feedTokenRepositoryDB) GetFeedTokenByGoogleId(googleId string) (*FeedToken, error) {
+	ctx := context.Background()
+	var token FeedToken
+	err := r.collection.FindOne(ctx, bson.M{"googleId": googleId}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *feedTokenRepositoryDB) DeleteFeedToken(googleId string) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"googleId": googleId})
+	return err
+}
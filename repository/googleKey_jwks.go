@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const GoogleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+// Google rotates its signing keys every few days, so a cached key set is
+// refetched once Cache-Control expires or when an unknown kid shows up.
+const (
+	defaultKeySetTTL = time.Hour
+	minKeySetRefetch = time.Minute
+)
+
+var maxAgePattern = regexp.MustCompile(`max-age=(\d+)`)
+
+type jwksKeySource struct {
+	url       string
+	client    *http.Client
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+func NewJWKSKeySource(url string) GoogleKeySource {
+	return &jwksKeySource{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *jwksKeySource) GetKey(kid string) (*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key, ok := s.keys[kid]
+	if ok && now.Before(s.expiresAt) {
+		return key, nil
+	}
+
+	if !ok && now.Sub(s.fetchedAt) < minKeySetRefetch && now.Before(s.expiresAt) {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+
+	if err := s.refresh(now); err != nil {
+		if ok {
+			// Keep serving the last known key if Google is unreachable
+			return key, nil
+		}
+		return nil, err
+	}
+
+	key, ok = s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return key, nil
+}
+
+func (s *jwksKeySource) refresh(now time.Time) error {
+	s.fetchedAt = now
+
+	response, err := s.client.Get(s.url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch key set: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response status: %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read key set: %v", err)
+	}
+
+	keys, err := parseJSONWebKeySet(body)
+	if err != nil {
+		return err
+	}
+
+	ttl := defaultKeySetTTL
+	if match := maxAgePattern.FindStringSubmatch(response.Header.Get("Cache-Control")); len(match) > 1 {
+		if seconds, err := strconv.Atoi(match[1]); err == nil {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+
+	s.keys = keys
+	s.expiresAt = now.Add(ttl)
+	return nil
+}
+
+type staticKeySource struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// NewStaticKeySource builds a key source from a local JWKS document, for
+// development and tests that sign their own ID tokens.
+func NewStaticKeySource(jwks []byte) (GoogleKeySource, error) {
+	keys, err := parseJSONWebKeySet(jwks)
+	if err != nil {
+		return nil, err
+	}
+	return &staticKeySource{keys: keys}, nil
+}
+
+func (s *staticKeySource) GetKey(kid string) (*rsa.PublicKey, error) {
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return key, nil
+}
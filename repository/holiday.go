@@ -0,0 +1,38 @@
+package repository
+
+import "time"
+
+// DefaultCountry is the holiday calendar of users who have not set a country.
+const DefaultCountry = "TH"
+
+// Holiday is a public holiday. Dates are UTC midnights.
+type Holiday struct {
+	Date time.Time `json:"date"`
+	Name string    `json:"name"`
+}
+
+// HolidayCalendar knows the public holidays of the countries it has data for.
+// Countries it has no data for have no holidays.
+type HolidayCalendar interface {
+	GetHolidays(country string, from time.Time, to time.Time) []Holiday
+	Countries() []string
+	// CoveredUntil is the last date the holidays of country are all known
+	// for: the end of the last year holidays that move every year are listed
+	// for. It is zero if all of them recur by rule.
+	CoveredUntil(country string) time.Time
+}
+
+// DayOff is a day a user is off on top of their country's public holidays.
+type DayOff struct {
+	Id       string    `bson:"_id,omitempty"`
+	GoogleId string    `bson:"googleId"`
+	Date     time.Time `bson:"date"`
+	Name     string    `bson:"name"`
+}
+
+type DayOffRepository interface {
+	InsertDayOff(dayOff *DayOff) error
+	GetDayOffById(id string) (*DayOff, error)
+	GetDaysOff(googleId string, from time.Time, to time.Time) ([]*DayOff, error)
+	DeleteDayOff(id string) error
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The bundled holiday sets, one file per ISO 3166 country code, as either
+// JSON or iCalendar.
+//
+//go:embed holidays
+var bundledHolidays embed.FS
+
+// holidayEntry is a holiday that falls on Start, and every date after it its
+// rule gives if it has one.
+type holidayEntry struct {
+	Start time.Time
+	Rule  *RecurrenceRule
+	Name  string
+}
+
+type bundledHolidayCalendar struct {
+	entries      map[string][]holidayEntry
+	coveredUntil map[string]time.Time
+}
+
+// holidayFile is the JSON format: holidays on the same day every year, given
+// as "01-02" (month and day), and ones on a single "2006-01-02" date.
+type holidayFile struct {
+	Annual []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"annual"`
+	Dates []struct {
+		Date string `json:"date"`
+		Name string `json:"name"`
+	} `json:"dates"`
+}
+
+// NewBundledHolidayCalendar reads the holiday sets compiled into the binary.
+func NewBundledHolidayCalendar() (HolidayCalendar, error) {
+	files, err := bundledHolidays.ReadDir("holidays")
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := &bundledHolidayCalendar{
+		entries:      make(map[string][]holidayEntry),
+		coveredUntil: make(map[string]time.Time),
+	}
+	for _, file := range files {
+		data, err := bundledHolidays.ReadFile(path.Join("holidays", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		ext := path.Ext(file.Name())
+		country := strings.ToUpper(strings.TrimSuffix(file.Name(), ext))
+		var entries []holidayEntry
+		switch ext {
+		case ".json":
+			entries, err = parseHolidayJSON(data)
+		case ".ics":
+			entries, err = parseHolidayICS(data)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read holidays/%s: %v", file.Name(), err)
+		}
+		calendar.entries[country] = append(calendar.entries[country], entries...)
+
+		for _, entry := range entries {
+			if entry.Rule != nil {
+				continue
+			}
+			endOfYear := time.Date(entry.Start.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+			if endOfYear.After(calendar.coveredUntil[country]) {
+				calendar.coveredUntil[country] = endOfYear
+			}
+		}
+	}
+	return calendar, nil
+}
+
+func (c *bundledHolidayCalendar) GetHolidays(country string, from time.Time, to time.Time) []Holiday {
+	var holidays []Holiday
+	for _, entry := range c.entries[strings.ToUpper(country)] {
+		if entry.Rule == nil {
+			if !entry.Start.Before(from) && !entry.Start.After(to) {
+				holidays = append(holidays, Holiday{Date: entry.Start, Name: entry.Name})
+			}
+			continue
+		}
+		for _, date := range entry.Rule.Between(entry.Start, from, to) {
+			holidays = append(holidays, Holiday{Date: date, Name: entry.Name})
+		}
+	}
+
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+func (c *bundledHolidayCalendar) CoveredUntil(country string) time.Time {
+	return c.coveredUntil[strings.ToUpper(country)]
+}
+
+func (c *bundledHolidayCalendar) Countries() []string {
+	countries := make([]string, 0, len(c.entries))
+	for country := range c.entries {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+func parseHolidayJSON(data []byte) ([]holidayEntry, error) {
+	var file holidayFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	yearly := &RecurrenceRule{Freq: FreqYearly, Interval: 1}
+	var entries []holidayEntry
+	for _, holiday := range file.Annual {
+		// 2000 is a leap year, so 29 February is kept
+		start, err := time.Parse("2006-01-02", "2000-"+holiday.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annual date %q", holiday.Date)
+		}
+		entries = append(entries, holidayEntry{Start: start, Rule: yearly, Name: holiday.Name})
+	}
+	for _, holiday := range file.Dates {
+		date, err := time.Parse("2006-01-02", holiday.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q", holiday.Date)
+		}
+		entries = append(entries, holidayEntry{Start: date, Name: holiday.Name})
+	}
+	return entries, nil
+}
+
+// parseHolidayICS reads the all-day VEVENTs of an iCalendar file: their
+// DTSTART, SUMMARY and, for holidays that recur, RRULE.
+func parseHolidayICS(data []byte) ([]holidayEntry, error) {
+	events, err := ParseICSEvents(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []holidayEntry
+	for _, event := range events {
+		entry := holidayEntry{Name: event.Value("SUMMARY")}
+
+		value := event.Value("DTSTART")
+		if value == "" {
+			return nil, fmt.Errorf("VEVENT %q has no DTSTART", entry.Name)
+		}
+		entry.Start, err = time.Parse("20060102", value[:min(len(value), 8)])
+		if err != nil {
+			return nil, fmt.Errorf("invalid DTSTART %q", value)
+		}
+
+		if value := event.Value("RRULE"); value != "" {
+			entry.Rule, err = ParseRecurrence(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCalendar = errors.New("invalid iCalendar data")
+
+// ICSProperty is a content line of an iCalendar file. Names and parameter
+// names are upper-cased; values are left as written, escapes included.
+type ICSProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// ICSEvent is a VEVENT with its properties in the order they were written.
+// Those of components nested in it, such as VALARMs, are left out.
+type ICSEvent struct {
+	Properties []ICSProperty
+}
+
+// Get is the first property called name, or nil if the event has none.
+func (e *ICSEvent) Get(name string) *ICSProperty {
+	for i := range e.Properties {
+		if e.Properties[i].Name == name {
+			return &e.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Value is the value of the first property called name, or "".
+func (e *ICSEvent) Value(name string) string {
+	if property := e.Get(name); property != nil {
+		return property.Value
+	}
+	return ""
+}
+
+// All lists every property called name, for those that may repeat such as
+// EXDATE.
+func (e *ICSEvent) All(name string) []ICSProperty {
+	var properties []ICSProperty
+	for _, property := range e.Properties {
+		if property.Name == name {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+// ParseICSEvents reads the VEVENTs of an iCalendar file. Lines that are not
+// content lines are skipped, but data without a VCALENDAR is rejected.
+func ParseICSEvents(data []byte) ([]ICSEvent, error) {
+	var events []ICSEvent
+	var event *ICSEvent
+	// Components opened inside the current event
+	depth := 0
+	hasCalendar := false
+
+	for _, line := range unfoldICSLines(data) {
+		property, ok := parseICSProperty(line)
+		if !ok {
+			continue
+		}
+		value := strings.ToUpper(property.Value)
+
+		switch {
+		case property.Name == "BEGIN" && value == "VCALENDAR":
+			hasCalendar = true
+		case property.Name == "BEGIN" && value == "VEVENT" && event == nil:
+			event = &ICSEvent{}
+		case event == nil:
+			continue
+		case property.Name == "BEGIN":
+			depth++
+		case property.Name == "END" && depth > 0:
+			depth--
+		case property.Name == "END" && value == "VEVENT":
+			events = append(events, *event)
+			event = nil
+		case depth == 0:
+			event.Properties = append(event.Properties, property)
+		}
+	}
+
+	if !hasCalendar {
+		return nil, ErrInvalidCalendar
+	}
+	return events, nil
+}
+
+// parseICSProperty splits a content line into its name, parameters and
+// value. Parameter values may be quoted, so the ';' and ':' inside quotes
+// are not separators.
+func parseICSProperty(line string) (ICSProperty, bool) {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				property := ICSProperty{Name: strings.ToUpper(parts[0]), Value: line[i+1:]}
+				for _, param := range parts[1:] {
+					name, value, _ := strings.Cut(param, "=")
+					if property.Params == nil {
+						property.Params = make(map[string]string)
+					}
+					property.Params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+				}
+				return property, property.Name != ""
+			}
+		}
+	}
+	return ICSProperty{}, false
+}
+
+// unfoldICSLines splits iCalendar content into lines, joining the
+// continuation lines that start with a space or tab onto the one before.
+func unfoldICSLines(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseICSEvents(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:event-1@example.com",
+		// Folded with a space and then a tab, within a multi-byte character
+		"SUMMARY:Weekly sync with the ",
+		" design team at the \xe0\xb8",
+		"\t\xa3าน",
+		`DTSTART;TZID="America/New_York":20260302T090000`,
+		`ATTENDEE;CN="Doe; Jane";DELEGATED-FROM="mailto:boss@example.com":mailto:jane@example.com`,
+		"location:Room 1",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"END:VALARM",
+		"EXDATE;TZID=America/New_York:20260309T090000",
+		"EXDATE;TZID=America/New_York:20260316T090000,20260323T090000",
+		"END:VEVENT",
+		"X-NOT-IN-AN-EVENT:ignored",
+		"not a content line",
+		"BEGIN:VEVENT",
+		"UID:event-2@example.com",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := ParseICSEvents([]byte(calendar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	event := events[0]
+
+	tests := []struct {
+		name   string
+		value  string
+		params map[string]string
+	}{
+		{"UID", "event-1@example.com", nil},
+		{"SUMMARY", "Weekly sync with the design team at the ราน", nil},
+		{"DTSTART", "20260302T090000", map[string]string{"TZID": "America/New_York"}},
+		{"ATTENDEE", "mailto:jane@example.com", map[string]string{"CN": "Doe; Jane", "DELEGATED-FROM": "mailto:boss@example.com"}},
+		{"LOCATION", "Room 1", nil},
+	}
+	for _, test := range tests {
+		property := event.Get(test.name)
+		if property == nil {
+			t.Errorf("%s missing", test.name)
+			continue
+		}
+		if property.Value != test.value || !reflect.DeepEqual(property.Params, test.params) {
+			t.Errorf("%s: got %q with %v, want %q with %v", test.name, property.Value, property.Params, test.value, test.params)
+		}
+	}
+
+	if event.Get("TRIGGER") != nil {
+		t.Error("property of the VALARM kept on the event")
+	}
+	if exDates := event.All("EXDATE"); len(exDates) != 2 || exDates[1].Value != "20260316T090000,20260323T090000" {
+		t.Errorf("EXDATE %+v", exDates)
+	}
+	if events[1].Value("UID") != "event-2@example.com" || len(events[1].Properties) != 1 {
+		t.Errorf("second event %+v", events[1])
+	}
+}
+
+func TestParseICSEventsRejects(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"BEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\n",
+		"<html><body>Not found</body></html>",
+	} {
+		if _, err := ParseICSEvents([]byte(data)); !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("%q: got %v, want ErrInvalidCalendar", data, err)
+		}
+	}
+}
+
+func TestParseICSProperty(t *testing.T) {
+	tests := []struct {
+		line     string
+		property ICSProperty
+		ok       bool
+	}{
+		{"DTSTART:20260302T090000Z", ICSProperty{Name: "DTSTART", Value: "20260302T090000Z"}, true},
+		// Only the first unquoted ':' ends the parameters
+		{"DESCRIPTION:See https://example.com: 10:00", ICSProperty{Name: "DESCRIPTION", Value: "See https://example.com: 10:00"}, true},
+		{`X-LINK;LABEL="a:b;c":http://x`, ICSProperty{Name: "X-LINK", Params: map[string]string{"LABEL": "a:b;c"}, Value: "http://x"}, true},
+		{"rdate;value=date:20260302", ICSProperty{Name: "RDATE", Params: map[string]string{"VALUE": "date"}, Value: "20260302"}, true},
+		{"no colon here", ICSProperty{}, false},
+		{":value without a name", ICSProperty{}, false},
+	}
+	for _, test := range tests {
+		property, ok := parseICSProperty(test.line)
+		if ok != test.ok || ok && !reflect.DeepEqual(property, test.property) {
+			t.Errorf("%q: got %+v, %v, want %+v, %v", test.line, property, ok, test.property, test.ok)
+		}
+	}
+}
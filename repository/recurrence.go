@@ -0,0 +1,553 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+const (
+	// Periods scanned before giving up on a rule that rarely or never matches,
+	// such as BYMONTHDAY=30 with BYMONTH=2
+	maxRecurrencePeriods = 5000
+
+	// Upper bound on COUNT
+	maxRecurrenceOccurrences = 1000
+)
+
+// The keywords schedules were created with before RRULEs were accepted.
+var recurrenceKeywords = map[string]string{
+	"daily":   FreqDaily,
+	"weekly":  FreqWeekly,
+	"monthly": FreqMonthly,
+	"yearly":  FreqYearly,
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// RecurrenceDay is a BYDAY entry. N picks the nth such weekday of the month
+// or year (negative counts from the end); 0 means every one.
+type RecurrenceDay struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// RecurrenceRule is the date part of an RFC 5545 RRULE. Times of day come
+// from the schedule itself, so BYHOUR and the like are not supported.
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []RecurrenceDay
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	Count      int
+	// Last date the series may fall on, inclusive
+	Until time.Time
+
+	keyword string
+}
+
+// ParseRecurrence reads an RRULE such as "FREQ=WEEKLY;BYDAY=MO,WE,FR", with
+// or without the "RRULE:" prefix, or one of the keywords daily, weekly,
+// monthly and yearly.
+func ParseRecurrence(value string) (*RecurrenceRule, error) {
+	value = strings.TrimSpace(value)
+	if freq, ok := recurrenceKeywords[strings.ToLower(value)]; ok {
+		return &RecurrenceRule{Freq: freq, Interval: 1, keyword: strings.ToLower(value)}, nil
+	}
+
+	value = strings.TrimPrefix(strings.ToUpper(value), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRecurrence)
+	}
+
+	rule := &RecurrenceRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRecurrence, part)
+		}
+
+		var err error
+		switch name {
+		case "FREQ":
+			if val != FreqDaily && val != FreqWeekly && val != FreqMonthly && val != FreqYearly {
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRecurrence, val)
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			rule.Interval, err = parseRuleInt(val, 1, 1000)
+		case "COUNT":
+			rule.Count, err = parseRuleInt(val, 1, maxRecurrenceOccurrences)
+		case "UNTIL":
+			rule.Until, err = parseRuleUntil(val)
+		case "BYDAY":
+			rule.ByDay, err = parseRuleDays(val)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseRuleInts(val, 31)
+		case "BYMONTH":
+			rule.ByMonth, err = parseRuleInts(val, 12)
+			for _, month := range rule.ByMonth {
+				if month < 0 {
+					err = fmt.Errorf("BYMONTH cannot be negative")
+				}
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseRuleInts(val, 366)
+		case "WKST":
+			// Weeks always start on Monday
+			if val != "MO" {
+				err = fmt.Errorf("only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("unsupported part %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRecurrence, err)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRecurrence)
+	}
+	if rule.Count != 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalidRecurrence)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != FreqMonthly && rule.Freq != FreqYearly {
+			return nil, fmt.Errorf("%w: numbered BYDAY needs FREQ=MONTHLY or YEARLY", ErrInvalidRecurrence)
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == FreqWeekly {
+		return nil, fmt.Errorf("%w: BYMONTHDAY cannot be used with FREQ=WEEKLY", ErrInvalidRecurrence)
+	}
+
+	return rule, nil
+}
+
+// String is the rule as it is stored on schedules: the keyword it was given
+// as, or a normalised RRULE without the "RRULE:" prefix.
+func (r *RecurrenceRule) String() string {
+	if r.keyword != "" {
+		return r.keyword
+	}
+
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinRuleInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinRuleInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinRuleInts(r.BySetPos))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// RRule is the rule as an RRULE even if it was given as a keyword.
+func (r *RecurrenceRule) RRule() string {
+	rule := *r
+	rule.keyword = ""
+	return rule.String()
+}
+
+func (d RecurrenceDay) String() string {
+	for code, weekday := range weekdayCodes {
+		if weekday == d.Weekday {
+			if d.N == 0 {
+				return code
+			}
+			return strconv.Itoa(d.N) + code
+		}
+	}
+	return ""
+}
+
+// Occurrences lists the dates of the series starting on start, a UTC midnight
+// date, up to max of them and no later than the rule's COUNT or UNTIL. The
+// start date itself is only included if it matches the rule.
+func (r *RecurrenceRule) Occurrences(start time.Time, max int) []time.Time {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	limit := max
+	if r.Count > 0 && r.Count < limit {
+		limit = r.Count
+	}
+
+	var dates []time.Time
+	for period := 0; period < maxRecurrencePeriods && len(dates) < limit; period++ {
+		for _, date := range r.periodDates(start, period*r.Interval) {
+			if date.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && date.After(r.Until) {
+				return dates
+			}
+			dates = append(dates, date)
+			if len(dates) == limit {
+				return dates
+			}
+		}
+	}
+	return dates
+}
+
+// Between lists the dates of the series starting on start that fall between
+// from and to, both inclusive. Periods before from are skipped rather than
+// expanded, unless COUNT has to be counted from the start.
+func (r *RecurrenceRule) Between(start time.Time, from time.Time, to time.Time) []time.Time {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	if from.Before(start) {
+		from = start
+	}
+	if !r.Until.IsZero() && r.Until.Before(to) {
+		to = r.Until
+	}
+
+	var dates []time.Time
+	if r.Count > 0 {
+		for _, date := range r.Occurrences(start, r.Count) {
+			if !date.Before(from) && !date.After(to) {
+				dates = append(dates, date)
+			}
+		}
+		return dates
+	}
+
+	first := r.periodsBetween(start, from) / r.Interval
+	for period := first; period < first+maxRecurrencePeriods; period++ {
+		if r.periodStart(start, period*r.Interval).After(to) {
+			break
+		}
+		for _, date := range r.periodDates(start, period*r.Interval) {
+			if date.Before(from) {
+				continue
+			}
+			if date.After(to) {
+				return dates
+			}
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// EndsBy reports whether the series starting on start has no occurrences
+// after date.
+func (r *RecurrenceRule) EndsBy(start time.Time, date time.Time) bool {
+	if !r.Until.IsZero() && !r.Until.After(date) {
+		return true
+	}
+	if r.Count > 0 {
+		dates := r.Occurrences(start, r.Count)
+		return len(dates) == 0 || !dates[len(dates)-1].After(date)
+	}
+	return false
+}
+
+// periodStart is the first day of the day, week, month or year that lies
+// offset periods after the one containing start.
+func (r *RecurrenceRule) periodStart(start time.Time, offset int) time.Time {
+	switch r.Freq {
+	case FreqWeekly:
+		return start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+offset*7)
+	case FreqMonthly:
+		return time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, time.UTC)
+	case FreqYearly:
+		return time.Date(start.Year()+offset, time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return start.AddDate(0, 0, offset)
+	}
+}
+
+// periodsBetween counts the whole periods from the one containing start to
+// the one containing date.
+func (r *RecurrenceRule) periodsBetween(start time.Time, date time.Time) int {
+	switch r.Freq {
+	case FreqWeekly:
+		days := r.periodStart(date, 0).Sub(r.periodStart(start, 0)).Hours() / 24
+		return int(days+0.5) / 7
+	case FreqMonthly:
+		return (date.Year()-start.Year())*12 + int(date.Month()) - int(start.Month())
+	case FreqYearly:
+		return date.Year() - start.Year()
+	default:
+		return int(date.Sub(start).Hours()/24 + 0.5)
+	}
+}
+
+// periodDates expands the rule within the day, week, month or year that lies
+// offset periods after the one containing start.
+func (r *RecurrenceRule) periodDates(start time.Time, offset int) []time.Time {
+	var candidates []time.Time
+	switch r.Freq {
+	case FreqDaily:
+		day := start.AddDate(0, 0, offset)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			candidates = append(candidates, day)
+		}
+	case FreqWeekly:
+		weekStart := r.periodStart(start, offset)
+		for i := 0; i < 7; i++ {
+			day := weekStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchesMonth(day) && r.matchesWeekday(day) {
+				candidates = append(candidates, day)
+			}
+		}
+	case FreqMonthly:
+		month := r.periodStart(start, offset)
+		if r.matchesMonth(month) {
+			candidates = r.monthDates(month, start)
+		}
+	case FreqYearly:
+		year := start.Year() + offset
+		if len(r.ByMonth) > 0 || len(r.ByMonthDay) > 0 {
+			for m := time.January; m <= time.December; m++ {
+				month := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+				if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && m != start.Month() {
+					continue
+				}
+				if len(r.ByMonth) == 0 && len(r.ByMonthDay) > 0 || r.matchesMonth(month) {
+					candidates = append(candidates, r.monthDates(month, start)...)
+				}
+			}
+		} else if len(r.ByDay) > 0 {
+			first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+			candidates = r.nthWeekdays(first, first.AddDate(1, 0, 0))
+		} else {
+			day := time.Date(year, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			// 29 February only recurs in leap years
+			if day.Day() == start.Day() {
+				candidates = append(candidates, day)
+			}
+		}
+	}
+
+	return r.applySetPos(candidates)
+}
+
+// monthDates expands BYMONTHDAY and BYDAY within a month, falling back to the
+// start's day of the month. Months without that day are skipped.
+func (r *RecurrenceRule) monthDates(month time.Time, start time.Time) []time.Time {
+	next := month.AddDate(0, 1, 0)
+	var dates []time.Time
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for day := month; day.Before(next); day = day.AddDate(0, 0, 1) {
+			if r.matchesMonthDay(day) && r.matchesWeekday(day) {
+				dates = append(dates, day)
+			}
+		}
+	case len(r.ByDay) > 0:
+		dates = r.nthWeekdays(month, next)
+	default:
+		day := time.Date(month.Year(), month.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if day.Before(next) {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+// nthWeekdays lists the BYDAY days between from and to, counting numbered
+// entries within that range.
+func (r *RecurrenceRule) nthWeekdays(from time.Time, to time.Time) []time.Time {
+	byWeekday := make(map[time.Weekday][]time.Time)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		byWeekday[day.Weekday()] = append(byWeekday[day.Weekday()], day)
+	}
+
+	seen := make(map[time.Time]bool)
+	var dates []time.Time
+	for _, byDay := range r.ByDay {
+		days := byWeekday[byDay.Weekday]
+		switch {
+		case byDay.N == 0:
+			for _, day := range days {
+				if !seen[day] {
+					seen[day] = true
+					dates = append(dates, day)
+				}
+			}
+		case byDay.N > 0 && byDay.N <= len(days):
+			day := days[byDay.N-1]
+			if !seen[day] {
+				seen[day] = true
+				dates = append(dates, day)
+			}
+		case byDay.N < 0 && -byDay.N <= len(days):
+			day := days[len(days)+byDay.N]
+			if !seen[day] {
+				seen[day] = true
+				dates = append(dates, day)
+			}
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+func (r *RecurrenceRule) applySetPos(candidates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(candidates) == 0 {
+		return candidates
+	}
+
+	var dates []time.Time
+	for _, pos := range r.BySetPos {
+		switch {
+		case pos > 0 && pos <= len(candidates):
+			dates = append(dates, candidates[pos-1])
+		case pos < 0 && -pos <= len(candidates):
+			dates = append(dates, candidates[len(candidates)+pos])
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+func (r *RecurrenceRule) matchesMonth(day time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if time.Month(month) == day.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecurrenceRule) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, monthDay := range r.ByMonthDay {
+		if monthDay == day.Day() || monthDay < 0 && daysInMonth+monthDay+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday ignores the numbers of BYDAY entries; they are only used
+// when expanding months and years.
+func (r *RecurrenceRule) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, byDay := range r.ByDay {
+		if byDay.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRuleInt(value string, min int, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%q is not between %d and %d", value, min, max)
+	}
+	return n, nil
+}
+
+// parseRuleInts reads a list of non-zero numbers between -max and max.
+func parseRuleInts(value string, max int) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -max || n > max {
+			return nil, fmt.Errorf("%q is not between -%d and %d", item, max, max)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+func parseRuleDays(value string) ([]RecurrenceDay, error) {
+	var days []RecurrenceDay
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		weekday, ok := weekdayCodes[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY %q", item)
+		}
+		day := RecurrenceDay{Weekday: weekday}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY %q", item)
+			}
+			day.N = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseRuleUntil keeps only the date of UNTIL, as occurrences are dates.
+func parseRuleUntil(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+	}
+	until, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+	}
+	return until, nil
+}
+
+func joinRuleInts(values []int) string {
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = strconv.Itoa(value)
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,66 @@
+package repository
+
+import "time"
+
+// RecurrenceSeries is the master of a recurring schedule. It keeps what every
+// occurrence is built from, while the occurrences themselves are only
+// materialized as schedules a few weeks ahead, through MaterializedUntil.
+type RecurrenceSeries struct {
+	Id              string  `bson:"_id,omitempty"`
+	RecurrenceId    int     `bson:"recurrenceId"`
+	GoogleId        string  `bson:"googleId"`
+	Name            string  `bson:"name"`
+	StartTime       string  `bson:"startTime"`
+	EndTime         string  `bson:"endTime"`
+	IsHaveEndTime   bool    `bson:"isHaveEndTime"`
+	OriName         string  `bson:"oriName"`
+	OriLatitude     float64 `bson:"oriLatitude"`
+	OriLongitude    float64 `bson:"oriLongitude"`
+	DestName        string  `bson:"destName"`
+	DestLatitude    float64 `bson:"destLatitude"`
+	DestLongitude   float64 `bson:"destLongitude"`
+	Transportation  string  `bson:"transportation"`
+	Priority        int     `bson:"priority"`
+	IsHaveLocation  bool    `bson:"isHaveLocation"`
+	IsFirstSchedule bool    `bson:"isFirstSchedule"`
+	TagId           string  `bson:"tag"`
+	Recurrence      string  `bson:"recurrence"`
+
+	// Occurrences on the owner's holidays and days off are left out, and
+	// with WorkingDaysOnly those on weekends too
+	SkipHolidays    bool `bson:"skipHolidays"`
+	WorkingDaysOnly bool `bson:"workingDaysOnly"`
+
+	// Dates are UTC midnights of days in the owner's time zone. EndDate cuts
+	// the series short after it has been split or deleted from a date on
+	StartDate         time.Time `bson:"startDate"`
+	EndDate           time.Time `bson:"endDate,omitempty"`
+	MaterializedUntil time.Time `bson:"materializedUntil"`
+
+	// Set once every occurrence has been materialized
+	IsComplete bool `bson:"isComplete"`
+
+	// Dates added to the rule's, and changes to single occurrences
+	RDates     []time.Time           `bson:"rDates,omitempty"`
+	Exceptions []RecurrenceException `bson:"exceptions,omitempty"`
+}
+
+// RecurrenceException changes the occurrence a series has on Date: it is
+// either cancelled or moved to NewDate with its own name and times.
+type RecurrenceException struct {
+	Date          time.Time `bson:"date"`
+	IsCancelled   bool      `bson:"isCancelled"`
+	NewDate       time.Time `bson:"newDate,omitempty"`
+	Name          string    `bson:"name,omitempty"`
+	StartTime     string    `bson:"startTime,omitempty"`
+	EndTime       string    `bson:"endTime,omitempty"`
+	IsHaveEndTime bool      `bson:"isHaveEndTime"`
+}
+
+type RecurrenceSeriesRepository interface {
+	InsertSeries(series *RecurrenceSeries) error
+	GetSeriesByRecurrenceId(recurrenceId int) (*RecurrenceSeries, error)
+	GetSeriesToMaterialize(before time.Time) ([]*RecurrenceSeries, error)
+	UpdateSeries(series *RecurrenceSeries) error
+	DeleteSeries(recurrenceId int) error
+}
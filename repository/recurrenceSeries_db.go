@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type recurrenceSeriesRepositoryDB struct {
+	collection *mongo.Collection
+}
+
+func NewRecurrenceSeriesRepositoryDB(client *mongo.Client, dbName string, collName string) RecurrenceSeriesRepository {
+	collection := client.Database(dbName).Collection(collName)
+
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "recurrenceId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "isComplete", Value: 1}, {Key: "materializedUntil", Value: 1}},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create recurrence series indexes: %v", err)
+	}
+
+	return &recurrenceSeriesRepositoryDB{collection: collection}
+}
+
+func (r *recurrenceSeriesRepositoryDB) InsertSeries(series *RecurrenceSeries) error {
+	ctx := context.Background()
+	result, err := r.collection.InsertOne(ctx, series)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		series.Id = oid.Hex()
+	}
+	return nil
+}
+
+func (r *recurrenceSeriesRepositoryDB) GetSeriesByRecurrenceId(recurrenceId int) (*RecurrenceSeries, error) {
+	ctx := context.Background()
+	var series RecurrenceSeries
+	err := r.collection.FindOne(ctx, bson.M{"recurrenceId": recurrenceId}).Decode(&series)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &series, nil
+}
+
+// GetSeriesToMaterialize lists the open series materialized through a date
+// before the given one.
+func (r *recurrenceSeriesRepositoryDB) GetSeriesToMaterialize(before time.Time) ([]*RecurrenceSeries, error) {
+	ctx := context.Background()
+	var seriesList []*RecurrenceSeries
+
+	filter := bson.M{
+		"isComplete":        false,
+		"materializedUntil": bson.M{"$lt": before},
+	}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var series RecurrenceSeries
+		if err := cursor.Decode(&series); err != nil {
+			return nil, err
+		}
+		seriesList = append(seriesList, &series)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return seriesList, nil
+}
+
+func (r *recurrenceSeriesRepositoryDB) UpdateSeries(series *RecurrenceSeries) error {
+	ctx := context.Background()
+	update := *series
+	update.Id = ""
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"recurrenceId": series.RecurrenceId}, update)
+	return err
+}
+
+func (r *recurrenceSeriesRepositoryDB) DeleteSeries(recurrenceId int) error {
+	ctx := context.Background()
+	_, err := r.collection.DeleteOne(ctx, bson.M{"recurrenceId": recurrenceId})
+	return err
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(value string) time.Time {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
+
+func formatDates(dates []time.Time) []string {
+	values := make([]string, len(dates))
+	for i, date := range dates {
+		values[i] = date.Format("2006-01-02")
+	}
+	return values
+}
+
+func equalDates(got []time.Time, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i, date := range formatDates(got) {
+		if date != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		max   int
+		want  []string
+	}{
+		// 1 January 2026 is a Thursday, so the start itself does not match
+		{"second Tuesday", "FREQ=MONTHLY;BYDAY=2TU", "2026-01-01", 4,
+			[]string{"2026-01-13", "2026-02-10", "2026-03-10", "2026-04-14"}},
+		{"last weekday of the month", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "2026-01-01", 3,
+			[]string{"2026-01-30", "2026-02-27", "2026-03-31"}},
+		{"fourth Thursday of November", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "2026-01-01", 3,
+			[]string{"2026-11-26", "2027-11-25", "2028-11-23"}},
+		{"COUNT stops before max", "FREQ=DAILY;COUNT=3", "2026-03-01", 10,
+			[]string{"2026-03-01", "2026-03-02", "2026-03-03"}},
+		{"UNTIL is inclusive", "FREQ=WEEKLY;UNTIL=20260315", "2026-03-01", 10,
+			[]string{"2026-03-01", "2026-03-08", "2026-03-15"}},
+		{"UNTIL keeps only the date", "FREQ=WEEKLY;UNTIL=20260315T000000Z", "2026-03-01", 10,
+			[]string{"2026-03-01", "2026-03-08", "2026-03-15"}},
+		// Months without a 31st are skipped, not rolled over into the next
+		{"31st of the month", "FREQ=MONTHLY", "2026-01-31", 4,
+			[]string{"2026-01-31", "2026-03-31", "2026-05-31", "2026-07-31"}},
+		{"29 February", "FREQ=YEARLY", "2024-02-29", 2,
+			[]string{"2024-02-29", "2028-02-29"}},
+		{"every other week", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", "2026-03-02", 4,
+			[]string{"2026-03-02", "2026-03-06", "2026-03-16", "2026-03-20"}},
+		{"first and last day of the month", "FREQ=MONTHLY;BYMONTHDAY=1,-1", "2026-01-01", 4,
+			[]string{"2026-01-01", "2026-01-31", "2026-02-01", "2026-02-28"}},
+		{"every third day", "FREQ=DAILY;INTERVAL=3", "2026-02-26", 3,
+			[]string{"2026-02-26", "2026-03-01", "2026-03-04"}},
+		{"daily keyword", "daily", "2026-12-31", 2,
+			[]string{"2026-12-31", "2027-01-01"}},
+		{"weekly keyword", "weekly", "2026-03-04", 2,
+			[]string{"2026-03-04", "2026-03-11"}},
+		{"monthly keyword", "monthly", "2026-01-15", 3,
+			[]string{"2026-01-15", "2026-02-15", "2026-03-15"}},
+		{"yearly keyword", "yearly", "2026-06-01", 2,
+			[]string{"2026-06-01", "2027-06-01"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRecurrence(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rule.Occurrences(day(test.start), test.max)
+			if !equalDates(got, test.want) {
+				t.Errorf("got %v, want %v", formatDates(got), test.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceBetween(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		to    string
+		want  []string
+	}{
+		{"weekly in a later month", "FREQ=WEEKLY;BYDAY=TU", "2026-01-06", "2026-06-01", "2026-06-30",
+			[]string{"2026-06-02", "2026-06-09", "2026-06-16", "2026-06-23", "2026-06-30"}},
+		{"range before the start", "FREQ=MONTHLY;BYMONTHDAY=15", "2026-03-01", "2026-01-01", "2026-04-30",
+			[]string{"2026-03-15", "2026-04-15"}},
+		// COUNT is counted from the start, not from the range
+		{"COUNT ends inside the range", "FREQ=WEEKLY;COUNT=5", "2026-03-02", "2026-03-20", "2026-04-30",
+			[]string{"2026-03-23", "2026-03-30"}},
+		{"UNTIL ends inside the range", "FREQ=DAILY;UNTIL=20260305", "2026-03-01", "2026-03-04", "2026-03-31",
+			[]string{"2026-03-04", "2026-03-05"}},
+		{"interval counted from the start", "FREQ=MONTHLY;INTERVAL=3;BYDAY=-1FR", "2026-01-01", "2026-06-01", "2026-12-31",
+			[]string{"2026-07-31", "2026-10-30"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRecurrence(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rule.Between(day(test.start), day(test.from), day(test.to))
+			if !equalDates(got, test.want) {
+				t.Errorf("got %v, want %v", formatDates(got), test.want)
+			}
+		})
+	}
+}
+
+// Between skips the periods before from, so it has to land on the same
+// dates as expanding the series from its start.
+func TestRecurrenceBetweenMatchesOccurrences(t *testing.T) {
+	rules := []string{
+		"FREQ=DAILY;INTERVAL=5",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+		"FREQ=MONTHLY;BYDAY=2TU",
+		"FREQ=MONTHLY;BYMONTHDAY=31",
+		"FREQ=MONTHLY;INTERVAL=2;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1,-1",
+		"FREQ=YEARLY;BYMONTH=2,11;BYDAY=-1SU",
+		"FREQ=WEEKLY;COUNT=20",
+	}
+	start, from, to := day("2026-01-31"), day("2026-09-10"), day("2027-08-20")
+	for _, value := range rules {
+		t.Run(value, func(t *testing.T) {
+			rule, err := ParseRecurrence(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, date := range rule.Occurrences(start, maxRecurrenceOccurrences) {
+				if !date.Before(from) && !date.After(to) {
+					want = append(want, date.Format("2006-01-02"))
+				}
+			}
+			got := rule.Between(start, from, to)
+			if !equalDates(got, want) {
+				t.Errorf("Between gives %v, Occurrences gives %v", formatDates(got), want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceString(t *testing.T) {
+	tests := []struct {
+		value  string
+		stored string
+		rrule  string
+	}{
+		{"weekly", "weekly", "FREQ=WEEKLY"},
+		{"Monthly", "monthly", "FREQ=MONTHLY"},
+		{"RRULE:freq=monthly;byday=2tu", "FREQ=MONTHLY;BYDAY=2TU", "FREQ=MONTHLY;BYDAY=2TU"},
+		{"BYSETPOS=-1;BYDAY=MO,FR;FREQ=MONTHLY;INTERVAL=2", "FREQ=MONTHLY;INTERVAL=2;BYDAY=MO,FR;BYSETPOS=-1", "FREQ=MONTHLY;INTERVAL=2;BYDAY=MO,FR;BYSETPOS=-1"},
+		{"FREQ=DAILY;UNTIL=20260315T120000Z", "FREQ=DAILY;UNTIL=20260315", "FREQ=DAILY;UNTIL=20260315"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			rule, err := ParseRecurrence(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rule.String() != test.stored || rule.RRule() != test.rrule {
+				t.Errorf("stored as %q with RRULE %q, want %q and %q", rule.String(), rule.RRule(), test.stored, test.rrule)
+			}
+		})
+	}
+}
+
+func TestParseRecurrenceRejects(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"hourly",
+		"FREQ=HOURLY",
+		"INTERVAL=2",
+		"FREQ=DAILY;COUNT=3;UNTIL=20260301",
+		"FREQ=WEEKLY;BYDAY=2TU",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTH=-1",
+		"FREQ=MONTHLY;WKST=SU",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		if _, err := ParseRecurrence(value); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("%q: got %v, want ErrInvalidRecurrence", value, err)
+		}
+	}
+}
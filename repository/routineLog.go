@@ -13,6 +13,8 @@ type RoutineLog struct {
 
 type RoutineLogRepository interface {
 	InsertRoutineLog(RoutineLog *RoutineLog) error
+	GetRoutineLogById(id string) (*RoutineLog, error)
 	GetRoutineLogs(googleId string, date string) ([]*RoutineLog, error)
+	FinishRoutineLog(id string, actualEndTime string, skewness int) (bool, error)
 	DeleteRoutineLog(id string) error
 }
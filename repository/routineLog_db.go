@@ -21,8 +21,33 @@ func NewRoutineLogRepositoryDB(client *mongo.Client, dbName string, collName str
 
 func (r *routineLogRepositoryDB) InsertRoutineLog(routineLog *RoutineLog) error {
 	ctx := context.Background()
-	_, err := r.collection.InsertOne(ctx, routineLog)
-	return err
+	result, err := r.collection.InsertOne(ctx, routineLog)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		routineLog.Id = oid.Hex()
+	}
+	return nil
+}
+
+func (r *routineLogRepositoryDB) GetRoutineLogById(id string) (*RoutineLog, error) {
+	ctx := context.Background()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var routineLog RoutineLog
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&routineLog)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &routineLog, nil
 }
 
 func (r *routineLogRepositoryDB) GetRoutineLogs(googleId string, date string) ([]*RoutineLog, error) {
@@ -62,6 +87,28 @@ func (r *routineLogRepositoryDB) GetRoutineLogs(googleId string, date string) ([
 	return routineLogs, nil
 }
 
+// FinishRoutineLog only updates a log that has no actual end time yet and
+// reports whether it did.
+func (r *routineLogRepositoryDB) FinishRoutineLog(id string, actualEndTime string, skewness int) (bool, error) {
+	ctx := context.Background()
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert ID: %v", err)
+	}
+
+	filter := bson.M{"_id": objectId, "actualEndTime": ""}
+	update := bson.M{"$set": bson.M{
+		"actualEndTime": actualEndTime,
+		"skewness":      skewness,
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to finish routine log: %v", err)
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
 func (r *routineLogRepositoryDB) DeleteRoutineLog(id string) error {
 	ctx := context.Background()
 	objectId, err := primitive.ObjectIDFromHex(id)
@@ -20,8 +20,14 @@ func NewRoutineRepositoryDB(client *mongo.Client, dbName string, collName string
 
 func (r *routineRepositoryDB) InsertRoutine(routine *Routine) error {
 	ctx := context.Background()
-	_, err := r.collection.InsertOne(ctx, routine)
-	return err
+	result, err := r.collection.InsertOne(ctx, routine)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		routine.Id = oid.Hex()
+	}
+	return nil
 }
 
 func (r *routineRepositoryDB) GetAllRoutines(gId string) ([]*Routine, error) {
@@ -61,13 +67,16 @@ func (r *routineRepositoryDB) GetRoutineById(id string) (*Routine, error) {
 
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert ID: %v", err)
+		return nil, nil
 	}
 	filter := bson.M{"_id": objectId}
 
 	routine := &Routine{}
 	err = r.collection.FindOne(ctx, filter).Decode(routine)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return routine, nil
@@ -30,9 +30,22 @@ type Schedule struct {
 	IsTraveling     bool      `bson:"isTraveling"`
 	IsUpdated       bool      `bson:"isUpdated"`
 	TagId           string    `bson:"tag"`
-	
-	Recurrence      string    `bson:"recurrence"`
-	RecurrenceId    int       `bson:"recurrenceId"`
+
+	// The keyword or RRULE the series was created with, kept so it can be
+	// expanded again
+	Recurrence   string `bson:"recurrence"`
+	RecurrenceId int    `bson:"recurrenceId"`
+
+	// The date of the series occurrence a main schedule was materialized for,
+	// which stays put when the occurrence is moved. Exceptions were edited on
+	// their own and keep their times through series edits
+	OccurrenceDate time.Time `bson:"occurrenceDate,omitempty"`
+	IsException    bool      `bson:"isException"`
+
+	// When the schedule runs. Date, StartTime and EndTime repeat them in the
+	// owner's time zone, with Date being the day it starts on
+	StartAt time.Time `bson:"startAt,omitempty"`
+	EndAt   time.Time `bson:"endAt,omitempty"`
 }
 
 type TrafficResponse struct {
@@ -97,12 +110,11 @@ type Precipitation struct {
 }
 
 type ScheduleRepository interface {
-	GetTravelTime(oriLat string, oriLong string, destLat string, destLong string, mode string, depTime string) (string, error)
 	GetTraffic(oriLat float64, oriLong float64, destLat float64, destLong float64) (TrafficResponse, error)
 	GetWeather(oriLat string, oriLong string, destLat string, destLong string, depTime string) (Forecast, error)
 	GetNextGroupId() (int, error)
 	GetNextRecurrenceId() (int, error)
-	CalculateNextRecurrenceDate(currentDate, recurrence string, count int) ([]string, error)
+	CalculateNextRecurrenceDate(startDate time.Time, recurrence string, from time.Time, to time.Time) ([]time.Time, error)
 	BatchInsertSchedules(schedules []Schedule) error
 	InsertSchedule(schedule *Schedule) error
 	GetAllSchedules(gId string, date string) ([]*Schedule, error)
@@ -110,8 +122,12 @@ type ScheduleRepository interface {
 	GetSchedulesByGroupId(groupId int) ([]*Schedule, error)
 	GetMainSchedulesByRecurrenceId(recurrenceId int, date string) ([]*Schedule, error)
 	GetSchedulesByRecurrenceId(recurrenceId int, date string) ([]*Schedule, error)
+	GetMainScheduleByOccurrence(recurrenceId int, occurrenceDate time.Time) (*Schedule, error)
 	UpdateSchedule(id string, schedule *Schedule) error
-	UpdateScheduleTime(id string, startTime string, endTime string) error
+	UpdateScheduleTime(id string, schedule *Schedule) error
+	GetSchedulesWithoutInstants() ([]*Schedule, error)
+	GetSchedulesStartingFrom(gId string, from time.Time) ([]*Schedule, error)
+	SetScheduleInstants(id string, startAt time.Time, endAt time.Time) error
 	DeleteSchedule(groupId int) error
 	DeleteScheduleByRecurrenceId(recurrenceId int, date string) error
 }
@@ -12,12 +12,18 @@ type ScheduleLog struct {
 	DestLongitude float64 `bson:"destLongitude"`
 	Date          time.Time  `bson:"date"`
 	CheckTime     string  `bson:"checkTime"`
+
+	// When the check is due. Date and CheckTime give the same moment in the
+	// user's time zone; logs written before time zones were stored lack it
+	CheckAt time.Time `bson:"checkAt,omitempty"`
 }
 
 type ScheduleLogRepository interface {
 	GetUpcomingSchedules() ([]int, error)
 	InsertScheduleLog(scheduleLog *ScheduleLog) error
 	BatchInsertScheduleLogs(schedules []ScheduleLog) error
+	GetScheduleLogByGroupId(groupId int) (*ScheduleLog, error)
+	UpdateScheduleLog(groupId int, scheduleLog *ScheduleLog) error
 	DeleteScheduleLog(groupId int) error
 	DeleteScheduleLogByRecurrenceId(recurrenceId int) error
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -16,6 +17,14 @@ type scheduleLogRepositoryDB struct {
 
 func NewScheduleLogRepositoryDB(client *mongo.Client, dbName string, collName string) ScheduleLogRepository {
 	collection := client.Database(dbName).Collection(collName)
+
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "checkAt", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Failed to create schedule log index: %v", err)
+	}
+
 	return &scheduleLogRepositoryDB{collection: collection}
 }
 
@@ -23,20 +32,34 @@ func (s *scheduleLogRepositoryDB) GetUpcomingSchedules() ([]int, error) {
 	ctx := context.Background()
 	var groupIds []int
 
-	now := time.Now().UTC().Add(7 * time.Hour)
-	currentTime := now.Format("15:04")
-	minuteLater := now.Add(1 * time.Minute).Format("15:04")
-	today := now.Format("02-01-2006")
+	nowMinute := time.Now().UTC().Truncate(time.Minute)
+
+	// Logs without checkAt were all written for users in Thailand (UTC+7)
+	legacyNow := nowMinute.Add(7 * time.Hour)
+	currentTime := legacyNow.Format("15:04")
+	minuteLater := legacyNow.Add(1 * time.Minute).Format("15:04")
+	today := legacyNow.Format("02-01-2006")
 	parsedDate, err := time.Parse("02-01-2006", today)
 		if err != nil {
 			return nil, fmt.Errorf("invalid date format: %v", err)
 		}
 
 	filter := bson.M{
-		"date": parsedDate,
-		"checkTime": bson.M{
-			"$gte": currentTime,
-			"$lt":  minuteLater,
+		"$or": []bson.M{
+			{
+				"checkAt": bson.M{
+					"$gte": nowMinute,
+					"$lt":  nowMinute.Add(1 * time.Minute),
+				},
+			},
+			{
+				"checkAt": bson.M{"$exists": false},
+				"date":    parsedDate,
+				"checkTime": bson.M{
+					"$gte": currentTime,
+					"$lt":  minuteLater,
+				},
+			},
 		},
 	}
 
@@ -86,6 +109,34 @@ func (r *scheduleLogRepositoryDB) BatchInsertScheduleLogs(schedules []ScheduleLo
 	return nil
 }
 
+func (s *scheduleLogRepositoryDB) GetScheduleLogByGroupId(groupId int) (*ScheduleLog, error) {
+	ctx := context.Background()
+	var scheduleLog ScheduleLog
+	err := s.collection.FindOne(ctx, bson.M{"groupId": groupId}).Decode(&scheduleLog)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &scheduleLog, nil
+}
+
+// UpdateScheduleLog moves the check of groupId to the time and series of
+// scheduleLog; its route is left as it is.
+func (s *scheduleLogRepositoryDB) UpdateScheduleLog(groupId int, scheduleLog *ScheduleLog) error {
+	ctx := context.Background()
+	filter := bson.M{"groupId": groupId}
+	update := bson.M{"$set": bson.M{
+		"recurrenceId": scheduleLog.RecurrenceId,
+		"date":         scheduleLog.Date,
+		"checkTime":    scheduleLog.CheckTime,
+		"checkAt":      scheduleLog.CheckAt,
+	}}
+	_, err := s.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
 func (s *scheduleLogRepositoryDB) DeleteScheduleLog(groupId int) error {
 	ctx := context.Background()
 	filter := bson.M{"groupId": groupId}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"time"
@@ -25,55 +24,6 @@ func NewScheduleRepositoryDB(client *mongo.Client, dbName string, collName strin
 	return &scheduleRepositoryDB{collection: collection}
 }
 
-type DistanceMatrixResponse struct {
-	Rows []struct {
-		Elements []struct {
-			Duration struct {
-				Text  string `json:"text"`
-				Value int    `json:"value"`
-			} `json:"duration"`
-			Status string `json:"status"`
-		} `json:"elements"`
-	} `json:"rows"`
-	Status string `json:"status"`
-}
-
-func (s *scheduleRepositoryDB) GetTravelTime(oriLat string, oriLong string, destLat string, destLong string, mode string, depTime string) (string, error) {
-	godotenv.Load()
-	apiKey := os.Getenv("G_MAP_API_KEY")
-	url := fmt.Sprintf("https://maps.googleapis.com/maps/api/distancematrix/json?origins=%s,%s&destinations=%s,%s&mode=%s&departure_time=%s&key=%s", oriLat, oriLong, destLat, destLong, mode, depTime, apiKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-
-	response, err := client.Get(url)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request to Google API: %v", err)
-	}
-	defer response.Body.Close()
-
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	var matrixResponse DistanceMatrixResponse
-	if err := json.Unmarshal(body, &matrixResponse); err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	if matrixResponse.Status != "OK" || len(matrixResponse.Rows) == 0 || len(matrixResponse.Rows[0].Elements) == 0 {
-		return "", fmt.Errorf("invalid response from Google API: %v", matrixResponse.Status)
-	}
-
-	element := matrixResponse.Rows[0].Elements[0]
-	if element.Status != "OK" {
-		return "", fmt.Errorf("invalid element status: %v", element.Status)
-	}
-
-	// Return the duration text
-	return element.Duration.Text, nil
-}
-
 func (s *scheduleRepositoryDB) GetTraffic(minLat float64, minLong float64, maxLat float64, maxLong float64) (TrafficResponse, error) {
 	godotenv.Load()
 	apiKey := os.Getenv("AZURE_MAP_API_KEY")
@@ -150,31 +100,14 @@ func (s *scheduleRepositoryDB) GetNextRecurrenceId() (int, error) {
 	return counter.Seq, nil
 }
 
-func (s *scheduleRepositoryDB) CalculateNextRecurrenceDate(currentDate, recurrence string, count int) ([]string, error) {
-	layout := "02-01-2006"
-	date, err := time.Parse(layout, currentDate)
+// CalculateNextRecurrenceDate lists the dates of the series starting on
+// startDate that fall between from and to, both inclusive.
+func (s *scheduleRepositoryDB) CalculateNextRecurrenceDate(startDate time.Time, recurrence string, from time.Time, to time.Time) ([]time.Time, error) {
+	rule, err := ParseRecurrence(recurrence)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse start date: %v", err)
-	}
-
-	var dates []string
-	for i := 0; i < count; i++ {
-		var nextDate time.Time
-		switch recurrence {
-		case "daily":
-			nextDate = date.AddDate(0, 0, i)
-		case "weekly":
-			nextDate = date.AddDate(0, 0, i*7)
-		case "monthly":
-			nextDate = date.AddDate(0, i, 0)
-		case "yearly":
-			nextDate = date.AddDate(i, 0, 0)
-		default:
-			return nil, fmt.Errorf("invalid recurrence type: %v", recurrence)
-		}
-		dates = append(dates, nextDate.Format(layout))
+		return nil, err
 	}
-	return dates, nil
+	return rule.Between(startDate, from, to), nil
 }
 
 func (r *scheduleRepositoryDB) BatchInsertSchedules(schedules []Schedule) error {
@@ -195,8 +128,14 @@ func (r *scheduleRepositoryDB) BatchInsertSchedules(schedules []Schedule) error
 
 func (s *scheduleRepositoryDB) InsertSchedule(schedule *Schedule) error {
 	ctx := context.Background()
-	_, err := s.collection.InsertOne(ctx, schedule)
-	return err
+	result, err := s.collection.InsertOne(ctx, schedule)
+	if err != nil {
+		return err
+	}
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		schedule.Id = oid.Hex()
+	}
+	return nil
 }
 
 func (s *scheduleRepositoryDB) GetAllSchedules(gId string, date string) ([]*Schedule, error) {
@@ -214,6 +153,7 @@ func (s *scheduleRepositoryDB) GetAllSchedules(gId string, date string) ([]*Sche
 
 	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.D{
 		{Key: "date", Value: 1},
+		{Key: "startAt", Value: 1},
 		{Key: "startTime", Value: 1},
 	}))
 	if err != nil {
@@ -242,12 +182,15 @@ func (s *scheduleRepositoryDB) GetScheduleById(id string) (*Schedule, error) {
 
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert ID: %v", err)
+		return nil, nil
 	}
 	filter := bson.M{"_id": objectId}
 
 	err = s.collection.FindOne(ctx, filter).Decode(&schedule)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to retrieve schedule: %v", err)
 	}
 
@@ -260,7 +203,9 @@ func (s *scheduleRepositoryDB) GetSchedulesByGroupId(groupId int) ([]*Schedule,
 
 	filter := bson.M{"groupId": groupId}
 
+	// Latest first, so the main schedule leads the entries before it
 	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.D{
+		{Key: "startAt", Value: -1},
 		{Key: "date", Value: 1},
 		{Key: "startTime", Value: -1},
 	}))
@@ -284,6 +229,35 @@ func (s *scheduleRepositoryDB) GetSchedulesByGroupId(groupId int) ([]*Schedule,
 	return schedules, nil
 }
 
+// fromOccurrence matches main schedules whose series occurrence is on or
+// after date, wherever the occurrence was moved to. Schedules stored before
+// occurrenceDate existed fall back to their date.
+func fromOccurrence(date time.Time) bson.A {
+	return bson.A{
+		bson.M{"occurrenceDate": bson.M{"$gte": date}},
+		bson.M{"occurrenceDate": bson.M{"$exists": false}, "date": bson.M{"$gte": date}},
+	}
+}
+
+// groupIdsFromOccurrence lists the groups of the series recurrenceId whose
+// occurrence is on or after date. Travel legs and routines carry no
+// occurrence of their own, so they are selected along with their group.
+func (s *scheduleRepositoryDB) groupIdsFromOccurrence(ctx context.Context, recurrenceId int, date time.Time) ([]interface{}, error) {
+	groupIds, err := s.collection.Distinct(ctx, "groupId", bson.M{
+		"recurrenceId": recurrenceId,
+		"recurrence":   bson.M{"$ne": ""},
+		"$or":          fromOccurrence(date),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule groups: %v", err)
+	}
+	// A nil slice would be sent as null, which $in rejects
+	if groupIds == nil {
+		groupIds = []interface{}{}
+	}
+	return groupIds, nil
+}
+
 func (s *scheduleRepositoryDB) GetMainSchedulesByRecurrenceId(recurrenceId int, date string) ([]*Schedule, error) {
 	ctx := context.Background()
 	var schedules []*Schedule
@@ -297,11 +271,12 @@ func (s *scheduleRepositoryDB) GetMainSchedulesByRecurrenceId(recurrenceId int,
 		if err != nil {
 			return nil, fmt.Errorf("invalid date format: %v", err)
 		}
-		filter["date"] = bson.M{"$gte": parsedDate}
+		filter["$or"] = fromOccurrence(parsedDate)
 	}
 
 	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.D{
 		{Key: "date", Value: 1},
+		{Key: "startAt", Value: 1},
 		{Key: "startTime", Value: 1},
 	}))
 	if err != nil {
@@ -336,11 +311,16 @@ func (s *scheduleRepositoryDB) GetSchedulesByRecurrenceId(recurrenceId int, date
 		if err != nil {
 			return nil, fmt.Errorf("invalid date format: %v", err)
 		}
-		filter["date"] = bson.M{"$gte": parsedDate}
+		groupIds, err := s.groupIdsFromOccurrence(ctx, recurrenceId, parsedDate)
+		if err != nil {
+			return nil, err
+		}
+		filter["groupId"] = bson.M{"$in": groupIds}
 	}
 
 	cursor, err := s.collection.Find(ctx, filter, options.Find().SetSort(bson.D{
 		{Key: "date", Value: 1},
+		{Key: "startAt", Value: 1},
 		{Key: "startTime", Value: 1},
 	}))
 	if err != nil {
@@ -363,6 +343,31 @@ func (s *scheduleRepositoryDB) GetSchedulesByRecurrenceId(recurrenceId int, date
 	return schedules, nil
 }
 
+// GetMainScheduleByOccurrence finds the main schedule materialized for the
+// series occurrence on occurrenceDate. Schedules from before occurrence dates
+// were stored are matched on their date.
+func (s *scheduleRepositoryDB) GetMainScheduleByOccurrence(recurrenceId int, occurrenceDate time.Time) (*Schedule, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"recurrenceId": recurrenceId,
+		"recurrence":   bson.M{"$ne": ""},
+		"$or": bson.A{
+			bson.M{"occurrenceDate": occurrenceDate},
+			bson.M{"occurrenceDate": bson.M{"$exists": false}, "date": occurrenceDate},
+		},
+	}
+
+	var schedule Schedule
+	err := s.collection.FindOne(ctx, filter).Decode(&schedule)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
 func (s *scheduleRepositoryDB) UpdateSchedule(id string, schedule *Schedule) error {
 	ctx := context.Background()
 
@@ -372,7 +377,7 @@ func (s *scheduleRepositoryDB) UpdateSchedule(id string, schedule *Schedule) err
 	}
 	filter := bson.M{"_id": (objectId)}
 
-	update := bson.M{"$set": bson.M{
+	set := bson.M{
 		"googleId":        schedule.GoogleId,
 		"routineId":       schedule.RoutineId,
 		"name":            schedule.Name,
@@ -395,14 +400,21 @@ func (s *scheduleRepositoryDB) UpdateSchedule(id string, schedule *Schedule) err
 		"tagId":           schedule.TagId,
 		"recurrence":      schedule.Recurrence,
 		"recurrenceId":    schedule.RecurrenceId,
-	},
+		"isException":     schedule.IsException,
+		"startAt":         schedule.StartAt,
+	}
+	if !schedule.OccurrenceDate.IsZero() {
+		set["occurrenceDate"] = schedule.OccurrenceDate
 	}
+	update := withEndAt(set, schedule.EndAt)
 
 	_, err = s.collection.UpdateOne(ctx, filter, update)
 	return err
 }
 
-func (s *scheduleRepositoryDB) UpdateScheduleTime(id string, startTime string, endTime string) error {
+// UpdateScheduleTime moves a schedule to the times set on schedule and marks
+// it as updated.
+func (s *scheduleRepositoryDB) UpdateScheduleTime(id string, schedule *Schedule) error {
 	ctx := context.Background()
 
 	objectId, err := primitive.ObjectIDFromHex(id)
@@ -414,12 +426,13 @@ func (s *scheduleRepositoryDB) UpdateScheduleTime(id string, startTime string, e
 		"_id": (objectId),
 	}
 
-	update := bson.M{"$set": bson.M{
-		"startTime": startTime,
-		"endTime":   endTime,
+	update := withEndAt(bson.M{
+		"date":      schedule.Date,
+		"startTime": schedule.StartTime,
+		"endTime":   schedule.EndTime,
+		"startAt":   schedule.StartAt,
 		"isUpdated": true,
-	},
-	}
+	}, schedule.EndAt)
 
 	_, err = s.collection.UpdateOne(ctx, filter, update)
 	return err
@@ -441,9 +454,71 @@ func (s *scheduleRepositoryDB) DeleteScheduleByRecurrenceId(recurrenceId int, da
 		if err != nil {
 			return fmt.Errorf("invalid date format: %v", err)
 		}
-		filter["date"] = bson.M{"$gte": parsedDate}
+		groupIds, err := s.groupIdsFromOccurrence(ctx, recurrenceId, parsedDate)
+		if err != nil {
+			return err
+		}
+		filter["groupId"] = bson.M{"$in": groupIds}
 	}
 
 	_, err := s.collection.DeleteMany(ctx, filter)
 	return err
 }
+
+func (s *scheduleRepositoryDB) GetSchedulesWithoutInstants() ([]*Schedule, error) {
+	ctx := context.Background()
+	var schedules []*Schedule
+
+	// The groupId and recurrenceId counters share the collection; only
+	// schedules have an owner
+	cursor, err := s.collection.Find(ctx, bson.M{"startAt": bson.M{"$exists": false}, "googleId": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (s *scheduleRepositoryDB) GetSchedulesStartingFrom(gId string, from time.Time) ([]*Schedule, error) {
+	ctx := context.Background()
+	var schedules []*Schedule
+
+	cursor, err := s.collection.Find(ctx, bson.M{"googleId": gId, "startAt": bson.M{"$gte": from}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (s *scheduleRepositoryDB) SetScheduleInstants(id string, startAt time.Time, endAt time.Time) error {
+	ctx := context.Background()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to convert ID: %v", err)
+	}
+
+	_, err = s.collection.UpdateOne(ctx, bson.M{"_id": objectId}, withEndAt(bson.M{"startAt": startAt}, endAt))
+	return err
+}
+
+// withEndAt builds an update setting fields and endAt, or removing endAt for
+// schedules without an end.
+func withEndAt(set bson.M, endAt time.Time) bson.M {
+	if endAt.IsZero() {
+		return bson.M{"$set": set, "$unset": bson.M{"endAt": ""}}
+	}
+	set["endAt"] = endAt
+	return bson.M{"$set": set}
+}
@@ -0,0 +1,28 @@
+package repository
+
+import "time"
+
+// Session is one refresh-token family. Every refresh rotates RefreshJti, so
+// a refresh token whose jti no longer matches has already been used.
+type Session struct {
+	Id         string    `bson:"_id"`
+	GoogleId   string    `bson:"googleId"`
+	RefreshJti string    `bson:"refreshJti"`
+	DeviceName string    `bson:"deviceName"`
+	UserAgent  string    `bson:"userAgent"`
+	IpAddress  string    `bson:"ipAddress"`
+	IsRevoked  bool      `bson:"isRevoked"`
+	CreatedAt  time.Time `bson:"createdAt"`
+	LastUsedAt time.Time `bson:"lastUsedAt"`
+	ExpiresAt  time.Time `bson:"expiresAt"`
+}
+
+type SessionRepository interface {
+	InsertSession(session *Session) error
+	GetSessionById(id string) (*Session, error)
+	GetActiveSessions(googleId string) ([]*Session, error)
+	RotateRefreshToken(id string, oldJti string, newJti string, usedAt time.Time, expiresAt time.Time) (bool, error)
+	TouchSession(id string, usedAt time.Time) error
+	RevokeSession(id string) error
+	RevokeAllSessions(googleId string) error
+}
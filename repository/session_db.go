@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type sessionRepositoryDB struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepositoryDB(client *mongo.Client, dbName string, collName string) SessionRepository {
+	collection := client.Database(dbName).Collection(collName)
+
+	// Let MongoDB drop sessions once their refresh token can no longer be used
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create session expiry index: %v", err)
+	}
+
+	return &sessionRepositoryDB{collection: collection}
+}
+
+func (r *sessionRepositoryDB) InsertSession(session *Session) error {
+	ctx := context.Background()
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *sessionRepositoryDB) GetSessionById(id string) (*Session, error) {
+	ctx := context.Background()
+	var session Session
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepositoryDB) GetActiveSessions(googleId string) ([]*Session, error) {
+	ctx := context.Background()
+	var sessions []*Session
+
+	filter := bson.M{
+		"googleId":  googleId,
+		"isRevoked": false,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "lastUsedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var session Session
+		if err := cursor.Decode(&session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// RotateRefreshToken swaps the family's current refresh jti only if oldJti is
+// still current, so two concurrent refreshes with the same token cannot both win.
+func (r *sessionRepositoryDB) RotateRefreshToken(id string, oldJti string, newJti string, usedAt time.Time, expiresAt time.Time) (bool, error) {
+	ctx := context.Background()
+	filter := bson.M{"_id": id, "refreshJti": oldJti, "isRevoked": false}
+	update := bson.M{"$set": bson.M{
+		"refreshJti": newJti,
+		"lastUsedAt": usedAt,
+		"expiresAt":  expiresAt,
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *sessionRepositoryDB) TouchSession(id string, usedAt time.Time) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lastUsedAt": usedAt}})
+	return err
+}
+
+func (r *sessionRepositoryDB) RevokeSession(id string) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"isRevoked": true}})
+	return err
+}
+
+func (r *sessionRepositoryDB) RevokeAllSessions(googleId string) error {
+	ctx := context.Background()
+	_, err := r.collection.UpdateMany(ctx, bson.M{"googleId": googleId}, bson.M{"$set": bson.M{"isRevoked": true}})
+	return err
+}
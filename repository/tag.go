@@ -10,6 +10,7 @@ type Tag struct {
 type TagRepository interface {
 	InsertTag(tag *Tag) error
 	GetAllTags(gId string) ([]*Tag, error)
+	GetTagById(id string) (*Tag, error)
 	GetRoutinesByTagId(id string) ([]string, error)
 	GetTagByRoutineId(string) (*Tag, error)
 	UpdateTag(id string, tag *Tag) error
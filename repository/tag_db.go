@@ -56,6 +56,25 @@ func (t *tagRepositoryDB) GetAllTags(gId string) ([]*Tag, error) {
 	return tags, nil
 }
 
+func (t *tagRepositoryDB) GetTagById(id string) (*Tag, error) {
+	ctx := context.Background()
+
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var tag Tag
+	err = t.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&tag)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tag, nil
+}
+
 func (t *tagRepositoryDB) GetRoutinesByTagId(id string) ([]string, error) {
 	ctx := context.Background()
 
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+)
+
+// TravelObservation pairs what was predicted for one travel schedule with how
+// long the trip actually took, once the user reports their arrival.
+type TravelObservation struct {
+	Id               string    `bson:"_id,omitempty"`
+	GoogleId         string    `bson:"googleId"`
+	ScheduleId       string    `bson:"scheduleId"`
+	GroupId          int       `bson:"groupId"`
+	RouteKey         string    `bson:"routeKey"`
+	Transportation   string    `bson:"transportation"`
+	Date             time.Time `bson:"date"`
+	Provider         string    `bson:"provider"`
+	Adjuster         string    `bson:"adjuster"`
+	ProviderSeconds  int       `bson:"providerSeconds"`
+	AdjustedSeconds  int       `bson:"adjustedSeconds"`
+	Correction       float64   `bson:"correction"`
+	PredictedSeconds int       `bson:"predictedSeconds"`
+	ActualSeconds    int       `bson:"actualSeconds,omitempty"`
+	CreatedAt        time.Time `bson:"createdAt"`
+	ReportedAt       time.Time `bson:"reportedAt,omitempty"`
+}
+
+type TravelObservationRepository interface {
+	SaveObservation(observation *TravelObservation) error
+	GetObservationByScheduleId(scheduleId string) (*TravelObservation, error)
+	ReportArrival(scheduleId string, actualSeconds int, reportedAt time.Time) (bool, error)
+	GetReportedObservations(googleId string, routeKey string, limit int64) ([]*TravelObservation, error)
+	GetReportedObservationsSince(googleId string, since time.Time) ([]*TravelObservation, error)
+}
+
+// TravelRouteKey identifies a route by its rounded end points, so trips
+// between the same two places share their history.
+func TravelRouteKey(oriLat float64, oriLong float64, destLat float64, destLong float64, mode string) string {
+	return fmt.Sprintf("%s:%s,%s:%s,%s", mode, roundCoordinate(oriLat), roundCoordinate(oriLong), roundCoordinate(destLat), roundCoordinate(destLong))
+}
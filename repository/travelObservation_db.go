@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type travelObservationRepositoryDB struct {
+	collection *mongo.Collection
+}
+
+func NewTravelObservationRepositoryDB(client *mongo.Client, dbName string, collName string) TravelObservationRepository {
+	collection := client.Database(dbName).Collection(collName)
+
+	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "scheduleId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "googleId", Value: 1}, {Key: "routeKey", Value: 1}, {Key: "reportedAt", Value: -1}},
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create travel observation indexes: %v", err)
+	}
+
+	return &travelObservationRepositoryDB{collection: collection}
+}
+
+// SaveObservation replaces the prediction stored for the observation's
+// schedule. Once an arrival has been reported the observation is left as is.
+func (r *travelObservationRepositoryDB) SaveObservation(observation *TravelObservation) error {
+	ctx := context.Background()
+	filter := bson.M{"scheduleId": observation.ScheduleId, "actualSeconds": bson.M{"$exists": false}}
+	update := bson.M{
+		"$set": bson.M{
+			"googleId":         observation.GoogleId,
+			"groupId":          observation.GroupId,
+			"routeKey":         observation.RouteKey,
+			"transportation":   observation.Transportation,
+			"date":             observation.Date,
+			"provider":         observation.Provider,
+			"adjuster":         observation.Adjuster,
+			"providerSeconds":  observation.ProviderSeconds,
+			"adjustedSeconds":  observation.AdjustedSeconds,
+			"correction":       observation.Correction,
+			"predictedSeconds": observation.PredictedSeconds,
+		},
+		"$setOnInsert": bson.M{"createdAt": observation.CreatedAt},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Already reported
+		return nil
+	}
+	return err
+}
+
+func (r *travelObservationRepositoryDB) GetObservationByScheduleId(scheduleId string) (*TravelObservation, error) {
+	var observation TravelObservation
+	ctx := context.Background()
+	err := r.collection.FindOne(ctx, bson.M{"scheduleId": scheduleId}).Decode(&observation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get travel observation: %v", err)
+	}
+
+	return &observation, nil
+}
+
+// ReportArrival reports false when the arrival for scheduleId was already
+// reported or nothing was predicted for it.
+func (r *travelObservationRepositoryDB) ReportArrival(scheduleId string, actualSeconds int, reportedAt time.Time) (bool, error) {
+	ctx := context.Background()
+	filter := bson.M{"scheduleId": scheduleId, "actualSeconds": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"actualSeconds": actualSeconds, "reportedAt": reportedAt}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, fmt.Errorf("failed to report arrival: %v", err)
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *travelObservationRepositoryDB) GetReportedObservations(googleId string, routeKey string, limit int64) ([]*TravelObservation, error) {
+	filter := bson.M{"googleId": googleId, "routeKey": routeKey, "actualSeconds": bson.M{"$exists": true}}
+	opts := options.Find().SetSort(bson.D{{Key: "reportedAt", Value: -1}}).SetLimit(limit)
+	return r.find(filter, opts)
+}
+
+func (r *travelObservationRepositoryDB) GetReportedObservationsSince(googleId string, since time.Time) ([]*TravelObservation, error) {
+	filter := bson.M{"googleId": googleId, "actualSeconds": bson.M{"$exists": true}, "date": bson.M{"$gte": since}}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	return r.find(filter, opts)
+}
+
+func (r *travelObservationRepositoryDB) find(filter bson.M, opts *options.FindOptions) ([]*TravelObservation, error) {
+	var observations []*TravelObservation
+	ctx := context.Background()
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get travel observations: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &observations); err != nil {
+		return nil, fmt.Errorf("failed to decode travel observations: %v", err)
+	}
+
+	return observations, nil
+}
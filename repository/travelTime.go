@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var ErrTravelModeNotSupported = errors.New("travel mode not supported")
+
+// TravelTime is a single route estimate. Distance is in meters.
+type TravelTime struct {
+	Duration time.Duration
+	Distance float64
+	Mode     string
+	Provider string
+}
+
+// TravelTimeProvider estimates how long it takes to go from the origin to the
+// destination with mode ("driving", "walking", "bicycling" or "transit").
+// A zero departAt means leaving now.
+type TravelTimeProvider interface {
+	GetTravelTime(oriLat float64, oriLong float64, destLat float64, destLong float64, mode string, departAt time.Time) (*TravelTime, error)
+}
+
+// NewTravelTimeProvider builds the provider named by name:
+//   - "google" (default): Google Distance Matrix, using G_MAP_API_KEY
+//   - "osrm": an OSRM-compatible router at OSRM_URL, falling back to the
+//     estimator for modes the router has no profile for
+//   - "estimate": the offline haversine estimator
+func NewTravelTimeProvider(name string) (TravelTimeProvider, error) {
+	switch name {
+	case "", "google":
+		apiKey := os.Getenv("G_MAP_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("G_MAP_API_KEY must be set for the google travel time provider")
+		}
+		return NewGoogleTravelTimeProvider(apiKey), nil
+	case "osrm":
+		baseURL := os.Getenv("OSRM_URL")
+		if baseURL == "" {
+			return nil, fmt.Errorf("OSRM_URL must be set for the osrm travel time provider")
+		}
+		return NewOSRMTravelTimeProvider(baseURL, NewEstimateTravelTimeProvider()), nil
+	case "estimate":
+		return NewEstimateTravelTimeProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown travel time provider: %q", name)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	earthRadius = 6371000.0
+
+	// Roads are rarely straight; scale the great-circle distance to a route length
+	detourFactor = 1.3
+)
+
+type travelSpeed struct {
+	// Average speed in km/h
+	speed float64
+	// Fixed time on top of moving, e.g. waiting for transit or parking
+	overhead time.Duration
+}
+
+var travelSpeeds = map[string]travelSpeed{
+	"driving":   {speed: 30, overhead: 5 * time.Minute},
+	"transit":   {speed: 20, overhead: 10 * time.Minute},
+	"bicycling": {speed: 14},
+	"walking":   {speed: 4.8},
+}
+
+// estimateTravelTimeProvider needs no network: it scales the haversine
+// distance by a detour factor and divides by an average speed per mode. The
+// same input always gives the same estimate.
+type estimateTravelTimeProvider struct{}
+
+func NewEstimateTravelTimeProvider() TravelTimeProvider {
+	return &estimateTravelTimeProvider{}
+}
+
+func (p *estimateTravelTimeProvider) GetTravelTime(oriLat float64, oriLong float64, destLat float64, destLong float64, mode string, departAt time.Time) (*TravelTime, error) {
+	speed, ok := travelSpeeds[mode]
+	if !ok {
+		return nil, ErrTravelModeNotSupported
+	}
+
+	distance := haversine(oriLat, oriLong, destLat, destLong) * detourFactor
+	moving := time.Duration(distance / (speed.speed * 1000 / 3600) * float64(time.Second))
+
+	return &TravelTime{
+		Duration: (moving + speed.overhead).Round(time.Second),
+		Distance: math.Round(distance),
+		Mode:     mode,
+		Provider: "estimate",
+	}, nil
+}
+
+// haversine returns the great-circle distance between two points in meters.
+func haversine(lat1 float64, long1 float64, lat2 float64, long2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLong := toRadians(long2 - long1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
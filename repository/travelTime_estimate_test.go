@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 0.1 degrees of longitude on the equator is 11,119.5 m, or 14,455 m of road
+// after the detour factor.
+const (
+	equatorLat     = 0.0
+	equatorLong    = 0.0
+	equatorEndLong = 0.1
+	equatorRoute   = 14455.0
+)
+
+func TestEstimateTravelTime(t *testing.T) {
+	provider := NewEstimateTravelTimeProvider()
+
+	tests := []struct {
+		mode     string
+		duration time.Duration
+	}{
+		// 14,455 m at 30 km/h is 28m55s, plus 5 minutes of parking
+		{"driving", 33*time.Minute + 55*time.Second},
+		// 43m22s at 20 km/h, plus 10 minutes of waiting
+		{"transit", 53*time.Minute + 22*time.Second},
+		{"bicycling", time.Hour + time.Minute + 57*time.Second},
+		{"walking", 3*time.Hour + 42*time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			travelTime, err := provider.GetTravelTime(equatorLat, equatorLong, equatorLat, equatorEndLong, test.mode, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if travelTime.Distance != equatorRoute {
+				t.Errorf("distance %v, want %v", travelTime.Distance, equatorRoute)
+			}
+			if travelTime.Duration != test.duration {
+				t.Errorf("duration %v, want %v", travelTime.Duration, test.duration)
+			}
+			if travelTime.Mode != test.mode || travelTime.Provider != "estimate" {
+				t.Errorf("got mode %q provider %q", travelTime.Mode, travelTime.Provider)
+			}
+		})
+	}
+}
+
+func TestEstimateTravelTimeSamePlace(t *testing.T) {
+	travelTime, err := NewEstimateTravelTimeProvider().GetTravelTime(13.7563, 100.5018, 13.7563, 100.5018, "transit", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if travelTime.Distance != 0 || travelTime.Duration != 10*time.Minute {
+		t.Errorf("got %v over %v m, want only the transit overhead", travelTime.Duration, travelTime.Distance)
+	}
+}
+
+func TestEstimateTravelTimeUnsupportedMode(t *testing.T) {
+	for _, mode := range []string{"", "flying", "DRIVING"} {
+		_, err := NewEstimateTravelTimeProvider().GetTravelTime(equatorLat, equatorLong, equatorLat, equatorEndLong, mode, time.Time{})
+		if !errors.Is(err, ErrTravelModeNotSupported) {
+			t.Errorf("mode %q: got %v, want ErrTravelModeNotSupported", mode, err)
+		}
+	}
+}
+
+func TestOSRMTravelTime(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"code":"Ok","routes":[{"duration":1234.5,"distance":15000}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOSRMTravelTimeProvider(server.URL+"/", NewEstimateTravelTimeProvider())
+
+	tests := []struct {
+		mode     string
+		provider string
+		duration time.Duration
+		distance float64
+		path     string
+	}{
+		{"driving", "osrm", 1234500 * time.Millisecond, 15000, "/route/v1/driving/"},
+		{"walking", "osrm", 1234500 * time.Millisecond, 15000, "/route/v1/foot/"},
+		{"bicycling", "osrm", 1234500 * time.Millisecond, 15000, "/route/v1/bike/"},
+		// OSRM has no transit profile, so the estimator answers without a request
+		{"transit", "estimate", 53*time.Minute + 22*time.Second, equatorRoute, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			paths = nil
+			travelTime, err := provider.GetTravelTime(equatorLat, equatorLong, equatorLat, equatorEndLong, test.mode, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if travelTime.Provider != test.provider || travelTime.Duration != test.duration || travelTime.Distance != test.distance {
+				t.Errorf("got %+v", travelTime)
+			}
+
+			if test.path == "" {
+				if len(paths) != 0 {
+					t.Errorf("router was queried for %s: %v", test.mode, paths)
+				}
+				return
+			}
+			if len(paths) != 1 || !strings.HasPrefix(paths[0], test.path) {
+				t.Errorf("router queried at %v, want %s...", paths, test.path)
+			}
+		})
+	}
+}
+
+func TestOSRMTravelTimeWithoutFallback(t *testing.T) {
+	provider := NewOSRMTravelTimeProvider("http://127.0.0.1:0", nil)
+	_, err := provider.GetTravelTime(equatorLat, equatorLong, equatorLat, equatorEndLong, "transit", time.Time{})
+	if !errors.Is(err, ErrTravelModeNotSupported) {
+		t.Errorf("got %v, want ErrTravelModeNotSupported", err)
+	}
+}
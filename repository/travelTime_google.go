@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const googleDistanceMatrixURL = "https://maps.googleapis.com/maps/api/distancematrix/json"
+
+type DistanceMatrixResponse struct {
+	Rows []struct {
+		Elements []struct {
+			Duration struct {
+				Text  string `json:"text"`
+				Value int    `json:"value"`
+			} `json:"duration"`
+			Distance struct {
+				Text  string `json:"text"`
+				Value int    `json:"value"`
+			} `json:"distance"`
+			Status string `json:"status"`
+		} `json:"elements"`
+	} `json:"rows"`
+	Status string `json:"status"`
+}
+
+type googleTravelTimeProvider struct {
+	apiKey string
+	client *http.Client
+}
+
+func NewGoogleTravelTimeProvider(apiKey string) TravelTimeProvider {
+	return &googleTravelTimeProvider{apiKey: apiKey, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (p *googleTravelTimeProvider) GetTravelTime(oriLat float64, oriLong float64, destLat float64, destLong float64, mode string, departAt time.Time) (*TravelTime, error) {
+	departureTime := "now"
+	if !departAt.IsZero() && departAt.After(time.Now()) {
+		departureTime = strconv.FormatInt(departAt.Unix(), 10)
+	}
+
+	query := url.Values{}
+	query.Set("origins", fmt.Sprintf("%f,%f", oriLat, oriLong))
+	query.Set("destinations", fmt.Sprintf("%f,%f", destLat, destLong))
+	query.Set("mode", mode)
+	query.Set("departure_time", departureTime)
+	query.Set("key", p.apiKey)
+
+	response, err := p.client.Get(googleDistanceMatrixURL + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to Google API: %v", err)
+	}
+	defer response.Body.Close()
+
+	var matrixResponse DistanceMatrixResponse
+	if err := json.NewDecoder(response.Body).Decode(&matrixResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if matrixResponse.Status != "OK" || len(matrixResponse.Rows) == 0 || len(matrixResponse.Rows[0].Elements) == 0 {
+		return nil, fmt.Errorf("invalid response from Google API: %v", matrixResponse.Status)
+	}
+
+	element := matrixResponse.Rows[0].Elements[0]
+	if element.Status != "OK" {
+		return nil, fmt.Errorf("invalid element status: %v", element.Status)
+	}
+
+	return &TravelTime{
+		Duration: time.Duration(element.Duration.Value) * time.Second,
+		Distance: float64(element.Distance.Value),
+		Mode:     mode,
+		Provider: "google",
+	}, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OSRM profiles for each travel mode. Transit has none.
+var osrmProfiles = map[string]string{
+	"driving":   "driving",
+	"walking":   "foot",
+	"bicycling": "bike",
+}
+
+type osrmRouteResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Routes  []struct {
+		Duration float64 `json:"duration"`
+		Distance float64 `json:"distance"`
+	} `json:"routes"`
+}
+
+// osrmTravelTimeProvider queries the route service of an OSRM-compatible
+// router, such as OSRM itself or Valhalla's OSRM endpoint. Routers do not
+// model departure times, so departAt is ignored.
+type osrmTravelTimeProvider struct {
+	baseURL  string
+	client   *http.Client
+	fallback TravelTimeProvider
+}
+
+// NewOSRMTravelTimeProvider answers modes without an OSRM profile with
+// fallback; a nil fallback reports ErrTravelModeNotSupported instead.
+func NewOSRMTravelTimeProvider(baseURL string, fallback TravelTimeProvider) TravelTimeProvider {
+	return &osrmTravelTimeProvider{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		fallback: fallback,
+	}
+}
+
+func (p *osrmTravelTimeProvider) GetTravelTime(oriLat float64, oriLong float64, destLat float64, destLong float64, mode string, departAt time.Time) (*TravelTime, error) {
+	profile, ok := osrmProfiles[mode]
+	if !ok {
+		if p.fallback == nil {
+			return nil, ErrTravelModeNotSupported
+		}
+		return p.fallback.GetTravelTime(oriLat, oriLong, destLat, destLong, mode, departAt)
+	}
+
+	// OSRM takes coordinates as longitude,latitude
+	url := fmt.Sprintf("%s/route/v1/%s/%f,%f;%f,%f?overview=false", p.baseURL, profile, oriLong, oriLat, destLong, destLat)
+
+	response, err := p.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request to OSRM: %v", err)
+	}
+	defer response.Body.Close()
+
+	var routeResponse osrmRouteResponse
+	if err := json.NewDecoder(response.Body).Decode(&routeResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if routeResponse.Code != "Ok" || len(routeResponse.Routes) == 0 {
+		return nil, fmt.Errorf("invalid response from OSRM: %s %s", routeResponse.Code, routeResponse.Message)
+	}
+
+	route := routeResponse.Routes[0]
+	return &TravelTime{
+		Duration: time.Duration(route.Duration * float64(time.Second)),
+		Distance: route.Distance,
+		Mode:     mode,
+		Provider: "osrm",
+	}, nil
+}
@@ -1,10 +1,17 @@
 package repository
 
+// DefaultTimeZone applies to users who have not set a time zone; every user
+// was in Thailand before time zones were stored.
+const DefaultTimeZone = "Asia/Bangkok"
+
 type User struct {
 	GoogleId string `bson:"googleId"`
 	Email    string `bson:"email"`
 	Name     string `bson:"name"`
 	Image    string `bson:"image"`
+	TimeZone string `bson:"timeZone,omitempty"`
+	// ISO 3166 code of the country whose public holidays apply
+	Country string `bson:"country,omitempty"`
 }
 
 type UserRepository interface {
@@ -12,4 +19,5 @@ type UserRepository interface {
 	GetUserInfo(string) (*User, error)
 	UpdateUser(string, *User) error
 	GetAllUsersId() ([]string, error)
+	GetAllUsers() ([]*User, error)
 }
@@ -38,13 +38,17 @@ func (r userRepositoryDB) GetUserInfo(gId string) (*User, error) {
 func (r userRepositoryDB) UpdateUser(gId string, user *User) error {
 	ctx := context.Background()
 	filter := bson.M{"googleId": gId}
-	update := bson.M{
-		"$set": bson.M{
-			"name":  user.Name,
-			"image": user.Image,
-		},
+	set := bson.M{
+		"name":  user.Name,
+		"image": user.Image,
 	}
-	_, err := r.collection.UpdateOne(ctx, filter, update)
+	if user.TimeZone != "" {
+		set["timeZone"] = user.TimeZone
+	}
+	if user.Country != "" {
+		set["country"] = user.Country
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
 	return err
 }
 
@@ -65,4 +69,18 @@ func (r userRepositoryDB) GetAllUsersId() ([]string, error) {
 		users = append(users, user.GoogleId)
 	}
 	return users, nil
+}
+
+func (r userRepositoryDB) GetAllUsers() ([]*User, error) {
+	ctx := context.Background()
+	var users []*User
+	cursor, err := r.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
 }
\ No newline at end of file
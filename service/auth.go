@@ -1,9 +1,16 @@
 package service
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type LoginInput struct {
-	GoogleId string `bson:"googleId"`
+	IdToken    string `bson:"idToken"`
+	DeviceName string `bson:"deviceName"`
+	UserAgent  string `bson:"userAgent"`
+	IpAddress  string `bson:"ipAddress"`
 }
 
 type LoginResponse struct {
@@ -13,8 +20,31 @@ type LoginResponse struct {
 	RefreshTokenExpired string `json:"refreshExpired,omitempty"`
 }
 
+// GoogleIdentity is the user profile taken from a verified Google ID token.
+type GoogleIdentity struct {
+	GoogleId string `bson:"googleId"`
+	Email    string `bson:"email"`
+	Name     string `bson:"name"`
+	Image    string `bson:"image"`
+}
+
+type SessionResponse struct {
+	Id         string    `json:"id"`
+	DeviceName string    `json:"deviceName"`
+	UserAgent  string    `json:"userAgent"`
+	IpAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	IsCurrent  bool      `json:"isCurrent"`
+}
+
 type AuthService interface {
 	Login(loginInput *LoginInput) (LoginResponse, error)
 	RefreshToken(refreshToken string) (LoginResponse, error)
 	ValidateAccessToken(accessToken string) (jwt.MapClaims, error)
+	VerifyIdToken(idToken string) (*GoogleIdentity, error)
+	Logout(sessionId string) error
+	LogoutAll(googleId string) error
+	GetSessions(googleId string, currentSessionId string) ([]*SessionResponse, error)
+	RevokeSession(googleId string, sessionId string) error
 }
@@ -1,106 +1,367 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"etalert-backend/keyring"
 	"etalert-backend/repository"
-	"os"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type authService struct {
-	userRepository repository.UserRepository
+	userRepository    repository.UserRepository
+	sessionRepository repository.SessionRepository
+	keyRing           *keyring.KeyRing
+	googleKeys        repository.GoogleKeySource
+	clientIds         []string
 }
 
-func NewAuthService(userRepository repository.UserRepository) AuthService {
-	return &authService{userRepository: userRepository}
+var (
+	ErrInvalidIdToken      = errors.New("invalid id token")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// NewAuthService signs and verifies its own tokens with keyRing and verifies
+// Google ID tokens against googleKeys. clientIds is the comma-separated list
+// of OAuth client IDs accepted as the ID token audience.
+func NewAuthService(userRepository repository.UserRepository, sessionRepository repository.SessionRepository, keyRing *keyring.KeyRing, googleKeys repository.GoogleKeySource, clientIds string) AuthService {
+	var audiences []string
+	for _, id := range strings.Split(clientIds, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			audiences = append(audiences, id)
+		}
+	}
+	return &authService{userRepository: userRepository, sessionRepository: sessionRepository, keyRing: keyRing, googleKeys: googleKeys, clientIds: audiences}
 }
 
 func (s *authService) Login(loginInput *LoginInput) (LoginResponse, error) {
-	userRepo, err := s.userRepository.GetUserInfo(loginInput.GoogleId)
+	identity, err := s.VerifyIdToken(loginInput.IdToken)
 	if err != nil {
 		return LoginResponse{}, err
 	}
 
-	accessToken, refreshToken, accessTokenExpire, refreshTokenExpire, err := s.generateToken(userRepo.GoogleId)
+	userRepo, err := s.userRepository.GetUserInfo(identity.GoogleId)
 	if err != nil {
 		return LoginResponse{}, err
 	}
-	return LoginResponse{AccessToken: accessToken, RefreshToken: refreshToken, AccessTokenExpired: accessTokenExpire, RefreshTokenExpired: refreshTokenExpire}, nil
+	if userRepo == nil {
+		return LoginResponse{}, ErrUserNotFound
+	}
+
+	return s.startSession(userRepo.GoogleId, loginInput)
 }
 
-func (s *authService) generateToken(googleId string) (string, string, string, string, error) {
-	accessExpire := time.Hour * 24
-	acExpTime := time.Now().Add(accessExpire).Unix()
-	acExpTimeUnix := time.Unix(acExpTime, 0)
-	acReadableTime := acExpTimeUnix.Format(time.RFC3339)
-	aClaims := jwt.MapClaims{
-		"googleId": googleId,
-		"exp":      time.Now().Add(accessExpire).Unix(),
+func (s *authService) VerifyIdToken(idToken string) (*GoogleIdentity, error) {
+	if len(s.clientIds) == 0 {
+		return nil, fmt.Errorf("G_CLIENT_ID is not configured")
 	}
-	refreshExpire := time.Hour * 24 * 7
-	rfExpTime := time.Now().Add(refreshExpire).Unix()
-	rfExpTimeUnix := time.Unix(rfExpTime, 0)
-	rfReadableTime := rfExpTimeUnix.Format(time.RFC3339)
-	rClaims := jwt.MapClaims{
-		"googleId": googleId,
-		"exp":      time.Now().Add(refreshExpire).Unix(),
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.googleKeys.GetKey(kid)
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithExpirationRequired(), jwt.WithIssuedAt(), jwt.WithLeeway(time.Minute))
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIdToken, err)
+	}
+
+	issuer, _ := claims.GetIssuer()
+	if !containsString(googleIssuers, issuer) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIdToken, issuer)
+	}
+
+	audience, _ := claims.GetAudience()
+	if !containsAnyString(s.clientIds, audience) {
+		return nil, fmt.Errorf("%w: unexpected audience %v", ErrInvalidIdToken, audience)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIdToken)
+	}
+
+	email, _ := claims["email"].(string)
+	if email != "" && !isEmailVerified(claims["email_verified"]) {
+		return nil, fmt.Errorf("%w: email is not verified", ErrInvalidIdToken)
+	}
+	name, _ := claims["name"].(string)
+	picture, _ := claims["picture"].(string)
+
+	return &GoogleIdentity{
+		GoogleId: subject,
+		Email:    email,
+		Name:     name,
+		Image:    picture,
+	}, nil
+}
+
+// isEmailVerified reads the email_verified claim, which Google has sent both
+// as a boolean and as a string.
+func isEmailVerified(claim interface{}) bool {
+	switch verified := claim.(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true"
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
 	}
+	return false
+}
+
+func containsAnyString(list []string, values []string) bool {
+	for _, value := range values {
+		if containsString(list, value) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+
+	accessTokenExpire  = time.Hour * 24
+	refreshTokenExpire = time.Hour * 24 * 7
+
+	// Last-used times are only written back this often to avoid a database
+	// write on every authenticated request
+	sessionTouchInterval = time.Minute * 5
+)
+
+func newTokenId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *authService) signToken(claims jwt.MapClaims) (string, error) {
+	return s.keyRing.Sign(claims)
+}
 
-	aToken := jwt.NewWithClaims(jwt.SigningMethodHS256, aClaims)
-	aTokenString, err := aToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
+func (s *authService) parseToken(tokenString string, tokenType string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyRing.Keyfunc, jwt.WithValidMethods(s.keyRing.Methods()), jwt.WithExpirationRequired())
 	if err != nil {
-		return "", "", "", "", err
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if typ, _ := claims["typ"].(string); typ != tokenType {
+		return nil, fmt.Errorf("unexpected token type: %v", claims["typ"])
+	}
+	return claims, nil
+}
+
+// startSession opens a new refresh-token family for googleId on the device
+// described by loginInput.
+func (s *authService) startSession(googleId string, loginInput *LoginInput) (LoginResponse, error) {
+	sessionId, err := newTokenId()
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	refreshJti, err := newTokenId()
+	if err != nil {
+		return LoginResponse{}, err
 	}
 
-	rToken := jwt.NewWithClaims(jwt.SigningMethodHS256, rClaims)
-	rTokenString, err := rToken.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	now := time.Now()
+	err = s.sessionRepository.InsertSession(&repository.Session{
+		Id:         sessionId,
+		GoogleId:   googleId,
+		RefreshJti: refreshJti,
+		DeviceName: loginInput.DeviceName,
+		UserAgent:  loginInput.UserAgent,
+		IpAddress:  loginInput.IpAddress,
+		IsRevoked:  false,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenExpire),
+	})
 	if err != nil {
-		return "", "", "", "", err
+		return LoginResponse{}, fmt.Errorf("failed to insert session: %v", err)
 	}
 
-	return aTokenString, rTokenString, acReadableTime, rfReadableTime, nil
+	return s.generateToken(googleId, sessionId, refreshJti, now)
 }
 
-func (s *authService) RefreshToken(refreshToken string) (LoginResponse, error) {
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
+func (s *authService) generateToken(googleId string, sessionId string, refreshJti string, now time.Time) (LoginResponse, error) {
+	accessJti, err := newTokenId()
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	acExpTime := now.Add(accessTokenExpire)
+	aTokenString, err := s.signToken(jwt.MapClaims{
+		"googleId": googleId,
+		"typ":      accessTokenType,
+		"jti":      accessJti,
+		"sid":      sessionId,
+		"iat":      now.Unix(),
+		"exp":      acExpTime.Unix(),
 	})
-	if err != nil || !token.Valid {
+	if err != nil {
 		return LoginResponse{}, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
+	rfExpTime := now.Add(refreshTokenExpire)
+	rTokenString, err := s.signToken(jwt.MapClaims{
+		"googleId": googleId,
+		"typ":      refreshTokenType,
+		"jti":      refreshJti,
+		"sid":      sessionId,
+		"iat":      now.Unix(),
+		"exp":      rfExpTime.Unix(),
+	})
+	if err != nil {
 		return LoginResponse{}, err
 	}
 
-	googleId, ok := claims["googleId"].(string)
-	if !ok {
+	return LoginResponse{
+		AccessToken:         aTokenString,
+		RefreshToken:        rTokenString,
+		AccessTokenExpired:  acExpTime.Format(time.RFC3339),
+		RefreshTokenExpired: rfExpTime.Format(time.RFC3339),
+	}, nil
+}
+
+// RefreshToken rotates the refresh token of a session. Presenting a refresh
+// token that was already rotated away revokes the whole session, since either
+// the client or an attacker is holding a stolen copy.
+func (s *authService) RefreshToken(refreshToken string) (LoginResponse, error) {
+	claims, err := s.parseToken(refreshToken, refreshTokenType)
+	if err != nil {
+		return LoginResponse{}, fmt.Errorf("%w: %v", ErrInvalidRefreshToken, err)
+	}
+
+	googleId, _ := claims["googleId"].(string)
+	sessionId, _ := claims["sid"].(string)
+	jti, _ := claims["jti"].(string)
+	if googleId == "" || sessionId == "" || jti == "" {
+		return LoginResponse{}, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepository.GetSessionById(sessionId)
+	if err != nil {
 		return LoginResponse{}, err
 	}
+	if session == nil || session.IsRevoked || session.GoogleId != googleId {
+		return LoginResponse{}, ErrInvalidRefreshToken
+	}
+
+	if session.RefreshJti != jti {
+		if err := s.sessionRepository.RevokeSession(sessionId); err != nil {
+			return LoginResponse{}, fmt.Errorf("failed to revoke session: %v", err)
+		}
+		return LoginResponse{}, ErrRefreshTokenReused
+	}
 
-	// Generate new access token
-	accessToken, _, accessTokenExpire, _, err := s.generateToken(googleId)
+	newJti, err := newTokenId()
 	if err != nil {
 		return LoginResponse{}, err
 	}
+	now := time.Now()
+	rotated, err := s.sessionRepository.RotateRefreshToken(sessionId, jti, newJti, now, now.Add(refreshTokenExpire))
+	if err != nil {
+		return LoginResponse{}, fmt.Errorf("failed to rotate refresh token: %v", err)
+	}
+	if !rotated {
+		// Another request rotated this token first
+		if err := s.sessionRepository.RevokeSession(sessionId); err != nil {
+			return LoginResponse{}, fmt.Errorf("failed to revoke session: %v", err)
+		}
+		return LoginResponse{}, ErrRefreshTokenReused
+	}
 
-	return LoginResponse{AccessToken: accessToken, AccessTokenExpired: accessTokenExpire}, nil
+	return s.generateToken(googleId, sessionId, newJti, now)
 }
 
+// ValidateAccessToken also rejects tokens whose session has been revoked,
+// so logging a device out takes effect before its access token expires.
 func (s *authService) ValidateAccessToken(tokenString string) (jwt.MapClaims, error) {
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        return []byte(os.Getenv("JWT_SECRET")), nil
-    })
-    if err != nil {
-        return nil, err
-    }
-
-    claims, ok := token.Claims.(jwt.MapClaims)
-    if !ok || !token.Valid {
-        return nil, err
-    }
-
-    return claims, nil
+	claims, err := s.parseToken(tokenString, accessTokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionId, _ := claims["sid"].(string)
+	session, err := s.sessionRepository.GetSessionById(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.IsRevoked {
+		return nil, ErrSessionRevoked
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastUsedAt) > sessionTouchInterval {
+		if err := s.sessionRepository.TouchSession(sessionId, now); err != nil {
+			log.Printf("Failed to update session last used time: %v", err)
+		}
+	}
+
+	return claims, nil
+}
+
+func (s *authService) Logout(sessionId string) error {
+	return s.sessionRepository.RevokeSession(sessionId)
+}
+
+func (s *authService) LogoutAll(googleId string) error {
+	return s.sessionRepository.RevokeAllSessions(googleId)
+}
+
+func (s *authService) GetSessions(googleId string, currentSessionId string) ([]*SessionResponse, error) {
+	sessions, err := s.sessionRepository.GetActiveSessions(googleId)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessionResponses []*SessionResponse
+	for _, session := range sessions {
+		sessionResponses = append(sessionResponses, &SessionResponse{
+			Id:         session.Id,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IpAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			IsCurrent:  session.Id == currentSessionId,
+		})
+	}
+
+	return sessionResponses, nil
+}
+
+func (s *authService) RevokeSession(googleId string, sessionId string) error {
+	session, err := s.sessionRepository.GetSessionById(sessionId)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.GoogleId != googleId {
+		return ErrResourceNotFound
+	}
+	return s.sessionRepository.RevokeSession(sessionId)
 }
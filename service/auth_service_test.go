@@ -0,0 +1,118 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"etalert-backend/repository"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientId = "client-1.apps.googleusercontent.com"
+
+// newTestGoogleKeys returns a signing key and a key source that knows it as
+// kid "test-key", the way Google's JWKS would.
+func newTestGoogleKeys(t *testing.T) (*rsa.PrivateKey, repository.GoogleKeySource) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := json.Marshal(repository.JSONWebKeySet{Keys: []repository.JSONWebKey{{
+		Kid: "test-key",
+		Kty: "RSA",
+		Alg: "RS256",
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := repository.NewStaticKeySource(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, source
+}
+
+func googleClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            "https://accounts.google.com",
+		"aud":            testClientId,
+		"sub":            "1234567890",
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Test User",
+		"picture":        "https://example.com/user.png",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func signIdToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyIdToken(t *testing.T) {
+	key, source := newTestGoogleKeys(t)
+	auth := NewAuthService(nil, nil, nil, source, testClientId)
+
+	identity, err := auth.VerifyIdToken(signIdToken(t, jwt.SigningMethodRS256, "test-key", key, googleClaims()))
+	if err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if identity.GoogleId != "1234567890" || identity.Email != "user@example.com" || identity.Name != "Test User" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+}
+
+func TestVerifyIdTokenRejects(t *testing.T) {
+	key, source := newTestGoogleKeys(t)
+	auth := NewAuthService(nil, nil, nil, source, testClientId)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withClaim := func(name string, value interface{}) jwt.MapClaims {
+		claims := googleClaims()
+		claims[name] = value
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong audience", signIdToken(t, jwt.SigningMethodRS256, "test-key", key, withClaim("aud", "someone-else"))},
+		{"wrong issuer", signIdToken(t, jwt.SigningMethodRS256, "test-key", key, withClaim("iss", "https://evil.example.com"))},
+		{"expired", signIdToken(t, jwt.SigningMethodRS256, "test-key", key, withClaim("exp", time.Now().Add(-time.Hour).Unix()))},
+		{"wrong algorithm", signIdToken(t, jwt.SigningMethodHS256, "test-key", []byte("secret"), googleClaims())},
+		{"unknown kid", signIdToken(t, jwt.SigningMethodRS256, "other-key", otherKey, googleClaims())},
+		{"unverified email", signIdToken(t, jwt.SigningMethodRS256, "test-key", key, withClaim("email_verified", false))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := auth.VerifyIdToken(test.token)
+			if !errors.Is(err, ErrInvalidIdToken) {
+				t.Errorf("got %v, want ErrInvalidIdToken", err)
+			}
+		})
+	}
+}
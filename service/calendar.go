@@ -0,0 +1,32 @@
+package service
+
+import "time"
+
+// CalendarDay is a day the user is off, either for a public holiday of
+// their country or for a day off of their own. Days off carry their Id.
+type CalendarDay struct {
+	Id       string `json:"id,omitempty"`
+	Date     string `json:"date"`
+	Name     string `json:"name"`
+	IsDayOff bool   `json:"isDayOff"`
+}
+
+type CalendarResponse struct {
+	Country string        `json:"country"`
+	Days    []CalendarDay `json:"days"`
+}
+
+type DayOffInput struct {
+	GoogleId string `bson:"googleId"`
+	Date     string `bson:"date"`
+	Name     string `bson:"name"`
+}
+
+type CalendarService interface {
+	GetCalendar(googleId string, year int) (*CalendarResponse, error)
+	AddDayOff(dayOff *DayOffInput) (string, error)
+	DeleteDayOff(id string) error
+	// NonWorkingDays lists the holidays and days off of the user between
+	// from and to, both UTC midnights, along with the weekends if asked to.
+	NonWorkingDays(googleId string, from time.Time, to time.Time, weekends bool) (map[time.Time]bool, error)
+}
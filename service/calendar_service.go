@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"etalert-backend/repository"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+var ErrInvalidDayOff = errors.New("invalid day off date")
+
+type calendarService struct {
+	holidayCalendar repository.HolidayCalendar
+	dayOffRepo      repository.DayOffRepository
+	userRepo        repository.UserRepository
+}
+
+func NewCalendarService(holidayCalendar repository.HolidayCalendar, dayOffRepo repository.DayOffRepository, userRepo repository.UserRepository) CalendarService {
+	return &calendarService{holidayCalendar: holidayCalendar, dayOffRepo: dayOffRepo, userRepo: userRepo}
+}
+
+func (s *calendarService) userCountry(googleId string) (string, error) {
+	user, err := s.userRepo.GetUserInfo(googleId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %v", err)
+	}
+	if user == nil || user.Country == "" {
+		return repository.DefaultCountry, nil
+	}
+	return user.Country, nil
+}
+
+func (s *calendarService) GetCalendar(googleId string, year int) (*CalendarResponse, error) {
+	country, err := s.userCountry(googleId)
+	if err != nil {
+		return nil, err
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	days := make([]CalendarDay, 0)
+	for _, holiday := range s.holidayCalendar.GetHolidays(country, from, to) {
+		days = append(days, CalendarDay{
+			Date: holiday.Date.Format("02-01-2006"),
+			Name: holiday.Name,
+		})
+	}
+
+	daysOff, err := s.dayOffRepo.GetDaysOff(googleId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get days off: %v", err)
+	}
+	for _, dayOff := range daysOff {
+		days = append(days, CalendarDay{
+			Id:       dayOff.Id,
+			Date:     dayOff.Date.Format("02-01-2006"),
+			Name:     dayOff.Name,
+			IsDayOff: true,
+		})
+	}
+
+	sort.SliceStable(days, func(i, j int) bool {
+		a, _ := time.Parse("02-01-2006", days[i].Date)
+		b, _ := time.Parse("02-01-2006", days[j].Date)
+		return a.Before(b)
+	})
+
+	return &CalendarResponse{Country: country, Days: days}, nil
+}
+
+func (s *calendarService) AddDayOff(dayOff *DayOffInput) (string, error) {
+	date, err := time.Parse("02-01-2006", dayOff.Date)
+	if err != nil {
+		return "", ErrInvalidDayOff
+	}
+
+	newDayOff := &repository.DayOff{
+		GoogleId: dayOff.GoogleId,
+		Date:     date,
+		Name:     dayOff.Name,
+	}
+	err = s.dayOffRepo.InsertDayOff(newDayOff)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert day off: %v", err)
+	}
+	return newDayOff.Id, nil
+}
+
+func (s *calendarService) DeleteDayOff(id string) error {
+	err := s.dayOffRepo.DeleteDayOff(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete day off: %v", err)
+	}
+	return nil
+}
+
+func (s *calendarService) NonWorkingDays(googleId string, from time.Time, to time.Time, weekends bool) (map[time.Time]bool, error) {
+	country, err := s.userCountry(googleId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Holidays that move every year are missing past the bundled data, so
+	// those days are not skipped
+	if until := s.holidayCalendar.CoveredUntil(country); !until.IsZero() && to.After(until) {
+		log.Printf("Holidays of %s are only listed until %s, not up to %s", country, until.Format("02-01-2006"), to.Format("02-01-2006"))
+	}
+
+	days := make(map[time.Time]bool)
+	for _, holiday := range s.holidayCalendar.GetHolidays(country, from, to) {
+		days[holiday.Date] = true
+	}
+
+	daysOff, err := s.dayOffRepo.GetDaysOff(googleId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get days off: %v", err)
+	}
+	for _, dayOff := range daysOff {
+		days[dayOff.Date] = true
+	}
+
+	if weekends {
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+				days[date] = true
+			}
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import "time"
+
+// What became of an imported event
+const (
+	ImportStatusPreview = "preview"
+	ImportStatusCreated = "created"
+	ImportStatusSkipped = "skipped"
+	ImportStatusFailed  = "failed"
+)
+
+type ICalendarImportInput struct {
+	GoogleId string
+	Calendar string
+	DryRun   bool
+
+	// The tag of events no other one is found for, and the tags to give
+	// events by category or summary
+	TagId string
+	Tags  map[string]string
+
+	// Where events travel from, and how, unless they say otherwise
+	OriName        string
+	OriLatitude    float64
+	OriLongitude   float64
+	Transportation string
+}
+
+// ImportedEvent is an event of an imported calendar as the schedule it was,
+// or in a dry run would be, created as, with what could not be converted.
+type ImportedEvent struct {
+	Uid            string   `json:"uid"`
+	Name           string   `json:"name"`
+	Date           string   `json:"date,omitempty"`
+	StartTime      string   `json:"startTime,omitempty"`
+	EndTime        string   `json:"endTime,omitempty"`
+	Recurrence     string   `json:"recurrence,omitempty"`
+	ExDates        []string `json:"exDates,omitempty"`
+	RDates         []string `json:"rDates,omitempty"`
+	Overrides      int      `json:"overrides,omitempty"`
+	TagId          string   `json:"tagId,omitempty"`
+	IsHaveLocation bool     `json:"isHaveLocation"`
+	DestName       string   `json:"destName,omitempty"`
+	Status         string   `json:"status"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+type ICalendarImportResponse struct {
+	DryRun  bool            `json:"dryRun"`
+	Created int             `json:"created"`
+	Skipped int             `json:"skipped"`
+	Failed  int             `json:"failed"`
+	Events  []ImportedEvent `json:"events"`
+}
+
+// FeedInfo describes a user's feed. The token is only returned when the
+// feed is created.
+type FeedInfo struct {
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ICalendarService renders schedules as RFC 5545 iCalendar data for other
+// calendar apps, and creates schedules from theirs.
+type ICalendarService interface {
+	ExportSchedules(googleId string) (string, error)
+	ImportSchedules(input *ICalendarImportInput) (*ICalendarImportResponse, error)
+	// CreateFeed gives the user a new feed token, revoking the one before
+	CreateFeed(googleId string) (*FeedInfo, error)
+	GetFeedInfo(googleId string) (*FeedInfo, error)
+	RevokeFeed(googleId string) error
+	// GetFeed renders the schedules of the feed token belongs to, along with
+	// an ETag that only changes when they do
+	GetFeed(token string) (string, string, error)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"etalert-backend/repository"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func newFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *icalendarService) CreateFeed(googleId string) (*FeedInfo, error) {
+	token, err := newFeedToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate feed token: %v", err)
+	}
+
+	err = s.feedTokenRepo.DeleteFeedToken(googleId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke feed token: %v", err)
+	}
+
+	feedToken := &repository.FeedToken{
+		Id:        hashFeedToken(token),
+		GoogleId:  googleId,
+		CreatedAt: time.Now().UTC(),
+	}
+	err = s.feedTokenRepo.InsertFeedToken(feedToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert feed token: %v", err)
+	}
+	return &FeedInfo{Token: token, CreatedAt: feedToken.CreatedAt}, nil
+}
+
+func (s *icalendarService) GetFeedInfo(googleId string) (*FeedInfo, error) {
+	feedToken, err := s.feedTokenRepo.GetFeedTokenByGoogleId(googleId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed token: %v", err)
+	}
+	if feedToken == nil {
+		return nil, ErrResourceNotFound
+	}
+	return &FeedInfo{CreatedAt: feedToken.CreatedAt}, nil
+}
+
+func (s *icalendarService) RevokeFeed(googleId string) error {
+	err := s.feedTokenRepo.DeleteFeedToken(googleId)
+	if err != nil {
+		return fmt.Errorf("failed to revoke feed token: %v", err)
+	}
+	return nil
+}
+
+func (s *icalendarService) GetFeed(token string) (string, string, error) {
+	feedToken, err := s.feedTokenRepo.GetFeedToken(hashFeedToken(token))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get feed token: %v", err)
+	}
+	if feedToken == nil {
+		return "", "", ErrResourceNotFound
+	}
+
+	calendar, err := s.ExportSchedules(feedToken.GoogleId)
+	if err != nil {
+		return "", "", err
+	}
+	return calendar, feedETag(calendar), nil
+}
+
+// feedETag hashes calendar without its DTSTAMPs, which only say when it was
+// rendered, so polling clients see the same tag until a schedule changes.
+func feedETag(calendar string) string {
+	hash := sha256.New()
+	for _, line := range strings.SplitAfter(calendar, "\r\n") {
+		if !strings.HasPrefix(line, "DTSTAMP:") {
+			hash.Write([]byte(line))
+		}
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`
+}
@@ -0,0 +1,572 @@
+package service
+
+import (
+	"errors"
+	"etalert-backend/repository"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Events a single import may hold
+const maxImportEvents = 500
+
+var (
+	ErrInvalidCalendar = repository.ErrInvalidCalendar
+	ErrTooManyEvents   = errors.New("too many events to import")
+)
+
+// icsImport is an event of an imported calendar converted to the schedule it
+// is created as, along with the changes to its series. Skipped events have
+// no schedule.
+type icsImport struct {
+	result    *ImportedEvent
+	schedule  *ScheduleInput
+	exDates   []time.Time
+	rDates    []time.Time
+	overrides []icsOverride
+}
+
+// icsOverride is a RECURRENCE-ID event: the occurrence of its series on date
+// edited as update.
+type icsOverride struct {
+	date   time.Time
+	update *ScheduleUpdateInput
+}
+
+func (i *icsImport) warn(format string, args ...interface{}) {
+	i.result.Warnings = append(i.result.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (i *icsImport) skip(format string, args ...interface{}) *icsImport {
+	i.warn(format, args...)
+	i.result.Status = ImportStatusSkipped
+	i.schedule = nil
+	return i
+}
+
+// ImportSchedules creates a schedule, or a recurring one, for every VEVENT
+// of the calendar. Events that cannot be converted are skipped, and what
+// could not be carried over is reported per event. A dry run converts the
+// events without creating anything.
+func (s *icalendarService) ImportSchedules(input *ICalendarImportInput) (*ICalendarImportResponse, error) {
+	events, err := repository.ParseICSEvents([]byte(input.Calendar))
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > maxImportEvents {
+		return nil, ErrTooManyEvents
+	}
+
+	tags, err := s.tagRepo.GetAllTags(input.GoogleId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %v", err)
+	}
+	tagIds := make(map[string]bool)
+	for _, tag := range tags {
+		tagIds[tag.Id] = true
+	}
+	if input.TagId != "" && !tagIds[input.TagId] {
+		return nil, ErrUnknownTag
+	}
+	for _, tagId := range input.Tags {
+		if !tagIds[tagId] {
+			return nil, ErrUnknownTag
+		}
+	}
+
+	loc := userLocation(s.userRepo, input.GoogleId)
+
+	var imports []*icsImport
+	var overrides []repository.ICSEvent
+	series := make(map[string]*icsImport)
+	for _, event := range events {
+		if event.Get("RECURRENCE-ID") != nil {
+			overrides = append(overrides, event)
+			continue
+		}
+		imported := convertICSEvent(&event, input, tags, loc)
+		imports = append(imports, imported)
+		if uid := event.Value("UID"); uid != "" && imported.schedule != nil {
+			series[uid] = imported
+		}
+	}
+
+	// Overrides are applied to their series once it exists
+	for _, event := range overrides {
+		master := series[event.Value("UID")]
+		if master == nil || master.schedule.Recurrence == "none" {
+			imported := convertICSEvent(&event, input, tags, loc)
+			if imported.schedule != nil {
+				imported.warn("changed occurrence of a recurring event that is not in the calendar, imported on its own")
+			}
+			imports = append(imports, imported)
+			continue
+		}
+		applyICSOverride(master, &event, loc)
+	}
+
+	response := &ICalendarImportResponse{DryRun: input.DryRun, Events: make([]ImportedEvent, 0, len(imports))}
+	for _, imported := range imports {
+		if imported.schedule != nil {
+			if input.DryRun {
+				imported.result.Status = ImportStatusPreview
+			} else {
+				s.createImport(imported)
+			}
+		}
+
+		switch imported.result.Status {
+		case ImportStatusCreated:
+			response.Created++
+		case ImportStatusSkipped:
+			response.Skipped++
+		case ImportStatusFailed:
+			response.Failed++
+		}
+		response.Events = append(response.Events, *imported.result)
+	}
+	return response, nil
+}
+
+// createImport creates the schedule of imported, then makes the same changes
+// to its series as the calendar had.
+func (s *icalendarService) createImport(imported *icsImport) {
+	schedule := imported.schedule
+	if schedule.Recurrence == "none" {
+		warning, err := s.scheduleSrv.InsertSchedule(schedule)
+		if err != nil {
+			imported.result.Status = ImportStatusFailed
+			imported.warn("failed to create schedule")
+			return
+		}
+		if warning != "" {
+			imported.warn("%s", warning)
+		}
+		imported.result.Status = ImportStatusCreated
+		return
+	}
+
+	warning, err := s.scheduleSrv.InsertRecurrenceSchedule(schedule)
+	if err != nil {
+		imported.result.Status = ImportStatusFailed
+		imported.warn("failed to create schedule")
+		return
+	}
+	if warning != "" {
+		imported.warn("%s", warning)
+	}
+	imported.result.Status = ImportStatusCreated
+
+	recurrenceId := strconv.Itoa(schedule.RecurrenceId)
+	for _, date := range imported.rDates {
+		if err := s.scheduleSrv.AddRecurrenceDate(recurrenceId, date.Format("02-01-2006")); err != nil {
+			imported.warn("failed to add the occurrence on %s", date.Format("02-01-2006"))
+		}
+	}
+	for _, date := range imported.exDates {
+		if err := s.scheduleSrv.DeleteScheduleByRecurrenceId(recurrenceId, date.Format("02-01-2006"), RecurrenceModeThis); err != nil {
+			imported.warn("failed to cancel the occurrence on %s", date.Format("02-01-2006"))
+		}
+	}
+	for _, override := range imported.overrides {
+		err := s.scheduleSrv.UpdateScheduleByRecurrenceId(recurrenceId, override.update, override.date.Format("02-01-2006"), RecurrenceModeThis)
+		if err == ErrResourceNotFound {
+			imported.warn("changed occurrence on %s is not an occurrence of the series", override.date.Format("02-01-2006"))
+		} else if err != nil {
+			imported.warn("failed to change the occurrence on %s", override.date.Format("02-01-2006"))
+		}
+	}
+}
+
+// convertICSEvent turns event into the schedule it is imported as, in loc.
+// Times are kept, and so are places that have coordinates; travel legs and
+// routines ETAlert exported itself are left out, since they are added again
+// for the schedules they lead to.
+func convertICSEvent(event *repository.ICSEvent, input *ICalendarImportInput, tags []*repository.Tag, loc *time.Location) *icsImport {
+	result := &ImportedEvent{Uid: event.Value("UID"), Name: icsUnescape(event.Value("SUMMARY"))}
+	imported := &icsImport{result: result}
+
+	categories := icsCategories(event)
+	if strings.EqualFold(event.Value("STATUS"), "CANCELLED") {
+		return imported.skip("event is cancelled")
+	}
+	if event.Get("RELATED-TO") != nil && (hasCategory(categories, "TRAVEL") || hasCategory(categories, "ROUTINE")) {
+		return imported.skip("travel legs and routines are added along with the schedules they lead to")
+	}
+
+	startAt, endAt, allDay, err := icsEventSpan(event, loc, imported)
+	if err != nil {
+		return imported.skip("%v", err)
+	}
+	if allDay {
+		return imported.skip("all-day events are not supported")
+	}
+
+	if result.Name == "" {
+		result.Name = "Untitled"
+		imported.warn("event has no SUMMARY, named %q", result.Name)
+	}
+
+	schedule := &ScheduleInput{
+		GoogleId:       input.GoogleId,
+		Name:           result.Name,
+		Date:           startAt.In(loc).Format("02-01-2006"),
+		StartTime:      startAt.In(loc).Format("15:04"),
+		Transportation: input.Transportation,
+		Recurrence:     "none",
+	}
+	if !endAt.IsZero() {
+		schedule.EndTime = endAt.In(loc).Format("15:04")
+		schedule.IsHaveEndTime = true
+	}
+	imported.schedule = schedule
+
+	if value := event.Value("RRULE"); value != "" {
+		convertICSRecurrence(event, value, startAt, imported, loc)
+	}
+
+	// Destination from LOCATION and GEO, origin from the export's own
+	// properties or the import's default
+	schedule.DestName = icsUnescape(event.Value("LOCATION"))
+	hasDestination := false
+	if value := event.Value("GEO"); value != "" {
+		schedule.DestLatitude, schedule.DestLongitude, hasDestination = parseICSGeo(value)
+		if !hasDestination {
+			imported.warn("invalid GEO %q", value)
+		}
+	}
+	if schedule.DestName != "" && !hasDestination {
+		imported.warn("location has no coordinates, so travel time is not tracked")
+	}
+
+	schedule.OriName, schedule.OriLatitude, schedule.OriLongitude = input.OriName, input.OriLatitude, input.OriLongitude
+	hasOrigin := input.OriLatitude != 0 || input.OriLongitude != 0
+	if value := event.Value("X-ETALERT-ORIGIN-GEO"); value != "" {
+		if latitude, longitude, ok := parseICSGeo(value); ok {
+			schedule.OriName = icsUnescape(event.Value("X-ETALERT-ORIGIN"))
+			schedule.OriLatitude, schedule.OriLongitude, hasOrigin = latitude, longitude, true
+		}
+	}
+	if hasDestination && !hasOrigin {
+		imported.warn("no origin to travel from, so travel time is not tracked")
+	}
+	schedule.IsHaveLocation = hasDestination && hasOrigin
+
+	if value := event.Value("X-ETALERT-TRANSPORTATION"); value == "walking" || value == "driving" || value == "transit" {
+		schedule.Transportation = value
+	}
+	if value := event.Value("X-ETALERT-PRIORITY"); value != "" {
+		schedule.Priority, _ = strconv.Atoi(value)
+	}
+
+	// Routines are added before schedules with a tag
+	schedule.TagId = importTag(event, categories, input, tags)
+	schedule.IsFirstSchedule = schedule.TagId != ""
+
+	result.Name = schedule.Name
+	result.Date = schedule.Date
+	result.StartTime = schedule.StartTime
+	result.EndTime = schedule.EndTime
+	result.TagId = schedule.TagId
+	result.IsHaveLocation = schedule.IsHaveLocation
+	result.DestName = schedule.DestName
+	return imported
+}
+
+// convertICSRecurrence makes the schedule of imported recur by rrule, with
+// the dates the event adds and excludes. A rule that is not supported leaves
+// just the first occurrence.
+func convertICSRecurrence(event *repository.ICSEvent, rrule string, startAt time.Time, imported *icsImport, loc *time.Location) {
+	rule, err := repository.ParseRecurrence(localizeICSUntil(rrule, startAt, loc))
+	if err != nil {
+		imported.warn("%v, only the first occurrence is imported", err)
+		return
+	}
+	imported.schedule.Recurrence = rule.String()
+	imported.result.Recurrence = imported.schedule.Recurrence
+	if !localDate(startAt, startAt.Location()).Equal(localDate(startAt, loc)) {
+		imported.warn("event falls on another day in your time zone, so its rule may pick the wrong days")
+	}
+
+	// DTSTART is always an occurrence in iCalendar, even if the rule does
+	// not give it
+	startDate := localDate(startAt, loc)
+	if first := rule.Occurrences(startDate, 1); len(first) == 0 || !first[0].Equal(startDate) {
+		imported.rDates = append(imported.rDates, startDate)
+	}
+
+	for _, property := range event.All("RDATE") {
+		if strings.EqualFold(property.Params["VALUE"], "PERIOD") {
+			imported.warn("RDATE periods are not supported")
+			continue
+		}
+		imported.rDates = append(imported.rDates, icsDates(property, imported, loc)...)
+	}
+	for _, property := range event.All("EXDATE") {
+		imported.exDates = append(imported.exDates, icsDates(property, imported, loc)...)
+	}
+
+	for _, date := range imported.rDates {
+		imported.result.RDates = append(imported.result.RDates, date.Format("02-01-2006"))
+	}
+	for _, date := range imported.exDates {
+		imported.result.ExDates = append(imported.result.ExDates, date.Format("02-01-2006"))
+	}
+}
+
+// applyICSOverride makes event, which has a RECURRENCE-ID, the change or
+// cancellation of that occurrence of master.
+func applyICSOverride(master *icsImport, event *repository.ICSEvent, loc *time.Location) {
+	recurrenceAt, _, err := parseICSTime(*event.Get("RECURRENCE-ID"), loc)
+	if err != nil {
+		master.warn("invalid RECURRENCE-ID %q", event.Value("RECURRENCE-ID"))
+		return
+	}
+	date := localDate(recurrenceAt, loc)
+	dateText := date.Format("02-01-2006")
+
+	if strings.EqualFold(event.Value("STATUS"), "CANCELLED") {
+		master.exDates = append(master.exDates, date)
+		master.result.ExDates = append(master.result.ExDates, dateText)
+		return
+	}
+
+	startAt, endAt, allDay, err := icsEventSpan(event, loc, master)
+	if err != nil || allDay {
+		master.warn("changed occurrence on %s could not be read, left unchanged", dateText)
+		return
+	}
+
+	update := &ScheduleUpdateInput{
+		Name:      icsUnescape(event.Value("SUMMARY")),
+		Date:      startAt.In(loc).Format("02-01-2006"),
+		StartTime: startAt.In(loc).Format("15:04"),
+	}
+	if update.Name == "" {
+		update.Name = master.schedule.Name
+	}
+	if !endAt.IsZero() {
+		update.EndTime = endAt.In(loc).Format("15:04")
+		update.IsHaveEndTime = true
+	}
+	master.overrides = append(master.overrides, icsOverride{date: date, update: update})
+	master.result.Overrides++
+}
+
+// icsEventSpan reads when event starts and ends. The end is zero for events
+// without one, and for those lasting a day or more, which schedules cannot
+// hold.
+func icsEventSpan(event *repository.ICSEvent, loc *time.Location, imported *icsImport) (time.Time, time.Time, bool, error) {
+	start := event.Get("DTSTART")
+	if start == nil {
+		return time.Time{}, time.Time{}, false, errors.New("event has no DTSTART")
+	}
+	startAt, allDay, err := parseICSTime(*start, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("invalid DTSTART %q", start.Value)
+	}
+
+	var endAt time.Time
+	if end := event.Get("DTEND"); end != nil {
+		endAt, _, err = parseICSTime(*end, loc)
+		if err != nil {
+			imported.warn("invalid DTEND %q, imported without an end time", end.Value)
+			endAt = time.Time{}
+		}
+	} else if value := event.Value("DURATION"); value != "" {
+		duration, err := parseICSDuration(value)
+		if err != nil {
+			imported.warn("invalid DURATION %q, imported without an end time", value)
+		} else {
+			endAt = startAt.Add(duration)
+		}
+	}
+
+	switch {
+	case endAt.IsZero() || allDay:
+	case !endAt.After(startAt):
+		endAt = time.Time{}
+	case endAt.Sub(startAt) >= 24*time.Hour:
+		imported.warn("event lasts a day or more, imported without an end time")
+		endAt = time.Time{}
+	}
+	return startAt, endAt, allDay, nil
+}
+
+// parseICSTime reads a DATE or DATE-TIME value. Dates are midnights in loc,
+// as are times without a zone. Zones Go does not know, such as Windows
+// ones, are read as loc too.
+func parseICSTime(property repository.ICSProperty, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(property.Value)
+	if strings.EqualFold(property.Params["VALUE"], "DATE") || len(value) == 8 {
+		date, err := time.ParseInLocation("20060102", value, loc)
+		return date, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		instant, err := time.Parse("20060102T150405Z", value)
+		return instant, false, err
+	}
+
+	zone := loc
+	if tzid := property.Params["TZID"]; tzid != "" {
+		if named, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			zone = named
+		}
+	}
+	instant, err := time.ParseInLocation("20060102T150405", value, zone)
+	return instant, false, err
+}
+
+// icsDates reads the comma separated dates of an EXDATE or RDATE as the
+// dates of loc they fall on.
+func icsDates(property repository.ICSProperty, imported *icsImport, loc *time.Location) []time.Time {
+	var dates []time.Time
+	for _, value := range strings.Split(property.Value, ",") {
+		instant, _, err := parseICSTime(repository.ICSProperty{Name: property.Name, Params: property.Params, Value: value}, loc)
+		if err != nil {
+			imported.warn("invalid %s %q", property.Name, value)
+			continue
+		}
+		dates = append(dates, localDate(instant, loc))
+	}
+	return dates
+}
+
+// localizeICSUntil rewrites a date-time UNTIL as the last date in loc an
+// occurrence starting at the time of startAt may fall on, since rules only
+// hold dates.
+func localizeICSUntil(rrule string, startAt time.Time, loc *time.Location) string {
+	parts := strings.Split(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rrule)), "RRULE:"), ";")
+	for i, part := range parts {
+		value, ok := strings.CutPrefix(part, "UNTIL=")
+		if !ok || len(value) <= 8 {
+			continue
+		}
+		until, _, err := parseICSTime(repository.ICSProperty{Value: value}, loc)
+		if err != nil {
+			continue
+		}
+		local := until.In(loc)
+		start := startAt.In(loc)
+		date := localDate(until, loc)
+		if local.Hour()*60+local.Minute() < start.Hour()*60+start.Minute() {
+			date = date.AddDate(0, 0, -1)
+		}
+		parts[i] = "UNTIL=" + date.Format("20060102")
+	}
+	return strings.Join(parts, ";")
+}
+
+// parseICSDuration reads a DURATION such as "PT1H30M" or "P1W".
+func parseICSDuration(value string) (time.Duration, error) {
+	rest := strings.TrimPrefix(value, "+")
+	negative := strings.HasPrefix(rest, "-")
+	rest = strings.TrimPrefix(rest, "-")
+	rest, ok := strings.CutPrefix(rest, "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var duration time.Duration
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c >= '0' && c <= '9':
+			number += string(c)
+		case c == 'T':
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		default:
+			unit, ok := units[c]
+			n, err := strconv.Atoi(number)
+			if !ok || err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			duration += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	if negative {
+		duration = -duration
+	}
+	return duration, nil
+}
+
+func parseICSGeo(value string) (float64, float64, bool) {
+	latText, lonText, ok := strings.Cut(value, ";")
+	if !ok {
+		return 0, 0, false
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return 0, 0, false
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return 0, 0, false
+	}
+	return latitude, longitude, true
+}
+
+func icsCategories(event *repository.ICSEvent) []string {
+	var categories []string
+	for _, property := range event.All("CATEGORIES") {
+		for _, category := range strings.Split(property.Value, ",") {
+			if category = strings.TrimSpace(icsUnescape(category)); category != "" {
+				categories = append(categories, category)
+			}
+		}
+	}
+	return categories
+}
+
+func hasCategory(categories []string, name string) bool {
+	for _, category := range categories {
+		if strings.EqualFold(category, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// importTag picks the tag of an imported event: the one it was exported
+// with, the one the import gives for one of its categories or its summary,
+// one of the user's tags named like a category, or the import's default.
+func importTag(event *repository.ICSEvent, categories []string, input *ICalendarImportInput, tags []*repository.Tag) string {
+	tagId := event.Value("X-ETALERT-TAG-ID")
+	for _, tag := range tags {
+		if tag.Id == tagId {
+			return tagId
+		}
+	}
+
+	keys := append(append([]string{}, categories...), icsUnescape(event.Value("SUMMARY")))
+	for _, key := range keys {
+		for name, tagId := range input.Tags {
+			if strings.EqualFold(name, key) {
+				return tagId
+			}
+		}
+	}
+
+	for _, category := range categories {
+		for _, tag := range tags {
+			if strings.EqualFold(tag.Name, category) {
+				return tag.Id
+			}
+		}
+	}
+	return input.TagId
+}
+
+var icsTextUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func icsUnescape(value string) string {
+	return icsTextUnescaper.Replace(value)
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"etalert-backend/repository"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeImportTags struct {
+	repository.TagRepository
+}
+
+func (r *fakeImportTags) GetAllTags(gId string) ([]*repository.Tag, error) {
+	return nil, nil
+}
+
+func bangkok(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseICSTime(t *testing.T) {
+	loc := bangkok(t)
+	tests := []struct {
+		name     string
+		property repository.ICSProperty
+		want     time.Time
+		allDay   bool
+	}{
+		{"UTC", repository.ICSProperty{Value: "20260310T090000Z"},
+			time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC), false},
+		// New York is on EDT from 8 March 2026
+		{"TZID", repository.ICSProperty{Params: map[string]string{"TZID": "America/New_York"}, Value: "20260310T090000"},
+			time.Date(2026, 3, 10, 13, 0, 0, 0, time.UTC), false},
+		{"TZID with a leading slash", repository.ICSProperty{Params: map[string]string{"TZID": "/Europe/London"}, Value: "20260115T090000"},
+			time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC), false},
+		{"unknown TZID", repository.ICSProperty{Params: map[string]string{"TZID": "SE Asia Standard Time"}, Value: "20260310T090000"},
+			time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC), false},
+		{"floating", repository.ICSProperty{Value: "20260310T090000"},
+			time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC), false},
+		{"date", repository.ICSProperty{Params: map[string]string{"VALUE": "DATE"}, Value: "20260310"},
+			time.Date(2026, 3, 9, 17, 0, 0, 0, time.UTC), true},
+		{"date without VALUE", repository.ICSProperty{Value: "20260310"},
+			time.Date(2026, 3, 9, 17, 0, 0, 0, time.UTC), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instant, allDay, err := parseICSTime(test.property, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !instant.Equal(test.want) || allDay != test.allDay {
+				t.Errorf("got %v, all day %v, want %v, all day %v", instant.UTC(), allDay, test.want, test.allDay)
+			}
+		})
+	}
+
+	for _, value := range []string{"2026-03-10", "20261310T090000", "20260310T0900"} {
+		if _, _, err := parseICSTime(repository.ICSProperty{Value: value}, loc); err == nil {
+			t.Errorf("%q accepted", value)
+		}
+	}
+}
+
+func TestParseICSDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"PT1H30M", 90 * time.Minute},
+		{"PT45S", 45 * time.Second},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P2W", 14 * 24 * time.Hour},
+		{"P1DT2H", 26 * time.Hour},
+		{"+PT15M", 15 * time.Minute},
+		{"-PT15M", -15 * time.Minute},
+		{"-P1DT12H", -36 * time.Hour},
+	}
+	for _, test := range tests {
+		duration, err := parseICSDuration(test.value)
+		if err != nil || duration != test.want {
+			t.Errorf("%q: got %v, %v, want %v", test.value, duration, err, test.want)
+		}
+	}
+
+	for _, value := range []string{"", "P", "PT1H3", "1H", "PT1X", "P1H", "PTH"} {
+		if _, err := parseICSDuration(value); err == nil {
+			t.Errorf("%q accepted", value)
+		}
+	}
+}
+
+func TestLocalizeICSUntil(t *testing.T) {
+	loc := bangkok(t)
+	// Occurrences start at 09:00 in Bangkok, 02:00 UTC
+	startAt := time.Date(2026, 3, 2, 9, 0, 0, 0, loc)
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{"at the start time", "FREQ=DAILY;UNTIL=20260310T020000Z", "FREQ=DAILY;UNTIL=20260310"},
+		{"before the start time", "FREQ=DAILY;UNTIL=20260310T015959Z", "FREQ=DAILY;UNTIL=20260309"},
+		// 01:00 on the 11th in Bangkok, before that day's occurrence
+		{"next day in the local zone", "RRULE:FREQ=WEEKLY;UNTIL=20260310T180000Z;BYDAY=TU", "FREQ=WEEKLY;UNTIL=20260310;BYDAY=TU"},
+		{"floating", "FREQ=DAILY;UNTIL=20260310T090000", "FREQ=DAILY;UNTIL=20260310"},
+		{"date", "FREQ=DAILY;UNTIL=20260310", "FREQ=DAILY;UNTIL=20260310"},
+		{"no UNTIL", "FREQ=DAILY;COUNT=3", "FREQ=DAILY;COUNT=3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := localizeICSUntil(test.rule, startAt, loc); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyICSOverride(t *testing.T) {
+	loc := bangkok(t)
+	newMaster := func() *icsImport {
+		return &icsImport{result: &ImportedEvent{}, schedule: &ScheduleInput{Name: "Standup", Recurrence: "FREQ=WEEKLY;BYDAY=MO"}}
+	}
+	override := func(properties ...repository.ICSProperty) *repository.ICSEvent {
+		return &repository.ICSEvent{Properties: append([]repository.ICSProperty{
+			{Name: "RECURRENCE-ID", Params: map[string]string{"TZID": "Asia/Bangkok"}, Value: "20260323T090000"},
+		}, properties...)}
+	}
+
+	cancelled := newMaster()
+	applyICSOverride(cancelled, override(repository.ICSProperty{Name: "STATUS", Value: "CANCELLED"}), loc)
+	if !reflect.DeepEqual(cancelled.exDates, []time.Time{date("2026-03-23")}) || len(cancelled.overrides) != 0 {
+		t.Errorf("cancelled occurrence gave EXDATEs %v and overrides %v", cancelled.exDates, cancelled.overrides)
+	}
+
+	// Moved to the Tuesday, without a SUMMARY of its own
+	moved := newMaster()
+	applyICSOverride(moved, override(
+		repository.ICSProperty{Name: "DTSTART", Params: map[string]string{"TZID": "Asia/Bangkok"}, Value: "20260324T110000"},
+		repository.ICSProperty{Name: "DURATION", Value: "PT1H30M"},
+	), loc)
+	if len(moved.exDates) != 0 || len(moved.overrides) != 1 || moved.result.Overrides != 1 {
+		t.Fatalf("moved occurrence gave EXDATEs %v and overrides %v", moved.exDates, moved.overrides)
+	}
+	want := ScheduleUpdateInput{Name: "Standup", Date: "24-03-2026", StartTime: "11:00", EndTime: "12:30", IsHaveEndTime: true}
+	if got := moved.overrides[0]; !got.date.Equal(date("2026-03-23")) || !reflect.DeepEqual(*got.update, want) {
+		t.Errorf("override of %v as %+v, want 2026-03-23 as %+v", got.date, *got.update, want)
+	}
+
+	invalid := newMaster()
+	applyICSOverride(invalid, &repository.ICSEvent{Properties: []repository.ICSProperty{{Name: "RECURRENCE-ID", Value: "soon"}}}, loc)
+	if len(invalid.exDates) != 0 || len(invalid.overrides) != 0 || len(invalid.result.Warnings) != 1 {
+		t.Errorf("invalid RECURRENCE-ID gave %+v", invalid)
+	}
+}
+
+func TestImportRecurringEvent(t *testing.T) {
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		// Starts on a Tuesday although the rule gives Mondays
+		"BEGIN:VEVENT",
+		"UID:standup@example.com",
+		"SUMMARY:Standup",
+		"DTSTART;TZID=Asia/Bangkok:20260303T090000",
+		"DTEND;TZID=Asia/Bangkok:20260303T100000",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO;UNTIL=20260330T020000Z",
+		"EXDATE;TZID=Asia/Bangkok:20260309T090000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup@example.com",
+		"RECURRENCE-ID;TZID=Asia/Bangkok:20260316T090000",
+		"DTSTART;TZID=Asia/Bangkok:20260316T090000",
+		"STATUS:CANCELLED",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup@example.com",
+		"RECURRENCE-ID;TZID=Asia/Bangkok:20260323T090000",
+		"DTSTART;TZID=Asia/Bangkok:20260324T110000",
+		"DURATION:PT30M",
+		"END:VEVENT",
+		// The series this changes is not in the calendar
+		"BEGIN:VEVENT",
+		"UID:gone@example.com",
+		"SUMMARY:Review",
+		"RECURRENCE-ID:20260401T010000Z",
+		"DTSTART:20260401T020000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	service := NewICalendarService(nil, nil, &fakeExportUsers{timeZone: "Asia/Bangkok"}, &fakeImportTags{}, nil, nil, nil)
+	response, err := service.ImportSchedules(&ICalendarImportInput{GoogleId: "user-1", Calendar: calendar, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Events) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(response.Events), response.Events)
+	}
+
+	standup := response.Events[0]
+	want := ImportedEvent{
+		Uid:        "standup@example.com",
+		Name:       "Standup",
+		Date:       "03-03-2026",
+		StartTime:  "09:00",
+		EndTime:    "10:00",
+		Recurrence: "FREQ=WEEKLY;BYDAY=MO;UNTIL=20260330",
+		ExDates:    []string{"09-03-2026", "16-03-2026"},
+		RDates:     []string{"03-03-2026"},
+		Overrides:  1,
+		Status:     ImportStatusPreview,
+	}
+	if !reflect.DeepEqual(standup, want) {
+		t.Errorf("got %+v, want %+v", standup, want)
+	}
+
+	review := response.Events[1]
+	if review.Uid != "gone@example.com" || review.Status != ImportStatusPreview || review.Date != "01-04-2026" || review.StartTime != "09:00" || review.Recurrence != "" {
+		t.Errorf("orphaned override imported as %+v", review)
+	}
+	if len(review.Warnings) != 1 || !strings.Contains(review.Warnings[0], "not in the calendar") {
+		t.Errorf("orphaned override warned %v", review.Warnings)
+	}
+}
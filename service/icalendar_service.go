@@ -0,0 +1,441 @@
+package service
+
+import (
+	"etalert-backend/repository"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const icsProductId = "-//ETAlert//Schedules//EN"
+
+type icalendarService struct {
+	scheduleRepo repository.ScheduleRepository
+	seriesRepo   repository.RecurrenceSeriesRepository
+	userRepo     repository.UserRepository
+	tagRepo      repository.TagRepository
+
+	feedTokenRepo repository.FeedTokenRepository
+
+	// Imported events are created the way the app creates them
+	scheduleSrv ScheduleService
+	calendarSrv CalendarService
+}
+
+func NewICalendarService(scheduleRepo repository.ScheduleRepository, seriesRepo repository.RecurrenceSeriesRepository, userRepo repository.UserRepository, tagRepo repository.TagRepository, feedTokenRepo repository.FeedTokenRepository, scheduleSrv ScheduleService, calendarSrv CalendarService) ICalendarService {
+	return &icalendarService{scheduleRepo: scheduleRepo, seriesRepo: seriesRepo, userRepo: userRepo, tagRepo: tagRepo, feedTokenRepo: feedTokenRepo, scheduleSrv: scheduleSrv, calendarSrv: calendarSrv}
+}
+
+// ExportSchedules renders every schedule of the user as a VEVENT. A group
+// becomes its main schedule's event, with its travel leg and routines as
+// events related to it. Series with a master are written once, with an
+// RRULE, and their materialized occurrences only as far as they differ from
+// it: as EXDATEs where they were cancelled or skipped, and as overrides
+// where they were edited. Series that leave out non-working days also get
+// EXDATEs for those days for a year past their materialized occurrences.
+// Times are given in the user's zone, which is written as a VTIMEZONE.
+func (s *icalendarService) ExportSchedules(googleId string) (string, error) {
+	schedules, err := s.scheduleRepo.GetAllSchedules(googleId, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get schedules: %v", err)
+	}
+	loc := userLocation(s.userRepo, googleId)
+
+	var groupIds []int
+	groups := make(map[int][]*repository.Schedule)
+	for _, schedule := range schedules {
+		if _, ok := groups[schedule.GroupId]; !ok {
+			groupIds = append(groupIds, schedule.GroupId)
+		}
+		groups[schedule.GroupId] = append(groups[schedule.GroupId], schedule)
+	}
+
+	// Series masters, and the occurrences of each that are materialized
+	var recurrenceIds []int
+	seriesById := make(map[int]*repository.RecurrenceSeries)
+	materialized := make(map[int]map[time.Time]bool)
+	for _, groupId := range groupIds {
+		main := groupMainSchedule(groups[groupId])
+		if main.RecurrenceId == 0 {
+			continue
+		}
+		if _, ok := seriesById[main.RecurrenceId]; !ok {
+			series, err := s.seriesRepo.GetSeriesByRecurrenceId(main.RecurrenceId)
+			if err != nil {
+				return "", fmt.Errorf("failed to get recurrence series: %v", err)
+			}
+			seriesById[main.RecurrenceId] = series
+			materialized[main.RecurrenceId] = make(map[time.Time]bool)
+			if series != nil {
+				recurrenceIds = append(recurrenceIds, main.RecurrenceId)
+			}
+		}
+		materialized[main.RecurrenceId][occurrenceDate(main)] = true
+	}
+
+	now := time.Now()
+	w := &icsWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + icsProductId)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:ETAlert")
+	w.line("X-WR-TIMEZONE:" + loc.String())
+	from, to := icsTimezoneRange(schedules, seriesById, now)
+	writeTimezone(w, loc, from, to)
+
+	for _, recurrenceId := range recurrenceIds {
+		err := s.writeSeries(w, seriesById[recurrenceId], materialized[recurrenceId], loc, now)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, groupId := range groupIds {
+		group := groups[groupId]
+		main := groupMainSchedule(group)
+		series := seriesById[main.RecurrenceId]
+
+		parentUid := icsGroupUid(groupId)
+		switch {
+		case series == nil:
+			err = writeScheduleEvent(w, main, parentUid, "", time.Time{}, loc, now)
+		case main.IsException:
+			parentUid = icsSeriesUid(series.RecurrenceId)
+			recurrenceStart, _, spanErr := scheduleInstants(occurrenceDate(main).Format("02-01-2006"), series.StartTime, "", loc)
+			if spanErr != nil {
+				return "", spanErr
+			}
+			err = writeScheduleEvent(w, main, parentUid, "", recurrenceStart, loc, now)
+		default:
+			// Written by the series' RRULE
+			parentUid = icsSeriesUid(series.RecurrenceId)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		for _, schedule := range group {
+			if schedule == main {
+				continue
+			}
+			err = writeScheduleEvent(w, schedule, schedule.Id+"@etalert", parentUid, time.Time{}, loc, now)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	w.line("END:VCALENDAR")
+	return w.String(), nil
+}
+
+// writeSeries writes the master event of series and overrides for the edited
+// occurrences it has not materialized yet.
+func (s *icalendarService) writeSeries(w *icsWriter, series *repository.RecurrenceSeries, materialized map[time.Time]bool, loc *time.Location, now time.Time) error {
+	rule, err := repository.ParseRecurrence(series.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	// DTSTART is always an occurrence in iCalendar, so the series starts on
+	// the first date its rule gives rather than on StartDate
+	first := rule.Occurrences(series.StartDate, 1)
+	if len(first) == 0 || !series.EndDate.IsZero() && first[0].After(series.EndDate) {
+		return nil
+	}
+
+	last := series.MaterializedUntil
+	if !series.EndDate.IsZero() {
+		// Written as an UNTIL on the last date the series still has
+		dates := rule.Between(series.StartDate, series.StartDate, series.EndDate)
+		if len(dates) > 0 {
+			rule.Count = 0
+			rule.Until = dates[len(dates)-1]
+		}
+		if series.EndDate.Before(last) {
+			last = series.EndDate
+		}
+	}
+
+	template := &repository.Schedule{
+		Name:           series.Name,
+		IsHaveEndTime:  series.IsHaveEndTime,
+		OriName:        series.OriName,
+		OriLatitude:    series.OriLatitude,
+		OriLongitude:   series.OriLongitude,
+		DestName:       series.DestName,
+		DestLatitude:   series.DestLatitude,
+		DestLongitude:  series.DestLongitude,
+		Transportation: series.Transportation,
+		Priority:       series.Priority,
+		IsHaveLocation: series.IsHaveLocation,
+		TagId:          series.TagId,
+		RecurrenceId:   series.RecurrenceId,
+	}
+	startAt, endAt, err := scheduleInstants(first[0].Format("02-01-2006"), series.StartTime, series.EndTime, loc)
+	if err != nil {
+		return err
+	}
+	template.StartAt, template.EndAt = startAt, endAt
+
+	occurrenceStart := func(date time.Time) (time.Time, error) {
+		instant, _, err := scheduleInstants(date.Format("02-01-2006"), series.StartTime, "", loc)
+		return instant, err
+	}
+
+	var exDates []time.Time
+	for _, date := range rule.Between(series.StartDate, series.StartDate, last) {
+		if !materialized[date] {
+			exDates = append(exDates, date)
+		}
+	}
+	for _, date := range series.RDates {
+		if !date.After(last) && !materialized[date] {
+			exDates = append(exDates, date)
+		}
+	}
+	for _, exception := range series.Exceptions {
+		if exception.IsCancelled && exception.Date.After(last) {
+			exDates = append(exDates, exception.Date)
+		}
+	}
+	skipped, err := s.skippedDates(series, rule, last)
+	if err != nil {
+		return err
+	}
+	exDates = append(exDates, skipped...)
+	sort.Slice(exDates, func(i, j int) bool { return exDates[i].Before(exDates[j]) })
+
+	uid := icsSeriesUid(series.RecurrenceId)
+	w.line("BEGIN:VEVENT")
+	writeEventProperties(w, template, uid, now, loc)
+	ruleValue := rule.RRule()
+	if !rule.Until.IsZero() {
+		until, err := occurrenceStart(rule.Until)
+		if err != nil {
+			return err
+		}
+		rule.Until = time.Time{}
+		ruleValue = rule.RRule() + ";UNTIL=" + until.UTC().Format("20060102T150405Z")
+	}
+	w.line("RRULE:" + ruleValue)
+	for _, date := range series.RDates {
+		instant, err := occurrenceStart(date)
+		if err != nil {
+			return err
+		}
+		w.line("RDATE;TZID=" + loc.String() + ":" + icsLocalTime(instant, loc))
+	}
+	for _, date := range exDates {
+		instant, err := occurrenceStart(date)
+		if err != nil {
+			return err
+		}
+		w.line("EXDATE;TZID=" + loc.String() + ":" + icsLocalTime(instant, loc))
+	}
+	w.line("END:VEVENT")
+
+	for _, exception := range series.Exceptions {
+		if exception.IsCancelled || !exception.Date.After(last) {
+			continue
+		}
+		override := *template
+		override.Name = exception.Name
+		override.IsHaveEndTime = exception.IsHaveEndTime
+		override.StartAt, override.EndAt, err = scheduleInstants(exception.NewDate.Format("02-01-2006"), exception.StartTime, exception.EndTime, loc)
+		if err != nil {
+			return err
+		}
+		recurrenceStart, err := occurrenceStart(exception.Date)
+		if err != nil {
+			return err
+		}
+		err = writeScheduleEvent(w, &override, uid, "", recurrenceStart, loc, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skippedDates lists the dates after last, up to a year on, that the rule of
+// series gives but that it leaves out as non-working days. Clients expand the
+// RRULE on their own, so these have to be written out as EXDATEs.
+func (s *icalendarService) skippedDates(series *repository.RecurrenceSeries, rule *repository.RecurrenceRule, last time.Time) ([]time.Time, error) {
+	if !series.SkipHolidays && !series.WorkingDaysOnly {
+		return nil, nil
+	}
+	from := last.AddDate(0, 0, 1)
+	through := last.AddDate(1, 0, 0)
+	if !series.EndDate.IsZero() && series.EndDate.Before(through) {
+		through = series.EndDate
+	}
+	if through.Before(from) {
+		return nil, nil
+	}
+
+	days, err := s.calendarSrv.NonWorkingDays(series.GoogleId, from, through, series.WorkingDaysOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get non-working days: %v", err)
+	}
+	var dates []time.Time
+	for _, date := range rule.Between(series.StartDate, from, through) {
+		if days[date] && !isSeriesRDate(series, date) && seriesException(series, date) == nil {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+// icsTimezoneRange is the span the VTIMEZONE has to cover: from the year of
+// the first schedule or series to icsTimezoneYears ahead, or past the last
+// schedule if that is later.
+func icsTimezoneRange(schedules []*repository.Schedule, seriesById map[int]*repository.RecurrenceSeries, now time.Time) (time.Time, time.Time) {
+	first, last := now, now.AddDate(icsTimezoneYears, 0, 0)
+	for _, schedule := range schedules {
+		if schedule.Date.IsZero() {
+			continue
+		}
+		if schedule.Date.Before(first) {
+			first = schedule.Date
+		}
+		if schedule.Date.After(last) {
+			last = schedule.Date
+		}
+	}
+	for _, series := range seriesById {
+		if series != nil && !series.StartDate.IsZero() && series.StartDate.Before(first) {
+			first = series.StartDate
+		}
+	}
+	return time.Date(first.Year(), time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(last.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// groupMainSchedule is the schedule a group was created for, the one that is
+// neither its travel leg nor one of its routines.
+func groupMainSchedule(group []*repository.Schedule) *repository.Schedule {
+	for _, schedule := range group {
+		if !schedule.IsTraveling && schedule.RoutineId == "" {
+			return schedule
+		}
+	}
+	return group[len(group)-1]
+}
+
+func icsGroupUid(groupId int) string {
+	return "group-" + strconv.Itoa(groupId) + "@etalert"
+}
+
+func icsSeriesUid(recurrenceId int) string {
+	return "recurrence-" + strconv.Itoa(recurrenceId) + "@etalert"
+}
+
+// writeScheduleEvent writes schedule as a VEVENT. A non-empty parentUid
+// relates it to the event of its group, and a non-zero recurrenceStart makes
+// it the override of that occurrence of the series uid names.
+func writeScheduleEvent(w *icsWriter, schedule *repository.Schedule, uid string, parentUid string, recurrenceStart time.Time, loc *time.Location, now time.Time) error {
+	startAt, endAt, err := scheduleSpan(schedule, loc)
+	if err != nil {
+		return fmt.Errorf("failed to read schedule time: %v", err)
+	}
+	event := *schedule
+	event.StartAt, event.EndAt = startAt, endAt
+
+	w.line("BEGIN:VEVENT")
+	writeEventProperties(w, &event, uid, now, loc)
+	if !recurrenceStart.IsZero() {
+		w.line("RECURRENCE-ID;TZID=" + loc.String() + ":" + icsLocalTime(recurrenceStart, loc))
+	}
+	if parentUid != "" {
+		w.line("RELATED-TO;RELTYPE=PARENT:" + parentUid)
+	}
+	w.line("END:VEVENT")
+	return nil
+}
+
+// writeEventProperties writes what every event has, from schedule's name,
+// instants and places. Travel legs are placed at their origin, everything
+// else at its destination; what iCalendar has no property for is written as
+// X-ETALERT properties.
+func writeEventProperties(w *icsWriter, schedule *repository.Schedule, uid string, now time.Time, loc *time.Location) {
+	w.line("UID:" + uid)
+	w.line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+	w.line("DTSTART;TZID=" + loc.String() + ":" + icsLocalTime(schedule.StartAt, loc))
+	if !schedule.EndAt.IsZero() {
+		w.line("DTEND;TZID=" + loc.String() + ":" + icsLocalTime(schedule.EndAt, loc))
+	}
+	w.line("SUMMARY:" + icsText(schedule.Name))
+	if schedule.GroupId != 0 {
+		w.line("X-ETALERT-GROUP-ID:" + strconv.Itoa(schedule.GroupId))
+	}
+
+	switch {
+	case schedule.IsTraveling:
+		w.line("CATEGORIES:TRAVEL")
+		w.line("LOCATION:" + icsText(schedule.OriName))
+		w.line("GEO:" + icsGeo(schedule.OriLatitude, schedule.OriLongitude))
+		w.line("X-ETALERT-DESTINATION:" + icsText(schedule.DestName))
+		w.line("X-ETALERT-DESTINATION-GEO:" + icsGeo(schedule.DestLatitude, schedule.DestLongitude))
+	case schedule.RoutineId != "":
+		w.line("CATEGORIES:ROUTINE")
+		w.line("X-ETALERT-ROUTINE-ID:" + schedule.RoutineId)
+	default:
+		if schedule.IsHaveLocation {
+			w.line("LOCATION:" + icsText(schedule.DestName))
+			w.line("GEO:" + icsGeo(schedule.DestLatitude, schedule.DestLongitude))
+			w.line("X-ETALERT-ORIGIN:" + icsText(schedule.OriName))
+			w.line("X-ETALERT-ORIGIN-GEO:" + icsGeo(schedule.OriLatitude, schedule.OriLongitude))
+			w.line("X-ETALERT-TRANSPORTATION:" + icsText(schedule.Transportation))
+		}
+		if schedule.TagId != "" {
+			w.line("X-ETALERT-TAG-ID:" + icsText(schedule.TagId))
+		}
+		w.line("X-ETALERT-PRIORITY:" + strconv.Itoa(schedule.Priority))
+	}
+}
+
+func icsLocalTime(instant time.Time, loc *time.Location) string {
+	return instant.In(loc).Format("20060102T150405")
+}
+
+func icsGeo(latitude float64, longitude float64) string {
+	return strconv.FormatFloat(latitude, 'f', 6, 64) + ";" + strconv.FormatFloat(longitude, 'f', 6, 64)
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsText(value string) string {
+	return icsTextEscaper.Replace(value)
+}
+
+// icsWriter collects content lines, folded at 75 octets as RFC 5545 asks
+// without splitting UTF-8 sequences, and ended with CRLF.
+type icsWriter struct {
+	b strings.Builder
+}
+
+func (w *icsWriter) line(value string) {
+	const maxOctets = 75
+	limit := maxOctets
+	for len(value) > limit {
+		cut := limit
+		for cut > 0 && value[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(value[:cut])
+		w.b.WriteString("\r\n ")
+		value = value[cut:]
+		// The leading space counts towards the next line
+		limit = maxOctets - 1
+	}
+	w.b.WriteString(value)
+	w.b.WriteString("\r\n")
+}
+
+func (w *icsWriter) String() string {
+	return w.b.String()
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"etalert-backend/repository"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// The fakes embed the interfaces they stand in for, so calling anything the
+// export does not use panics.
+type fakeExportSchedules struct {
+	repository.ScheduleRepository
+	schedules []*repository.Schedule
+}
+
+func (r *fakeExportSchedules) GetAllSchedules(gId string, date string) ([]*repository.Schedule, error) {
+	return r.schedules, nil
+}
+
+type fakeExportSeries struct {
+	repository.RecurrenceSeriesRepository
+	series *repository.RecurrenceSeries
+}
+
+func (r *fakeExportSeries) GetSeriesByRecurrenceId(recurrenceId int) (*repository.RecurrenceSeries, error) {
+	if r.series.RecurrenceId != recurrenceId {
+		return nil, nil
+	}
+	return r.series, nil
+}
+
+type fakeExportUsers struct {
+	repository.UserRepository
+	timeZone string
+}
+
+func (r *fakeExportUsers) GetUserInfo(googleId string) (*repository.User, error) {
+	return &repository.User{GoogleId: googleId, TimeZone: r.timeZone}, nil
+}
+
+// fakeNonWorkingDays has the same days off for everyone.
+type fakeNonWorkingDays struct {
+	CalendarService
+	days []time.Time
+}
+
+func (c *fakeNonWorkingDays) NonWorkingDays(googleId string, from time.Time, to time.Time, weekends bool) (map[time.Time]bool, error) {
+	days := make(map[time.Time]bool)
+	for _, day := range c.days {
+		if !day.Before(from) && !day.After(to) {
+			days[day] = true
+		}
+	}
+	return days, nil
+}
+
+func date(value string) time.Time {
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// occurrence is a materialized occurrence of series running from start to
+// end on day, alone in its group.
+func occurrence(t *testing.T, series *repository.RecurrenceSeries, groupId int, day string, start string, end string, loc *time.Location) *repository.Schedule {
+	t.Helper()
+	startAt, endAt, err := scheduleInstants(date(day).Format("02-01-2006"), start, end, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule := &repository.Schedule{
+		Id:             "schedule-" + day,
+		GoogleId:       series.GoogleId,
+		Name:           series.Name,
+		IsHaveEndTime:  true,
+		GroupId:        groupId,
+		Recurrence:     series.Recurrence,
+		RecurrenceId:   series.RecurrenceId,
+		OccurrenceDate: date(day),
+	}
+	setScheduleSpan(schedule, startAt, endAt, loc)
+	return schedule
+}
+
+// A weekly series materialized through March 2026, with a Saturday added, one
+// Monday cancelled and one moved to the Tuesday, and more such changes past
+// what is materialized.
+func TestExportSeriesRoundTrip(t *testing.T) {
+	const zone = "Asia/Bangkok"
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Long enough, in three-byte characters, to be folded
+	name := "ประชุมทีมประจำสัปดาห์ที่สำนักงานใหญ่"
+	series := &repository.RecurrenceSeries{
+		RecurrenceId:      7,
+		GoogleId:          "user-1",
+		Name:              name,
+		StartTime:         "09:00",
+		EndTime:           "10:00",
+		IsHaveEndTime:     true,
+		Recurrence:        "FREQ=WEEKLY;BYDAY=MO",
+		SkipHolidays:      true,
+		StartDate:         date("2026-03-02"),
+		MaterializedUntil: date("2026-03-30"),
+		RDates:            []time.Time{date("2026-03-07")},
+		Exceptions: []repository.RecurrenceException{
+			{Date: date("2026-03-16"), IsCancelled: true},
+			{Date: date("2026-03-23"), NewDate: date("2026-03-24"), Name: "Moved", StartTime: "11:00", EndTime: "12:00", IsHaveEndTime: true},
+			{Date: date("2026-04-13"), NewDate: date("2026-04-14"), Name: "Moved later", StartTime: "10:00", EndTime: "11:00", IsHaveEndTime: true},
+			{Date: date("2026-04-20"), IsCancelled: true},
+		},
+	}
+
+	moved := occurrence(t, series, 3, "2026-03-24", "11:00", "12:00", loc)
+	moved.Name = "Moved"
+	moved.OccurrenceDate = date("2026-03-23")
+	moved.IsException = true
+	schedules := []*repository.Schedule{
+		occurrence(t, series, 1, "2026-03-02", "09:00", "10:00", loc),
+		occurrence(t, series, 2, "2026-03-07", "09:00", "10:00", loc),
+		occurrence(t, series, 4, "2026-03-09", "09:00", "10:00", loc),
+		moved,
+		occurrence(t, series, 5, "2026-03-30", "09:00", "10:00", loc),
+	}
+
+	// Mondays: a holiday after the materialized ones, one within a year of
+	// them and one past that
+	calendar := &fakeNonWorkingDays{days: []time.Time{date("2026-04-06"), date("2027-03-01"), date("2027-04-05")}}
+	service := NewICalendarService(&fakeExportSchedules{schedules: schedules}, &fakeExportSeries{series: series}, &fakeExportUsers{timeZone: zone}, nil, nil, nil, calendar)
+
+	exported, err := service.ExportSchedules("user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(exported, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+	}
+
+	events, err := repository.ParseICSEvents([]byte(exported))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var master *repository.ICSEvent
+	overrides := make(map[string]*repository.ICSEvent)
+	for i := range events {
+		event := &events[i]
+		if event.Value("UID") != "recurrence-7@etalert" {
+			t.Errorf("unexpected event %q", event.Value("UID"))
+			continue
+		}
+		if recurrenceId := event.Get("RECURRENCE-ID"); recurrenceId != nil {
+			overrides[recurrenceId.Value] = event
+		} else if master != nil {
+			t.Error("series written twice")
+		} else {
+			master = event
+		}
+	}
+	if master == nil {
+		t.Fatal("series not written")
+	}
+
+	if summary := master.Value("SUMMARY"); summary != name {
+		t.Errorf("SUMMARY %q, want %q", summary, name)
+	}
+	if start := master.Get("DTSTART"); start.Value != "20260302T090000" || start.Params["TZID"] != zone {
+		t.Errorf("DTSTART %+v", start)
+	}
+	if rule := master.Value("RRULE"); rule != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("RRULE %q", rule)
+	}
+
+	values := func(name string) []string {
+		var values []string
+		for _, property := range master.All(name) {
+			if property.Params["TZID"] != zone {
+				t.Errorf("%s in %q, want %s", name, property.Params["TZID"], zone)
+			}
+			values = append(values, property.Value)
+		}
+		return values
+	}
+	if rDates := values("RDATE"); !reflect.DeepEqual(rDates, []string{"20260307T090000"}) {
+		t.Errorf("RDATE %v", rDates)
+	}
+	// The cancelled Mondays and the holiday within a year, but not the moved
+	// Monday, which an override replaces
+	wantExDates := []string{"20260316T090000", "20260406T090000", "20260420T090000", "20270301T090000"}
+	if exDates := values("EXDATE"); !reflect.DeepEqual(exDates, wantExDates) {
+		t.Errorf("EXDATE %v, want %v", exDates, wantExDates)
+	}
+
+	wantOverrides := []struct {
+		recurrenceId string
+		summary      string
+		start        string
+		end          string
+	}{
+		{"20260323T090000", "Moved", "20260324T110000", "20260324T120000"},
+		{"20260413T090000", "Moved later", "20260414T100000", "20260414T110000"},
+	}
+	if len(overrides) != len(wantOverrides) {
+		t.Errorf("got %d overrides, want %d", len(overrides), len(wantOverrides))
+	}
+	for _, want := range wantOverrides {
+		override := overrides[want.recurrenceId]
+		if override == nil {
+			t.Errorf("no override for %s", want.recurrenceId)
+			continue
+		}
+		if override.Get("RECURRENCE-ID").Params["TZID"] != zone {
+			t.Errorf("override for %s: RECURRENCE-ID in %q", want.recurrenceId, override.Get("RECURRENCE-ID").Params["TZID"])
+		}
+		if override.Value("SUMMARY") != want.summary || override.Value("DTSTART") != want.start || override.Value("DTEND") != want.end {
+			t.Errorf("override for %s: %q from %s to %s, want %q from %s to %s", want.recurrenceId,
+				override.Value("SUMMARY"), override.Value("DTSTART"), override.Value("DTEND"), want.summary, want.start, want.end)
+		}
+	}
+}
+
+func TestICSWriterFolds(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "SUMMARY:Standup"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"ascii", "DESCRIPTION:" + strings.Repeat("0123456789", 20)},
+		// Two-, three- and four-byte characters that straddle the limit
+		{"two bytes", "SUMMARY:" + strings.Repeat("é", 80)},
+		{"three bytes", "SUMMARY:" + strings.Repeat("ก", 60)},
+		{"four bytes", "SUMMARY:x" + strings.Repeat("😀", 40)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &icsWriter{}
+			w.line(test.value)
+			output := w.String()
+			if !strings.HasSuffix(output, "\r\n") {
+				t.Fatalf("line not ended with CRLF: %q", output)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n")
+			var unfolded strings.Builder
+			for i, line := range lines {
+				if len(line) > 75 {
+					t.Errorf("line %d has %d octets", i, len(line))
+				}
+				if i > 0 {
+					if !strings.HasPrefix(line, " ") {
+						t.Fatalf("continuation line %d does not start with a space: %q", i, line)
+					}
+					line = line[1:]
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a character: %q", i, line)
+				}
+				unfolded.WriteString(line)
+			}
+			if unfolded.String() != test.value {
+				t.Errorf("unfolds to %q, want %q", unfolded.String(), test.value)
+			}
+			if len(test.value) <= 75 && len(lines) != 1 {
+				t.Errorf("folded a line of %d octets", len(test.value))
+			}
+		})
+	}
+}
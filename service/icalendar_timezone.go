@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// Years past the current one the VTIMEZONE lists offset changes for, so
+// recurring events keep their clock times across DST in clients that only
+// read the calendar's own definition of the zone
+const icsTimezoneYears = 10
+
+// writeTimezone writes the VTIMEZONE the TZID parameters refer to: the
+// offset of loc at from, taken to hold since 1970 as calendar apps write it,
+// then every change of it until to as an observance of its own.
+func writeTimezone(w *icsWriter, loc *time.Location, from time.Time, to time.Time) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+	start := from.In(loc)
+	writeObservance(w, start, start, "19700101T000000")
+	for _, transition := range zoneTransitions(loc, from, to) {
+		before := transition.Add(-time.Second).In(loc)
+		_, offsetFrom := before.Zone()
+		// The onset is given in the local time before the change
+		onset := transition.In(time.FixedZone("", offsetFrom)).Format("20060102T150405")
+		writeObservance(w, before, transition.In(loc), onset)
+	}
+	w.line("END:VTIMEZONE")
+}
+
+// writeObservance writes the offset in force at at, which took over from
+// the one in force at before on onset.
+func writeObservance(w *icsWriter, before time.Time, at time.Time, onset string) {
+	name, offset := at.Zone()
+	_, offsetFrom := before.Zone()
+	component := "STANDARD"
+	if at.IsDST() {
+		component = "DAYLIGHT"
+	}
+
+	w.line("BEGIN:" + component)
+	w.line("DTSTART:" + onset)
+	w.line("TZOFFSETFROM:" + icsOffset(offsetFrom))
+	w.line("TZOFFSETTO:" + icsOffset(offset))
+	w.line("TZNAME:" + icsText(name))
+	w.line("END:" + component)
+}
+
+// zoneTransitions lists the instants between from and to at which the
+// offset of loc changes. time.Location does not expose them, so the range is
+// scanned a day at a time and each change narrowed down to the second.
+func zoneTransitions(loc *time.Location, from time.Time, to time.Time) []time.Time {
+	offsetAt := func(unix int64) int {
+		_, offset := time.Unix(unix, 0).In(loc).Zone()
+		return offset
+	}
+
+	var transitions []time.Time
+	const day = 24 * 60 * 60
+	for low := from.Unix(); low < to.Unix(); low += day {
+		high := low + day
+		before := offsetAt(low)
+		if offsetAt(high) == before {
+			continue
+		}
+		// The old offset holds at start, the new one at end
+		start, end := low, high
+		for end-start > 1 {
+			mid := start + (end-start)/2
+			if offsetAt(mid) == before {
+				start = mid
+			} else {
+				end = mid
+			}
+		}
+		transitions = append(transitions, time.Unix(end, 0))
+	}
+	return transitions
+}
+
+// icsOffset formats a UTC offset in seconds as iCalendar's +hhmm, or
+// +hhmmss for the odd historical offset.
+func icsOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	hours, minutes, seconds := offset/3600, offset/60%60, offset%60
+	if seconds != 0 {
+		return fmt.Sprintf("%s%02d%02d%02d", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, hours, minutes)
+}
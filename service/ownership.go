@@ -0,0 +1,14 @@
+package service
+
+// OwnershipService resolves the googleId that owns a stored resource so
+// protected routes can compare it with the session user. Every lookup
+// returns ErrResourceNotFound when the resource does not exist.
+type OwnershipService interface {
+	GetScheduleOwner(id string) (string, error)
+	GetGroupOwner(groupId string) (string, error)
+	GetRecurrenceOwner(recurrenceId string) (string, error)
+	GetRoutineOwner(id string) (string, error)
+	GetRoutineLogOwner(id string) (string, error)
+	GetTagOwner(id string) (string, error)
+	GetDayOffOwner(id string) (string, error)
+}
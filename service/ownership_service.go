@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"etalert-backend/repository"
+	"strconv"
+)
+
+type ownershipService struct {
+	scheduleRepo   repository.ScheduleRepository
+	routineRepo    repository.RoutineRepository
+	routineLogRepo repository.RoutineLogRepository
+	tagRepo        repository.TagRepository
+	dayOffRepo     repository.DayOffRepository
+}
+
+var ErrResourceNotFound = errors.New("resource not found")
+
+func NewOwnershipService(scheduleRepo repository.ScheduleRepository, routineRepo repository.RoutineRepository, routineLogRepo repository.RoutineLogRepository, tagRepo repository.TagRepository, dayOffRepo repository.DayOffRepository) OwnershipService {
+	return &ownershipService{scheduleRepo: scheduleRepo, routineRepo: routineRepo, routineLogRepo: routineLogRepo, tagRepo: tagRepo, dayOffRepo: dayOffRepo}
+}
+
+func (s *ownershipService) GetScheduleOwner(id string) (string, error) {
+	schedule, err := s.scheduleRepo.GetScheduleById(id)
+	if err != nil {
+		return "", err
+	}
+	if schedule == nil {
+		return "", ErrResourceNotFound
+	}
+	return schedule.GoogleId, nil
+}
+
+func (s *ownershipService) GetGroupOwner(groupId string) (string, error) {
+	id, err := strconv.Atoi(groupId)
+	if err != nil {
+		return "", ErrResourceNotFound
+	}
+	schedules, err := s.scheduleRepo.GetSchedulesByGroupId(id)
+	if err != nil {
+		return "", err
+	}
+	if len(schedules) == 0 {
+		return "", ErrResourceNotFound
+	}
+	return schedules[0].GoogleId, nil
+}
+
+func (s *ownershipService) GetRecurrenceOwner(recurrenceId string) (string, error) {
+	id, err := strconv.Atoi(recurrenceId)
+	if err != nil {
+		return "", ErrResourceNotFound
+	}
+	schedules, err := s.scheduleRepo.GetSchedulesByRecurrenceId(id, "")
+	if err != nil {
+		return "", err
+	}
+	if len(schedules) == 0 {
+		return "", ErrResourceNotFound
+	}
+	return schedules[0].GoogleId, nil
+}
+
+func (s *ownershipService) GetRoutineOwner(id string) (string, error) {
+	routine, err := s.routineRepo.GetRoutineById(id)
+	if err != nil {
+		return "", err
+	}
+	if routine == nil {
+		return "", ErrResourceNotFound
+	}
+	return routine.GoogleId, nil
+}
+
+func (s *ownershipService) GetRoutineLogOwner(id string) (string, error) {
+	routineLog, err := s.routineLogRepo.GetRoutineLogById(id)
+	if err != nil {
+		return "", err
+	}
+	if routineLog == nil {
+		return "", ErrResourceNotFound
+	}
+	return routineLog.GoogleId, nil
+}
+
+func (s *ownershipService) GetTagOwner(id string) (string, error) {
+	tag, err := s.tagRepo.GetTagById(id)
+	if err != nil {
+		return "", err
+	}
+	if tag == nil {
+		return "", ErrResourceNotFound
+	}
+	return tag.GoogleId, nil
+}
+
+func (s *ownershipService) GetDayOffOwner(id string) (string, error) {
+	dayOff, err := s.dayOffRepo.GetDayOffById(id)
+	if err != nil {
+		return "", err
+	}
+	if dayOff == nil {
+		return "", ErrResourceNotFound
+	}
+	return dayOff.GoogleId, nil
+}
@@ -0,0 +1,604 @@
+package service
+
+import (
+	"errors"
+	"etalert-backend/repository"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The parts of a series an edit or delete of one of its occurrences applies to
+const (
+	RecurrenceModeThis      = "this"
+	RecurrenceModeFollowing = "following"
+	RecurrenceModeAll       = "all"
+)
+
+var (
+	ErrInvalidRecurrenceMode = errors.New("invalid recurrence mode")
+	ErrInvalidOccurrence     = errors.New("invalid occurrence date")
+)
+
+// Occurrences of a recurring schedule are kept materialized this many weeks
+// ahead unless RECURRENCE_HORIZON_WEEKS says otherwise.
+const defaultRecurrenceHorizonWeeks = 8
+
+func RecurrenceHorizonFromEnv() (int, error) {
+	raw := os.Getenv("RECURRENCE_HORIZON_WEEKS")
+	if raw == "" {
+		return defaultRecurrenceHorizonWeeks, nil
+	}
+	weeks, err := strconv.Atoi(raw)
+	if err != nil || weeks < 1 {
+		return 0, fmt.Errorf("invalid RECURRENCE_HORIZON_WEEKS: %q", raw)
+	}
+	return weeks, nil
+}
+
+// newRecurrenceSeries is the master of a series created from schedule, with
+// nothing materialized yet.
+func newRecurrenceSeries(schedule *ScheduleInput, startDate time.Time) *repository.RecurrenceSeries {
+	return &repository.RecurrenceSeries{
+		RecurrenceId:      schedule.RecurrenceId,
+		GoogleId:          schedule.GoogleId,
+		Name:              schedule.Name,
+		StartTime:         schedule.StartTime,
+		EndTime:           schedule.EndTime,
+		IsHaveEndTime:     schedule.IsHaveEndTime,
+		OriName:           schedule.OriName,
+		OriLatitude:       schedule.OriLatitude,
+		OriLongitude:      schedule.OriLongitude,
+		DestName:          schedule.DestName,
+		DestLatitude:      schedule.DestLatitude,
+		DestLongitude:     schedule.DestLongitude,
+		Transportation:    schedule.Transportation,
+		Priority:          schedule.Priority,
+		IsHaveLocation:    schedule.IsHaveLocation,
+		IsFirstSchedule:   schedule.IsFirstSchedule,
+		TagId:             schedule.TagId,
+		Recurrence:        schedule.Recurrence,
+		SkipHolidays:      schedule.SkipHolidays,
+		WorkingDaysOnly:   schedule.WorkingDaysOnly,
+		StartDate:         startDate,
+		MaterializedUntil: startDate.AddDate(0, 0, -1),
+	}
+}
+
+func seriesInput(series *repository.RecurrenceSeries) *ScheduleInput {
+	return &ScheduleInput{
+		GoogleId:        series.GoogleId,
+		Name:            series.Name,
+		StartTime:       series.StartTime,
+		EndTime:         series.EndTime,
+		IsHaveEndTime:   series.IsHaveEndTime,
+		OriName:         series.OriName,
+		OriLatitude:     series.OriLatitude,
+		OriLongitude:    series.OriLongitude,
+		DestName:        series.DestName,
+		DestLatitude:    series.DestLatitude,
+		DestLongitude:   series.DestLongitude,
+		Transportation:  series.Transportation,
+		Priority:        series.Priority,
+		IsHaveLocation:  series.IsHaveLocation,
+		IsFirstSchedule: series.IsFirstSchedule,
+		TagId:           series.TagId,
+		Recurrence:      series.Recurrence,
+		RecurrenceId:    series.RecurrenceId,
+		SkipHolidays:    series.SkipHolidays,
+		WorkingDaysOnly: series.WorkingDaysOnly,
+	}
+}
+
+// seriesOccurrence is an occurrence of a series to materialize, placed on
+// the date the series gives it unless an exception moved it.
+type seriesOccurrence struct {
+	Date        time.Time
+	PlacedOn    time.Time
+	IsException bool
+}
+
+// occurrenceDate is the series date a main schedule was materialized for.
+func occurrenceDate(schedule *repository.Schedule) time.Time {
+	if !schedule.OccurrenceDate.IsZero() {
+		return schedule.OccurrenceDate
+	}
+	return schedule.Date
+}
+
+// moveDays moves an instant by days, keeping its wall clock time in loc.
+func moveDays(instant time.Time, days int, loc *time.Location) time.Time {
+	local := instant.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day()+days, local.Hour(), local.Minute(), 0, 0, loc)
+}
+
+func seriesException(series *repository.RecurrenceSeries, date time.Time) *repository.RecurrenceException {
+	for i := range series.Exceptions {
+		if series.Exceptions[i].Date.Equal(date) {
+			return &series.Exceptions[i]
+		}
+	}
+	return nil
+}
+
+// setSeriesException records exception, replacing any earlier one for the
+// same occurrence.
+func setSeriesException(series *repository.RecurrenceSeries, exception repository.RecurrenceException) {
+	if existing := seriesException(series, exception.Date); existing != nil {
+		*existing = exception
+		return
+	}
+	series.Exceptions = append(series.Exceptions, exception)
+}
+
+func removeSeriesException(series *repository.RecurrenceSeries, date time.Time) {
+	exceptions := series.Exceptions[:0]
+	for _, exception := range series.Exceptions {
+		if !exception.Date.Equal(date) {
+			exceptions = append(exceptions, exception)
+		}
+	}
+	series.Exceptions = exceptions
+}
+
+// seriesDates lists the dates of series between from and to, both
+// inclusive: those of its rule and its added dates, in order. Exceptions are
+// left to the caller.
+func (s *scheduleService) seriesDates(series *repository.RecurrenceSeries, from time.Time, to time.Time) ([]time.Time, error) {
+	dates, err := s.scheduleRepo.CalculateNextRecurrenceDate(series.StartDate, series.Recurrence, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[time.Time]bool)
+	for _, date := range dates {
+		seen[date] = true
+	}
+	for _, date := range series.RDates {
+		if !seen[date] && !date.Before(from) && !date.After(to) {
+			seen[date] = true
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates, nil
+}
+
+// materializeSeries inserts the occurrences of series that fall after its
+// MaterializedUntil and within the horizon, then records how far it got.
+// Cancelled occurrences are skipped and edited ones inserted as edited.
+// Occurrences already stored, left by a run that failed before recording its
+// progress, are not inserted again.
+func (s *scheduleService) materializeSeries(series *repository.RecurrenceSeries) error {
+	if series.IsComplete {
+		return nil
+	}
+
+	rule, err := repository.ParseRecurrence(series.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	loc := userLocation(s.userRepo, series.GoogleId)
+	through := localDate(time.Now(), loc).AddDate(0, 0, 7*s.horizonWeeks)
+	if !series.EndDate.IsZero() && series.EndDate.Before(through) {
+		through = series.EndDate
+	}
+	if !through.After(series.MaterializedUntil) {
+		return nil
+	}
+
+	dates, err := s.seriesDates(series, series.MaterializedUntil.AddDate(0, 0, 1), through)
+	if err != nil {
+		return err
+	}
+	nonWorkingDays, err := s.seriesNonWorkingDays(series, series.MaterializedUntil.AddDate(0, 0, 1), through)
+	if err != nil {
+		return err
+	}
+	stored, err := s.storedOccurrences(series.RecurrenceId, series.MaterializedUntil.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	var occurrences []seriesOccurrence
+	for _, date := range dates {
+		exception := seriesException(series, date)
+		switch {
+		case stored[date]:
+			continue
+		case exception == nil && nonWorkingDays[date] && !isSeriesRDate(series, date):
+			continue
+		case exception == nil:
+			occurrences = append(occurrences, seriesOccurrence{Date: date, PlacedOn: date})
+		case exception.IsCancelled:
+			continue
+		default:
+			input := seriesInput(series)
+			input.Name = exception.Name
+			input.StartTime = exception.StartTime
+			input.EndTime = exception.EndTime
+			input.IsHaveEndTime = exception.IsHaveEndTime
+			err = s.insertOccurrences(input, []seriesOccurrence{{Date: date, PlacedOn: exception.NewDate, IsException: true}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(occurrences) > 0 {
+		err = s.insertOccurrences(seriesInput(series), occurrences)
+		if err != nil {
+			return err
+		}
+	}
+
+	series.MaterializedUntil = through
+	series.IsComplete = through.Equal(series.EndDate) || rule.EndsBy(series.StartDate, through)
+	for _, date := range series.RDates {
+		if date.After(through) {
+			series.IsComplete = false
+		}
+	}
+	err = s.seriesRepo.UpdateSeries(series)
+	if err != nil {
+		return fmt.Errorf("failed to update recurrence series: %v", err)
+	}
+	return nil
+}
+
+// storedOccurrences lists the occurrence dates of the series recurrenceId
+// that have a main schedule from from on.
+func (s *scheduleService) storedOccurrences(recurrenceId int, from time.Time) (map[time.Time]bool, error) {
+	schedules, err := s.scheduleRepo.GetMainSchedulesByRecurrenceId(recurrenceId, from.Format("02-01-2006"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get materialized occurrences: %v", err)
+	}
+	stored := make(map[time.Time]bool, len(schedules))
+	for _, schedule := range schedules {
+		stored[occurrenceDate(schedule)] = true
+	}
+	return stored, nil
+}
+
+// seriesNonWorkingDays lists the days between from and to that series leaves
+// out, if it leaves out any.
+func (s *scheduleService) seriesNonWorkingDays(series *repository.RecurrenceSeries, from time.Time, to time.Time) (map[time.Time]bool, error) {
+	if !series.SkipHolidays && !series.WorkingDaysOnly {
+		return nil, nil
+	}
+	days, err := s.calendarSrv.NonWorkingDays(series.GoogleId, from, to, series.WorkingDaysOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get non-working days: %v", err)
+	}
+	return days, nil
+}
+
+// isSuppressedOccurrence reports whether the travel checks of the group led
+// by mainSchedule are to be skipped: it is a regular occurrence of a series
+// that leaves out non-working days, and its day became one after it was
+// materialized, such as a day off added later.
+func (s *scheduleService) isSuppressedOccurrence(mainSchedule *repository.Schedule) bool {
+	if mainSchedule.RecurrenceId == 0 || mainSchedule.IsException {
+		return false
+	}
+	series, err := s.seriesRepo.GetSeriesByRecurrenceId(mainSchedule.RecurrenceId)
+	if err != nil {
+		log.Printf("Failed to get recurrence series: %v", err)
+		return false
+	}
+	if series == nil {
+		return false
+	}
+
+	date := occurrenceDate(mainSchedule)
+	if isSeriesRDate(series, date) {
+		return false
+	}
+	days, err := s.seriesNonWorkingDays(series, date, date)
+	if err != nil {
+		log.Printf("Failed to get non-working days: %v", err)
+		return false
+	}
+	return days[date]
+}
+
+// isSeriesRDate reports whether date was added to series by hand, which keeps
+// it even on a holiday.
+func isSeriesRDate(series *repository.RecurrenceSeries, date time.Time) bool {
+	for _, rDate := range series.RDates {
+		if rDate.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// extendRecurrenceSeries runs nightly and moves the horizon of every open
+// series a day further.
+func (s *scheduleService) extendRecurrenceSeries() {
+	before := localDate(time.Now(), time.UTC).AddDate(0, 0, 7*s.horizonWeeks+1)
+	seriesList, err := s.seriesRepo.GetSeriesToMaterialize(before)
+	if err != nil {
+		log.Printf("Failed to get recurrence series: %v", err)
+		return
+	}
+	for _, series := range seriesList {
+		if err := s.materializeSeries(series); err != nil {
+			log.Printf("Failed to materialize recurrence series %d: %v", series.RecurrenceId, err)
+		}
+	}
+}
+
+// endSeries stops series before from, leaving the occurrences before it. A
+// zero from, or one on or before the first date, removes the series.
+func (s *scheduleService) endSeries(series *repository.RecurrenceSeries, from time.Time) error {
+	if from.IsZero() || !from.After(series.StartDate) {
+		err := s.seriesRepo.DeleteSeries(series.RecurrenceId)
+		if err != nil {
+			return fmt.Errorf("failed to delete recurrence series: %v", err)
+		}
+		return nil
+	}
+
+	series.EndDate = from.AddDate(0, 0, -1)
+	if series.MaterializedUntil.After(series.EndDate) {
+		series.MaterializedUntil = series.EndDate
+	}
+	series.IsComplete = true
+	err := s.seriesRepo.UpdateSeries(series)
+	if err != nil {
+		return fmt.Errorf("failed to update recurrence series: %v", err)
+	}
+	return nil
+}
+
+// splitSeries ends series before from and returns the series that carries on
+// from start under recurrenceId with the edited name and times. Its COUNT is
+// what the old series leaves over; nil is returned if nothing is. The
+// occurrences from from on, as far as anything is stored about them, are
+// mapped to their dates in the new series, and their exceptions and added
+// dates move along with them.
+func (s *scheduleService) splitSeries(series *repository.RecurrenceSeries, from time.Time, recurrenceId int, input *ScheduleUpdateInput, start time.Time) (*repository.RecurrenceSeries, map[time.Time]time.Time, error) {
+	rule, err := repository.ParseRecurrence(series.Recurrence)
+	if err != nil {
+		return nil, nil, err
+	}
+	if from.Before(series.StartDate) {
+		from = series.StartDate
+	}
+
+	through := series.MaterializedUntil
+	for _, exception := range series.Exceptions {
+		if exception.Date.After(through) {
+			through = exception.Date
+		}
+	}
+	for _, date := range series.RDates {
+		if date.After(through) {
+			through = date
+		}
+	}
+	oldDates := rule.Between(series.StartDate, from, through)
+
+	if rule.Count > 0 {
+		rule.Count -= len(rule.Between(series.StartDate, series.StartDate, from.AddDate(0, 0, -1)))
+		if rule.Count <= 0 {
+			return nil, map[time.Time]time.Time{}, s.endSeries(series, from)
+		}
+	}
+
+	dateMap := make(map[time.Time]time.Time)
+	for i, date := range rule.Occurrences(start, len(oldDates)) {
+		dateMap[oldDates[i]] = date
+	}
+
+	// Added dates move by as much as the first occurrence does
+	shift := start.Sub(from)
+	if len(oldDates) > 0 {
+		shift = start.Sub(oldDates[0])
+	}
+
+	next := *series
+	next.Id = ""
+	next.RecurrenceId = recurrenceId
+	next.Name = input.Name
+	next.StartTime = input.StartTime
+	next.EndTime = input.EndTime
+	next.IsHaveEndTime = input.IsHaveEndTime
+	next.Recurrence = rule.String()
+	next.StartDate = start
+	next.MaterializedUntil = start.AddDate(0, 0, -1)
+	next.IsComplete = false
+	next.RDates = nil
+	next.Exceptions = nil
+
+	var keptDates []time.Time
+	for _, date := range series.RDates {
+		if date.Before(from) {
+			keptDates = append(keptDates, date)
+			continue
+		}
+		dateMap[date] = date.Add(shift)
+		next.RDates = append(next.RDates, date.Add(shift))
+	}
+
+	var keptExceptions []repository.RecurrenceException
+	for _, exception := range series.Exceptions {
+		if exception.Date.Before(from) {
+			keptExceptions = append(keptExceptions, exception)
+			continue
+		}
+		date, ok := dateMap[exception.Date]
+		if !ok {
+			continue
+		}
+		if !exception.NewDate.IsZero() {
+			exception.NewDate = exception.NewDate.Add(date.Sub(exception.Date))
+		}
+		exception.Date = date
+		next.Exceptions = append(next.Exceptions, exception)
+	}
+	series.RDates = keptDates
+	series.Exceptions = keptExceptions
+
+	err = s.endSeries(series, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &next, dateMap, nil
+}
+
+// recordException stores exception on the series of recurrenceId, if it has
+// a master, and reports whether it did.
+func (s *scheduleService) recordException(recurrenceId int, exception repository.RecurrenceException) (bool, error) {
+	series, err := s.seriesRepo.GetSeriesByRecurrenceId(recurrenceId)
+	if err != nil {
+		return false, fmt.Errorf("failed to get recurrence series: %v", err)
+	}
+	if series == nil {
+		return false, nil
+	}
+
+	setSeriesException(series, exception)
+	err = s.seriesRepo.UpdateSeries(series)
+	if err != nil {
+		return false, fmt.Errorf("failed to update recurrence series: %v", err)
+	}
+	return true, nil
+}
+
+// updateOccurrence edits the single occurrence of a series on date. One that
+// is not materialized yet is recorded on the series and edited once it is.
+func (s *scheduleService) updateOccurrence(recurrenceId int, input *ScheduleUpdateInput, date time.Time) error {
+	schedule, err := s.scheduleRepo.GetMainScheduleByOccurrence(recurrenceId, date)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule by occurrence: %v", err)
+	}
+	if schedule != nil {
+		return s.UpdateSchedule(schedule.Id, input)
+	}
+
+	series, err := s.seriesRepo.GetSeriesByRecurrenceId(recurrenceId)
+	if err != nil {
+		return fmt.Errorf("failed to get recurrence series: %v", err)
+	}
+	// Materialized occurrences without a schedule have been cancelled
+	if series == nil || !date.After(series.MaterializedUntil) {
+		return ErrResourceNotFound
+	}
+	dates, err := s.seriesDates(series, date, date)
+	if err != nil {
+		return err
+	}
+	if len(dates) == 0 {
+		return ErrResourceNotFound
+	}
+
+	newDate, err := time.Parse("02-01-2006", input.Date)
+	if err != nil {
+		return fmt.Errorf("failed to parse input schedule date: %v", err)
+	}
+	setSeriesException(series, repository.RecurrenceException{
+		Date:          date,
+		NewDate:       newDate,
+		Name:          input.Name,
+		StartTime:     input.StartTime,
+		EndTime:       input.EndTime,
+		IsHaveEndTime: input.IsHaveEndTime,
+	})
+	err = s.seriesRepo.UpdateSeries(series)
+	if err != nil {
+		return fmt.Errorf("failed to update recurrence series: %v", err)
+	}
+	return nil
+}
+
+// deleteOccurrence cancels the single occurrence of a series on date.
+func (s *scheduleService) deleteOccurrence(recurrenceId int, date time.Time) error {
+	recorded, err := s.recordException(recurrenceId, repository.RecurrenceException{Date: date, IsCancelled: true})
+	if err != nil {
+		return err
+	}
+
+	schedule, err := s.scheduleRepo.GetMainScheduleByOccurrence(recurrenceId, date)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule by occurrence: %v", err)
+	}
+	if schedule == nil {
+		if !recorded {
+			return ErrResourceNotFound
+		}
+		return nil
+	}
+	return s.deleteGroup(schedule)
+}
+
+func (s *scheduleService) AddRecurrenceDate(recurrenceId string, date string) error {
+	id, err := strconv.Atoi(recurrenceId)
+	if err != nil {
+		return fmt.Errorf("invalid recurrenceId: %v", err)
+	}
+	addedDate, err := time.Parse("02-01-2006", date)
+	if err != nil {
+		return ErrInvalidOccurrence
+	}
+
+	series, err := s.seriesRepo.GetSeriesByRecurrenceId(id)
+	if err != nil {
+		return fmt.Errorf("failed to get recurrence series: %v", err)
+	}
+	if series == nil {
+		return ErrResourceNotFound
+	}
+	if addedDate.Before(series.StartDate) || !series.EndDate.IsZero() && addedDate.After(series.EndDate) {
+		return ErrInvalidOccurrence
+	}
+
+	dates, err := s.seriesDates(series, addedDate, addedDate)
+	if err != nil {
+		return err
+	}
+	nonWorkingDays, err := s.seriesNonWorkingDays(series, addedDate, addedDate)
+	if err != nil {
+		return err
+	}
+	exception := seriesException(series, addedDate)
+	// A date the rule gives but the series leaves out as a non-working day
+	// is kept by recording it as an RDate
+	skipped := exception == nil && nonWorkingDays[addedDate] && !isSeriesRDate(series, addedDate)
+	if len(dates) > 0 && !skipped && (exception == nil || !exception.IsCancelled) {
+		return nil
+	}
+	if exception != nil {
+		removeSeriesException(series, addedDate)
+	}
+	if len(dates) == 0 || skipped {
+		series.RDates = append(series.RDates, addedDate)
+	}
+
+	if addedDate.After(series.MaterializedUntil) {
+		series.IsComplete = false
+	} else {
+		// An occurrence whose day became a day off after it was
+		// materialized is still stored, only its checks were suppressed
+		stored, err := s.scheduleRepo.GetMainScheduleByOccurrence(id, addedDate)
+		if err != nil {
+			return fmt.Errorf("failed to get schedule by occurrence: %v", err)
+		}
+		if stored == nil {
+			err = s.insertOccurrences(seriesInput(series), []seriesOccurrence{{Date: addedDate, PlacedOn: addedDate}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = s.seriesRepo.UpdateSeries(series)
+	if err != nil {
+		return fmt.Errorf("failed to update recurrence series: %v", err)
+	}
+	return nil
+}
@@ -23,5 +23,7 @@ type RoutineLogResponse struct {
 type RoutineLogService interface {
 	InsertRoutineLog(routineLog *RoutineLogInput) error
 	GetRoutineLogs(googleId string, date string) ([]*RoutineLogResponse, error)
+	StartRoutineLog(routineLog *RoutineLogInput) (string, error)
+	FinishRoutineLog(id string, actualEndTime string) (*RoutineLogResponse, error)
 	DeleteRoutineLog(id string) error
 }
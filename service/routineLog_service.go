@@ -1,9 +1,14 @@
 package service
 
 import (
+	"errors"
 	"etalert-backend/repository"
+	"fmt"
+	"time"
 )
 
+var ErrRoutineLogFinished = errors.New("routine log already finished")
+
 type routineLogService struct {
 	routineLogRepo repository.RoutineLogRepository
 }
@@ -25,6 +30,66 @@ func (r *routineLogService) InsertRoutineLog(routineLog *RoutineLogInput) error
 	return r.routineLogRepo.InsertRoutineLog(routineLogRepo)
 }
 
+// StartRoutineLog records a routine the user has just begun. The actual end
+// time is filled in by FinishRoutineLog.
+func (r *routineLogService) StartRoutineLog(routineLog *RoutineLogInput) (string, error) {
+	newRoutineLog := &repository.RoutineLog{
+		RoutineId: routineLog.RoutineId,
+		GoogleId:  routineLog.GoogleId,
+		Date:      routineLog.Date,
+		StartTime: routineLog.StartTime,
+		EndTime:   routineLog.EndTime,
+	}
+	err := r.routineLogRepo.InsertRoutineLog(newRoutineLog)
+	if err != nil {
+		return "", err
+	}
+	return newRoutineLog.Id, nil
+}
+
+// FinishRoutineLog sets the actual end time of a started routine and its
+// skewness, the minutes it ran past its planned end.
+func (r *routineLogService) FinishRoutineLog(id string, actualEndTime string) (*RoutineLogResponse, error) {
+	routineLog, err := r.routineLogRepo.GetRoutineLogById(id)
+	if err != nil {
+		return nil, err
+	}
+	if routineLog == nil {
+		return nil, ErrResourceNotFound
+	}
+	if routineLog.ActualEndTime != "" {
+		return nil, ErrRoutineLogFinished
+	}
+
+	endTime, err := time.Parse("15:04", routineLog.EndTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse end time: %v", err)
+	}
+	actualEnd, err := time.Parse("15:04", actualEndTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse actual end time: %v", err)
+	}
+	skewness := int(actualEnd.Sub(endTime).Minutes())
+
+	finished, err := r.routineLogRepo.FinishRoutineLog(id, actualEndTime, skewness)
+	if err != nil {
+		return nil, err
+	}
+	if !finished {
+		return nil, ErrRoutineLogFinished
+	}
+
+	return &RoutineLogResponse{
+		Id:            routineLog.Id,
+		RoutineId:     routineLog.RoutineId,
+		Date:          routineLog.Date,
+		StartTime:     routineLog.StartTime,
+		EndTime:       routineLog.EndTime,
+		ActualEndTime: actualEndTime,
+		Skewness:      skewness,
+	}, nil
+}
+
 func (r *routineLogService) GetRoutineLogs(googleId string, date string) ([]*RoutineLogResponse, error) {
 	routinesLogs, err := r.routineLogRepo.GetRoutineLogs(googleId, date)
 	if err != nil {
@@ -2,27 +2,42 @@ package service
 
 import (
 	"etalert-backend/repository"
+	"etalert-backend/websocket"
 )
 
 type routineService struct {
 	routineRepo repository.RoutineRepository
 	tagRepo     repository.TagRepository
+	hub         *websocket.Hub
 }
 
-func NewRoutineService(routineRepo repository.RoutineRepository, tagRepo repository.TagRepository) RoutineService {
-	return &routineService{routineRepo: routineRepo, tagRepo: tagRepo}
+func NewRoutineService(routineRepo repository.RoutineRepository, tagRepo repository.TagRepository, hub *websocket.Hub) RoutineService {
+	return &routineService{routineRepo: routineRepo, tagRepo: tagRepo, hub: hub}
+}
+
+func (s *routineService) publishRoutineChanged(routine *repository.Routine, action string) {
+	s.hub.Publish(routine.GoogleId, websocket.NewEvent(websocket.EventRoutineChanged, websocket.RoutineChangedPayload{
+		Id:       routine.Id,
+		Action:   action,
+		Name:     routine.Name,
+		Duration: routine.Duration,
+		Order:    routine.Order,
+	}))
 }
 
 func (s routineService) InsertRoutine(routine *RoutineInput) error {
-	err := s.routineRepo.InsertRoutine(&repository.Routine{
+	newRoutine := &repository.Routine{
 		GoogleId: routine.GoogleId,
 		Name:     routine.Name,
 		Duration: routine.Duration,
 		Order:    routine.Order,
-	})
+	}
+	err := s.routineRepo.InsertRoutine(newRoutine)
 	if err != nil {
 		return err
 	}
+
+	s.publishRoutineChanged(newRoutine, websocket.ActionCreated)
 	return nil
 }
 
@@ -53,24 +68,42 @@ func (s *routineService) UpdateRoutine(id string, routine *RoutineUpdateInput) e
 	if err != nil {
 		return err
 	}
-	err = s.routineRepo.UpdateRoutine(id, &repository.Routine{
+	if currentRoutine == nil {
+		return ErrResourceNotFound
+	}
+	updatedRoutine := &repository.Routine{
 		Id:       currentRoutine.Id,
 		GoogleId: currentRoutine.GoogleId,
 		Name:     routine.Name,
 		Duration: routine.Duration,
 		Order:    routine.Order,
-	})
+	}
+	err = s.routineRepo.UpdateRoutine(id, updatedRoutine)
 	if err != nil {
 		return err
 	}
+
+	s.publishRoutineChanged(updatedRoutine, websocket.ActionUpdated)
 	return nil
 }
 
 func (s *routineService) DeleteRoutine(id string) error {
-	err := s.routineRepo.DeleteRoutine(id)
+	currentRoutine, err := s.routineRepo.GetRoutineById(id)
+	if err != nil {
+		return err
+	}
+	if currentRoutine == nil {
+		return ErrResourceNotFound
+	}
+
+	err = s.routineRepo.DeleteRoutine(id)
 	if err != nil {
 		return err
 	}
+	s.hub.Publish(currentRoutine.GoogleId, websocket.NewEvent(websocket.EventRoutineChanged, websocket.RoutineChangedPayload{
+		Id:     currentRoutine.Id,
+		Action: websocket.ActionDeleted,
+	}))
 
 	tag, err := s.tagRepo.GetTagByRoutineId(id)
 	if err != nil {
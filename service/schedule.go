@@ -1,5 +1,7 @@
 package service
 
+import "time"
+
 type ScheduleInput struct {
 	GoogleId        string  `bson:"googleId"`
 	RoutineId       string  `bson:"routineId"`
@@ -24,8 +26,10 @@ type ScheduleInput struct {
 	DepartTime      string  `bson:"departTime"`
 	TagId           string  `bson:"tagId"`
 
-	Recurrence   string `bson:"recurrence"`
-	RecurrenceId int    `bson:"recurrenceId"`
+	Recurrence      string `bson:"recurrence"`
+	RecurrenceId    int    `bson:"recurrenceId"`
+	SkipHolidays    bool   `bson:"skipHolidays"`
+	WorkingDaysOnly bool   `bson:"workingDaysOnly"`
 }
 
 type ScheduleResponse struct {
@@ -53,6 +57,9 @@ type ScheduleResponse struct {
 
 	Recurrence   string `bson:"recurrence"`
 	RecurrenceId int    `bson:"recurrenceId"`
+
+	StartAt time.Time `bson:"startAt"`
+	EndAt   time.Time `bson:"endAt"`
 }
 
 type ScheduleUpdateInput struct {
@@ -87,7 +94,11 @@ type ScheduleService interface {
 	GetSchedulesByGroupId(groupId string) ([]string, error)
 	GetSchedulesIdByRecurrenceId(recurrenceId string, date string) ([]string, error)
 	UpdateSchedule(id string, schedule *ScheduleUpdateInput) error
-	UpdateScheduleByRecurrenceId(recurrenceId string, schedule *ScheduleUpdateInput, date string) error
+	UpdateScheduleByRecurrenceId(recurrenceId string, schedule *ScheduleUpdateInput, date string, mode string) error
 	DeleteSchedule(groupId string) error
-	DeleteScheduleByRecurrenceId(recurrenceId string, date string) error
+	DeleteScheduleByRecurrenceId(recurrenceId string, date string, mode string) error
+	AddRecurrenceDate(recurrenceId string, date string) error
+	SnoozeSchedule(id string, minutes int) error
+	LeaveNow(id string) error
+	MigrateScheduleInstants() error
 }
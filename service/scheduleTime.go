@@ -0,0 +1,87 @@
+package service
+
+import (
+	"etalert-backend/repository"
+	"fmt"
+	"time"
+)
+
+// scheduleInstants turns a "02-01-2006" date and "15:04" start and end times
+// into instants in loc. An end earlier than the start falls on the next day;
+// an empty end gives a zero end.
+func scheduleInstants(date string, startTime string, endTime string, loc *time.Location) (time.Time, time.Time, error) {
+	startAt, err := localInstant(date, startTime, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse start time: %v", err)
+	}
+	if endTime == "" {
+		return startAt, time.Time{}, nil
+	}
+
+	endAt, err := localInstant(date, endTime, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse end time: %v", err)
+	}
+	if endAt.Before(startAt) {
+		local := endAt.In(loc)
+		endAt = time.Date(local.Year(), local.Month(), local.Day()+1, local.Hour(), local.Minute(), 0, 0, loc)
+	}
+	return startAt, endAt, nil
+}
+
+// scheduleSpan is when a stored schedule runs. Schedules written before
+// instants were stored are read from their date and clock times.
+func scheduleSpan(schedule *repository.Schedule, loc *time.Location) (time.Time, time.Time, error) {
+	if !schedule.StartAt.IsZero() {
+		return schedule.StartAt, schedule.EndAt, nil
+	}
+	return scheduleInstants(schedule.Date.Format("02-01-2006"), schedule.StartTime, schedule.EndTime, loc)
+}
+
+// optionalInstant leaves unset instants, such as the end of a schedule
+// without one, out of JSON.
+func optionalInstant(instant time.Time) *time.Time {
+	if instant.IsZero() {
+		return nil
+	}
+	return &instant
+}
+
+// scheduleLogCheckAt is when the check of scheduleLog is due. Logs written
+// before instants were stored are read in loc.
+func scheduleLogCheckAt(scheduleLog *repository.ScheduleLog, loc *time.Location) (time.Time, error) {
+	if !scheduleLog.CheckAt.IsZero() {
+		return scheduleLog.CheckAt, nil
+	}
+	return localInstant(scheduleLog.Date.Format("02-01-2006"), scheduleLog.CheckTime, loc)
+}
+
+// moveScheduleLog sets the check of groupId to checkAt, in the series
+// recurrenceId. Groups without a log are left alone.
+func moveScheduleLog(scheduleLogRepo repository.ScheduleLogRepository, groupId int, recurrenceId int, checkAt time.Time, loc *time.Location) error {
+	err := scheduleLogRepo.UpdateScheduleLog(groupId, &repository.ScheduleLog{
+		RecurrenceId: recurrenceId,
+		Date:         localDate(checkAt, loc),
+		CheckTime:    checkAt.In(loc).Format("15:04"),
+		CheckAt:      checkAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update schedule log: %v", err)
+	}
+	return nil
+}
+
+// setScheduleSpan stores when schedule runs as instants and, for clients that
+// still read them, as the date it starts on and clock times in loc.
+func setScheduleSpan(schedule *repository.Schedule, startAt time.Time, endAt time.Time, loc *time.Location) {
+	schedule.StartAt = startAt.UTC()
+	schedule.Date = localDate(startAt, loc)
+	schedule.StartTime = startAt.In(loc).Format("15:04")
+
+	schedule.EndAt = time.Time{}
+	schedule.EndTime = ""
+	if !endAt.IsZero() {
+		schedule.EndAt = endAt.UTC()
+		schedule.EndTime = endAt.In(loc).Format("15:04")
+	}
+}
@@ -2,21 +2,16 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"etalert-backend/repository"
 	"etalert-backend/websocket"
 	"fmt"
 	"log"
-	"os"
 	"regexp"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/google/generative-ai-go/genai"
 	"github.com/robfig/cron/v3"
-	"google.golang.org/api/option"
 )
 
 type scheduleService struct {
@@ -25,10 +20,24 @@ type scheduleService struct {
 	routineRepo     repository.RoutineRepository
 	bedtimeRepo     repository.BedtimeRepository
 	tagRepo         repository.TagRepository
+	userRepo        repository.UserRepository
+	hub             *websocket.Hub
+
+	travelTimeProvider repository.TravelTimeProvider
+	travelAdjuster     TravelAdjuster
+	observationRepo    repository.TravelObservationRepository
+
+	seriesRepo   repository.RecurrenceSeriesRepository
+	horizonWeeks int
+	calendarSrv  CalendarService
 }
 
-func NewScheduleService(scheduleRepo repository.ScheduleRepository, scheduleLogRepo repository.ScheduleLogRepository, routineRepo repository.RoutineRepository, bedTimeRepo repository.BedtimeRepository, tagRepo repository.TagRepository) ScheduleService {
-	return &scheduleService{scheduleRepo: scheduleRepo, scheduleLogRepo: scheduleLogRepo, routineRepo: routineRepo, bedtimeRepo: bedTimeRepo, tagRepo: tagRepo}
+// ErrInvalidRecurrence is returned for recurrences that are neither a known
+// keyword nor a supported RRULE.
+var ErrInvalidRecurrence = repository.ErrInvalidRecurrence
+
+func NewScheduleService(scheduleRepo repository.ScheduleRepository, scheduleLogRepo repository.ScheduleLogRepository, routineRepo repository.RoutineRepository, bedTimeRepo repository.BedtimeRepository, tagRepo repository.TagRepository, userRepo repository.UserRepository, travelTimeProvider repository.TravelTimeProvider, travelAdjuster TravelAdjuster, observationRepo repository.TravelObservationRepository, seriesRepo repository.RecurrenceSeriesRepository, horizonWeeks int, calendarSrv CalendarService, hub *websocket.Hub) ScheduleService {
+	return &scheduleService{scheduleRepo: scheduleRepo, scheduleLogRepo: scheduleLogRepo, routineRepo: routineRepo, bedtimeRepo: bedTimeRepo, tagRepo: tagRepo, userRepo: userRepo, travelTimeProvider: travelTimeProvider, travelAdjuster: travelAdjuster, observationRepo: observationRepo, seriesRepo: seriesRepo, horizonWeeks: horizonWeeks, calendarSrv: calendarSrv, hub: hub}
 }
 
 func parseDuration(durationText string) (time.Duration, error) {
@@ -62,13 +71,27 @@ func parseDuration(durationText string) (time.Duration, error) {
 	return time.Duration(totalMinutes) * time.Minute, nil
 }
 
+// parseDepartTime reads the departure time clients send with a schedule: a
+// Unix timestamp in seconds, or "now" / empty for leaving now.
+func parseDepartTime(departTime string) time.Time {
+	seconds, err := strconv.ParseInt(departTime, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
 func (s *scheduleService) StartCronJob() {
 	c := cron.New()
 	c.AddFunc("@every 1m", func() {
 		fmt.Println("Checking upcoming schedules...")
 		s.autoUpdateSchedules()
 	})
+	c.AddFunc("@daily", s.extendRecurrenceSeries)
 	c.Start()
+
+	// Catch up on nights the server was down
+	go s.extendRecurrenceSeries()
 }
 
 func (s *scheduleService) autoUpdateSchedules() {
@@ -78,148 +101,152 @@ func (s *scheduleService) autoUpdateSchedules() {
 	}
 	for _, groupId := range groupIds {
 		schedules, err := s.scheduleRepo.GetSchedulesByGroupId(groupId)
-		newStartTime := schedules[0].StartTime
-		newEndTime := newStartTime
-		schedules = schedules[1:]
 		if err != nil {
 			log.Printf("Failed to get schedules by group ID: %v", err)
+			continue
 		}
-		for _, schedule := range schedules {
+		if len(schedules) == 0 {
+			continue
+		}
+		mainSchedule := schedules[0]
+		if s.isSuppressedOccurrence(mainSchedule) {
+			continue
+		}
+		if mainSchedule.Transportation != "walking" && mainSchedule.Transportation != "driving" && mainSchedule.Transportation != "transit" {
+			mainSchedule.Transportation = "driving"
+		}
+
+		loc := userLocation(s.userRepo, mainSchedule.GoogleId)
+		newStartAt, _, err := scheduleSpan(mainSchedule, loc)
+		if err != nil {
+			log.Printf("Failed to read schedule time: %v", err)
+			continue
+		}
+
+		for _, schedule := range schedules[1:] {
 			if schedule.IsUpdated {
 				break
 			}
+			newEndAt := newStartAt
 			if schedule.IsTraveling {
-				if schedules[0].Transportation != "walking" && schedules[0].Transportation != "driving" && schedules[0].Transportation != "transit" {
-					schedules[0].Transportation = "driving"
-				}
-				travelTimeText, err := s.scheduleRepo.GetTravelTime(
-					fmt.Sprintf("%f", schedule.OriLatitude),
-					fmt.Sprintf("%f", schedule.OriLongitude),
-					fmt.Sprintf("%f", schedule.DestLatitude),
-					fmt.Sprintf("%f", schedule.DestLongitude),
-					schedules[0].Transportation,
-					"now",
+				travelTime, err := s.travelTimeProvider.GetTravelTime(
+					schedule.OriLatitude,
+					schedule.OriLongitude,
+					schedule.DestLatitude,
+					schedule.DestLongitude,
+					mainSchedule.Transportation,
+					time.Time{},
 				)
 				if err != nil {
 					log.Printf("Failed to get travel time: %v", err)
 					return
 				}
 
-				var travelDuration time.Duration
-
-				if schedules[0].Transportation == "driving" {
-					traffic, err := s.GetTraffic(fmt.Sprintf("%f", schedule.OriLatitude), fmt.Sprintf("%f", schedule.OriLongitude), fmt.Sprintf("%f", schedule.DestLatitude), fmt.Sprintf("%f", schedule.DestLongitude))
-					if err != nil {
-						log.Printf("Failed to get traffic: %v", err)
-					}
-
-					weather, err := s.GetWeather(fmt.Sprintf("%f", schedule.OriLatitude), fmt.Sprintf("%f", schedule.OriLongitude), fmt.Sprintf("%f", schedule.DestLatitude), fmt.Sprintf("%f", schedule.DestLongitude), travelTimeText)
-					if err != nil {
-						log.Printf("Failed to get weather: %v", err)
-					}
-
-					ctx := context.Background()
-					client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("GEMINI_API_KEY")))
-					if err != nil {
-						log.Fatal(err)
-					}
-					defer client.Close()
-
-					model := client.GenerativeModel("gemini-1.5-flash")
-					resp, err := model.GenerateContent(ctx, genai.Text(fmt.Sprintf(`
-							Based on the following travel details, calculate the adjusted travel time between the two locations:
-						  
-							- Origin coordinates: Latitude %f, Longitude %f
-							- Destination coordinates: Latitude %f, Longitude %f
-							- Estimated travel time from Google Distance Matrix API: %s
-							- Transportation method: %s
-							- Traffic data: %s
-							- Weather data at original location: %s
-							- Weather data at destination: %s
-							
-							Adjust the travel time by accounting for the effects of traffic and weather conditions on the route. Return only the adjusted travel time as a numeric value in minutes, without any additional text or explanation.
-						  `, schedule.OriLatitude, schedule.OriLongitude, schedule.DestLatitude, schedule.DestLongitude, travelTimeText, schedules[0].Transportation, traffic, weather[0], weather[1])))
-
-					if err != nil {
-						log.Fatal(err)
-					}
-
-					geminiTravelTime := strings.TrimSpace(fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])) + " mins"
-
-					travelDuration, err = parseDuration(geminiTravelTime)
-					if err != nil {
-						log.Printf("failed to parse travel duration: %v", err)
-					}
-				} else {
-					travelDuration, err = parseDuration(travelTimeText)
-					if err != nil {
-						log.Printf("failed to parse travel duration: %v", err)
-					}
-				}
-
-				startTime, err := time.Parse("15:04", newStartTime)
-				if err != nil {
-					log.Printf("failed to parse start time: %v", err)
-				}
-
-				newEndTime = newStartTime
-				newStartTime = startTime.Add(-travelDuration).Format("15:04")
-
-				err = s.scheduleRepo.UpdateScheduleTime(schedule.Id, newStartTime, newEndTime)
-				if err != nil {
-					log.Printf("Failed to update schedule time: %v", err)
-				} else {
-					updateMessage := map[string]interface{}{
-						"id":            schedule.Id,
-						"name":          schedule.Name,
-						"date":          schedule.Date,
-						"startTime":     newStartTime,
-						"endTime":       newEndTime,
-						"isHaveEndTime": schedule.IsHaveEndTime,
-					}
-					message, _ := json.Marshal(updateMessage)
-					websocket.SendUpdate(message, schedule.GoogleId)
-				}
-				log.Printf("Updated schedule time for %s from user %s", schedule.Name, schedule.GoogleId)
+				adjustedDuration, adjuster := s.adjustTravelTime(schedule, mainSchedule.Transportation, travelTime)
+				routeKey := repository.TravelRouteKey(schedule.OriLatitude, schedule.OriLongitude, schedule.DestLatitude, schedule.DestLongitude, mainSchedule.Transportation)
+				travelDuration, correction := s.correctTravelTime(schedule.GoogleId, routeKey, adjustedDuration)
+
+				newStartAt = newEndAt.Add(-travelDuration)
+				setScheduleSpan(schedule, newStartAt, newEndAt, loc)
+
+				s.recordTravelObservation(&repository.TravelObservation{
+					GoogleId:         schedule.GoogleId,
+					ScheduleId:       schedule.Id,
+					GroupId:          schedule.GroupId,
+					RouteKey:         routeKey,
+					Transportation:   mainSchedule.Transportation,
+					Date:             schedule.Date,
+					Provider:         travelTime.Provider,
+					Adjuster:         adjuster,
+					ProviderSeconds:  int(travelTime.Duration.Seconds()),
+					AdjustedSeconds:  int(adjustedDuration.Seconds()),
+					Correction:       correction,
+					PredictedSeconds: int(travelDuration.Seconds()),
+				})
 			} else {
-				startTime, err := time.Parse("15:04", schedule.StartTime)
+				duration := 5 * time.Minute
+				startAt, endAt, err := scheduleSpan(schedule, loc)
 				if err != nil {
-					log.Printf("failed to parse start time: %v", err)
-				}
-				endTime := startTime.Add(5 * time.Minute)
-				if schedule.EndTime != "" {
-					endTime, err = time.Parse("15:04", schedule.EndTime)
-					if err != nil {
-						log.Printf("failed to parse end time: %v", err)
-					}
+					log.Printf("Failed to read schedule time: %v", err)
+				} else if !endAt.IsZero() {
+					duration = endAt.Sub(startAt)
 				}
-				duration := endTime.Sub(startTime)
 
-				newEndTime = newStartTime
-				startTimeForNext, _ := time.Parse("15:04", newStartTime)
-				newStartTime = startTimeForNext.Add(-duration).Format("15:04")
+				newStartAt = newEndAt.Add(-duration)
+				setScheduleSpan(schedule, newStartAt, newEndAt, loc)
+			}
 
-				err = s.scheduleRepo.UpdateScheduleTime(schedule.Id, newStartTime, newEndTime)
-				if err != nil {
-					log.Printf("Failed to update schedule time: %v", err)
-				} else {
-					updateMessage := map[string]interface{}{
-						"id":            schedule.Id,
-						"name":          schedule.Name,
-						"date":          schedule.Date,
-						"startTime":     newStartTime,
-						"endTime":       newEndTime,
-						"isHaveEndTime": schedule.IsHaveEndTime,
-					}
-					message, _ := json.Marshal(updateMessage)
-					websocket.SendUpdate(message, schedule.GoogleId)
-				}
-				log.Printf("Updated schedule time for %s from user %s", schedule.Name, schedule.GoogleId)
+			err = s.scheduleRepo.UpdateScheduleTime(schedule.Id, schedule)
+			if err != nil {
+				log.Printf("Failed to update schedule time: %v", err)
+			} else {
+				s.publishScheduleUpdated(schedule, websocket.ReasonTravelTime)
 			}
+			log.Printf("Updated schedule time for %s from user %s", schedule.Name, schedule.GoogleId)
 		}
 	}
 }
 
+// adjustTravelTime applies traffic and weather to a driving estimate. It
+// never fails: without an adjustment the provider's estimate is used as is.
+func (s *scheduleService) adjustTravelTime(schedule *repository.Schedule, transportation string, travelTime *repository.TravelTime) (time.Duration, string) {
+	if transportation != "driving" {
+		return travelTime.Duration, ""
+	}
+
+	conditions := &TravelConditions{
+		OriLatitude:    schedule.OriLatitude,
+		OriLongitude:   schedule.OriLongitude,
+		DestLatitude:   schedule.DestLatitude,
+		DestLongitude:  schedule.DestLongitude,
+		Transportation: transportation,
+		Base:           travelTime,
+	}
+
+	minLat, maxLat := min(schedule.OriLatitude, schedule.DestLatitude)
+	minLon, maxLon := min(schedule.OriLongitude, schedule.DestLongitude)
+	traffic, err := s.scheduleRepo.GetTraffic(minLat, minLon, maxLat, maxLon)
+	if err != nil {
+		log.Printf("Failed to get traffic: %v", err)
+	} else {
+		conditions.Traffic = &traffic
+	}
+
+	forecast, err := s.scheduleRepo.GetWeather(fmt.Sprintf("%f", schedule.OriLatitude), fmt.Sprintf("%f", schedule.OriLongitude), fmt.Sprintf("%f", schedule.DestLatitude), fmt.Sprintf("%f", schedule.DestLongitude), fmt.Sprintf("%.0f", travelTime.Duration.Minutes()))
+	if err != nil {
+		log.Printf("Failed to get weather: %v", err)
+	} else {
+		conditions.Forecast = &forecast
+	}
+
+	adjustment, err := s.travelAdjuster.AdjustTravelTime(context.Background(), conditions)
+	if err != nil {
+		log.Printf("Failed to adjust travel time: %v", err)
+		return travelTime.Duration, ""
+	}
+	return adjustment.Duration, adjustment.Adjuster
+}
+
+// correctTravelTime scales duration by what the user's reported trips on the
+// route have shown so far.
+func (s *scheduleService) correctTravelTime(googleId string, routeKey string, duration time.Duration) (time.Duration, float64) {
+	correction, err := routeCorrection(s.observationRepo, googleId, routeKey)
+	if err != nil {
+		log.Printf("Failed to get travel time correction: %v", err)
+	}
+	if correction == 1 {
+		return duration, correction
+	}
+	return time.Duration(float64(duration) * correction).Round(time.Minute), correction
+}
+
+func (s *scheduleService) recordTravelObservation(observation *repository.TravelObservation) {
+	observation.CreatedAt = time.Now().UTC()
+	if err := s.observationRepo.SaveObservation(observation); err != nil {
+		log.Printf("Failed to record travel observation: %v", err)
+	}
+}
+
 func (s *scheduleService) GetTraffic(oriLat string, oriLong string, destLat string, destLong string) ([]Traffic, error) {
 	oriLatF, err := strconv.ParseFloat(oriLat, 64)
 	if err != nil {
@@ -245,6 +272,10 @@ func (s *scheduleService) GetTraffic(oriLat string, oriLong string, destLat stri
 		return nil, err
 	}
 
+	return trafficDetails(&traffic), nil
+}
+
+func trafficDetails(traffic *repository.TrafficResponse) []Traffic {
 	var trafficDetails []Traffic
 	for _, poi := range traffic.Tm.Poi {
 		if poi.D != "" && poi.C != "" && poi.F != "" && poi.T != "" {
@@ -257,7 +288,7 @@ func (s *scheduleService) GetTraffic(oriLat string, oriLong string, destLat stri
 		}
 	}
 
-	return trafficDetails, nil
+	return trafficDetails
 }
 
 func min(a, b float64) (float64, float64) {
@@ -273,6 +304,10 @@ func (s *scheduleService) GetWeather(oriLat string, oriLong string, destLat stri
 		return nil, err
 	}
 
+	return weatherDetails(&forecasts), nil
+}
+
+func weatherDetails(forecasts *repository.Forecast) []Weather {
 	var weatherDetails []Weather
 
 	for _, waypoint := range forecasts.Waypoints {
@@ -297,10 +332,30 @@ func (s *scheduleService) GetWeather(oriLat string, oriLong string, destLat stri
 		weatherDetails = append(weatherDetails, weather)
 	}
 
-	return weatherDetails, nil
+	return weatherDetails
+}
+
+// checkTag rejects a tag that does not belong to googleId, so a schedule
+// cannot pull in another user's routines.
+func (s *scheduleService) checkTag(googleId string, tagId string) error {
+	if tagId == "" {
+		return nil
+	}
+	tag, err := s.tagRepo.GetTagById(tagId)
+	if err != nil {
+		return fmt.Errorf("failed to get tag: %v", err)
+	}
+	if tag == nil || tag.GoogleId != googleId {
+		return ErrUnknownTag
+	}
+	return nil
 }
 
 func (s *scheduleService) InsertSchedule(schedule *ScheduleInput) (string, error) {
+	if err := s.checkTag(schedule.GoogleId, schedule.TagId); err != nil {
+		return "", err
+	}
+
 	groupId, err := s.scheduleRepo.GetNextGroupId()
 	if err != nil {
 		return "", fmt.Errorf("failed to get next group ID: %v", err)
@@ -313,17 +368,15 @@ func (s *scheduleService) InsertSchedule(schedule *ScheduleInput) (string, error
 	}
 	schedule.RecurrenceId = recurrenceId
 
-	parsedDate, err := time.Parse("02-01-2006", schedule.Date)
+	loc := userLocation(s.userRepo, schedule.GoogleId)
+	startAt, endAt, err := scheduleInstants(schedule.Date, schedule.StartTime, schedule.EndTime, loc)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse date: %v", err)
+		return "", fmt.Errorf("failed to parse schedule time: %v", err)
 	}
 
-	err = s.scheduleRepo.InsertSchedule(&repository.Schedule{
+	mainSchedule := &repository.Schedule{
 		GoogleId:        schedule.GoogleId,
 		Name:            schedule.Name,
-		Date:            parsedDate,
-		StartTime:       schedule.StartTime,
-		EndTime:         schedule.EndTime,
 		IsHaveEndTime:   schedule.IsHaveEndTime,
 		OriName:         schedule.OriName,
 		OriLatitude:     schedule.OriLatitude,
@@ -341,32 +394,35 @@ func (s *scheduleService) InsertSchedule(schedule *ScheduleInput) (string, error
 		TagId:           schedule.TagId,
 		Recurrence:      schedule.Recurrence,
 		RecurrenceId:    schedule.RecurrenceId,
-	})
+	}
+	setScheduleSpan(mainSchedule, startAt, endAt, loc)
+
+	err = s.scheduleRepo.InsertSchedule(mainSchedule)
 	if err != nil {
 		return "", fmt.Errorf("failed to insert schedule: %v", err)
 	}
 
 	var travelDuration time.Duration
+	// Start of the earliest entry of the group inserted so far
+	firstStartAt := startAt
 
 	if schedule.IsHaveLocation {
-		travelDuration, err = s.handleTravelSchedule(schedule)
+		travelDuration, err = s.handleTravelSchedule(schedule, startAt, loc)
 		if err != nil {
 			log.Printf("Failed to handle travel schedule: %v", err)
 		}
+		firstStartAt = startAt.Add(-travelDuration)
 	}
 
 	if schedule.IsFirstSchedule {
-		_, err := s.insertRoutineSchedules(schedule)
+		firstStartAt, err = s.insertRoutineSchedules(schedule, firstStartAt, loc)
 		if err != nil {
 			log.Printf("Failed to insert routines: %v", err)
 		}
 	}
 
 	if schedule.IsHaveLocation {
-		checkTime, err := time.Parse("15:04", schedule.StartTime)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse check time: %v", err)
-		}
+		checkAt := firstStartAt.Add(-travelDuration)
 
 		scheduleLog := &repository.ScheduleLog{
 			GroupId:       schedule.GroupId,
@@ -375,8 +431,9 @@ func (s *scheduleService) InsertSchedule(schedule *ScheduleInput) (string, error
 			OriLongitude:  schedule.OriLongitude,
 			DestLatitude:  schedule.DestLatitude,
 			DestLongitude: schedule.DestLongitude,
-			Date:          parsedDate,
-			CheckTime:     checkTime.Add(-travelDuration).Format("15:04"),
+			Date:          localDate(checkAt, loc),
+			CheckTime:     checkAt.In(loc).Format("15:04"),
+			CheckAt:       checkAt.UTC(),
 		}
 
 		err = s.scheduleLogRepo.InsertScheduleLog(scheduleLog)
@@ -388,59 +445,31 @@ func (s *scheduleService) InsertSchedule(schedule *ScheduleInput) (string, error
 	return "", nil
 }
 
-func (s *scheduleService) handleTravelSchedule(schedule *ScheduleInput) (time.Duration, error) {
-	departureTime := schedule.DepartTime
-	if departureTime == "" {
-		departureTime = "now"
-	}
-
+// handleTravelSchedule inserts the travel entry that ends at arriveAt, the
+// start of the schedule it leads to, and returns how long the trip takes.
+func (s *scheduleService) handleTravelSchedule(schedule *ScheduleInput, arriveAt time.Time, loc *time.Location) (time.Duration, error) {
 	if schedule.Transportation != "walking" && schedule.Transportation != "driving" && schedule.Transportation != "transit" {
 		schedule.Transportation = "driving"
 	}
-	travelTimeText, err := s.scheduleRepo.GetTravelTime(
-		fmt.Sprintf("%f", schedule.OriLatitude),
-		fmt.Sprintf("%f", schedule.OriLongitude),
-		fmt.Sprintf("%f", schedule.DestLatitude),
-		fmt.Sprintf("%f", schedule.DestLongitude),
+	travelTime, err := s.travelTimeProvider.GetTravelTime(
+		schedule.OriLatitude,
+		schedule.OriLongitude,
+		schedule.DestLatitude,
+		schedule.DestLongitude,
 		schedule.Transportation,
-		departureTime,
+		parseDepartTime(schedule.DepartTime),
 	)
 	if err != nil {
 		log.Printf("Failed to get travel time: %v", err)
 		return 0, fmt.Errorf("failed to get travel time: %v", err)
 	}
 
-	startTime, err := time.Parse("15:04", schedule.StartTime)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse start time: %v", err)
-	}
-
-	travelDuration, err := parseDuration(travelTimeText)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse travel duration: %v", err)
-	}
-	leaveTime := startTime.Add(-travelDuration)
-	arriveTime := startTime
-	if leaveTime.Year() < arriveTime.Year() {
-		date, err := time.Parse("02-01-2006", schedule.Date)
-		if err == nil {
-			date = date.AddDate(0, 0, -1)
-			schedule.Date = date.Format("02-01-2006")
-		}
-	}
-	schedule.StartTime = leaveTime.Format("15:04")
-
-	parsedDate, err := time.Parse("02-01-2006", schedule.Date)
-	if err != nil {
-		return 0, err
-	}
+	routeKey := repository.TravelRouteKey(schedule.OriLatitude, schedule.OriLongitude, schedule.DestLatitude, schedule.DestLongitude, schedule.Transportation)
+	travelDuration, correction := s.correctTravelTime(schedule.GoogleId, routeKey, travelTime.Duration)
 
 	leaveSchedule := &repository.Schedule{
 		GoogleId:        schedule.GoogleId,
 		Name:            "Leave From " + schedule.OriName,
-		Date:            parsedDate,
-		StartTime:       schedule.StartTime,
-		EndTime:         arriveTime.Format("15:04"),
 		IsHaveEndTime:   true,
 		OriName:         schedule.OriName,
 		OriLatitude:     schedule.OriLatitude,
@@ -455,30 +484,50 @@ func (s *scheduleService) handleTravelSchedule(schedule *ScheduleInput) (time.Du
 		IsUpdated:       false,
 		RecurrenceId:    schedule.RecurrenceId,
 	}
+	setScheduleSpan(leaveSchedule, arriveAt.Add(-travelDuration), arriveAt, loc)
 
 	err = s.scheduleRepo.InsertSchedule(leaveSchedule)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert leave home schedule: %v", err)
 	}
 
+	s.recordTravelObservation(&repository.TravelObservation{
+		GoogleId:         schedule.GoogleId,
+		ScheduleId:       leaveSchedule.Id,
+		GroupId:          schedule.GroupId,
+		RouteKey:         routeKey,
+		Transportation:   schedule.Transportation,
+		Date:             leaveSchedule.Date,
+		Provider:         travelTime.Provider,
+		ProviderSeconds:  int(travelTime.Duration.Seconds()),
+		AdjustedSeconds:  int(travelTime.Duration.Seconds()),
+		Correction:       correction,
+		PredictedSeconds: int(travelDuration.Seconds()),
+	})
+
 	return travelDuration, nil
 }
 
-func (s *scheduleService) insertRoutineSchedules(schedule *ScheduleInput) (string, error) {
+// insertRoutineSchedules inserts the tag's routines back to back so the last
+// one ends at endAt, and returns when the first one starts.
+func (s *scheduleService) insertRoutineSchedules(schedule *ScheduleInput, endAt time.Time, loc *time.Location) (time.Time, error) {
 	if schedule == nil {
-		return "", fmt.Errorf("schedule cannot be nil")
+		return endAt, fmt.Errorf("schedule cannot be nil")
 	}
 
 	routineLists, err := s.tagRepo.GetRoutinesByTagId(schedule.TagId)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch user routine lists: %v", err)
+		return endAt, fmt.Errorf("failed to fetch user routine lists: %v", err)
 	}
 
 	var routines []*RoutineResponse
 	for _, routineId := range routineLists {
 		routine, err := s.routineRepo.GetRoutineById(routineId)
 		if err != nil {
-			return "", err
+			return endAt, err
+		}
+		if routine == nil {
+			continue
 		}
 		routines = append(routines, &RoutineResponse{
 			Id:       routine.Id,
@@ -488,41 +537,22 @@ func (s *scheduleService) insertRoutineSchedules(schedule *ScheduleInput) (strin
 		})
 	}
 
-	currentStartTime, err := time.Parse("15:04", schedule.StartTime)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse first schedule start time: %v", err)
-	}
+	currentStartAt := endAt
 
 	for i := len(routines) - 1; i >= 0; i-- {
 		routine := routines[i]
 		routineDuration, err := parseDuration(fmt.Sprintf("%d min", routine.Duration))
 		if err != nil {
-			return "", fmt.Errorf("failed to parse routine duration: %v", err)
-		}
-
-		currentEndTime := currentStartTime
-		currentStartTime = currentStartTime.Add(-routineDuration)
-
-		if currentStartTime.Year() < currentEndTime.Year() {
-			date, err := time.Parse("02-01-2006", schedule.Date)
-			if err == nil {
-				date = date.AddDate(0, 0, -1)
-				schedule.Date = date.Format("02-01-2006")
-			}
+			return currentStartAt, fmt.Errorf("failed to parse routine duration: %v", err)
 		}
 
-		parsedDate, err := time.Parse("02-01-2006", schedule.Date)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse schedule date: %v", err)
-		}
+		routineEndAt := currentStartAt
+		currentStartAt = currentStartAt.Add(-routineDuration)
 
 		newRoutineSchedule := &repository.Schedule{
 			GoogleId:        schedule.GoogleId,
 			RoutineId:       routine.Id,
 			Name:            routine.Name,
-			Date:            parsedDate,
-			StartTime:       currentStartTime.Format("15:04"),
-			EndTime:         currentEndTime.Format("15:04"),
 			GroupId:         schedule.GroupId,
 			IsHaveEndTime:   true,
 			IsHaveLocation:  false,
@@ -531,59 +561,79 @@ func (s *scheduleService) insertRoutineSchedules(schedule *ScheduleInput) (strin
 			IsUpdated:       false,
 			RecurrenceId:    schedule.RecurrenceId,
 		}
+		setScheduleSpan(newRoutineSchedule, currentStartAt, routineEndAt, loc)
 
 		err = s.scheduleRepo.InsertSchedule(newRoutineSchedule)
 		if err != nil {
 			log.Printf("Failed to insert routine schedule: %v", err) // Log and continue
-			return "", fmt.Errorf("failed to insert routine schedule: %v", err)
+			return routineEndAt, fmt.Errorf("failed to insert routine schedule: %v", err)
 		}
-		schedule.StartTime = currentStartTime.Format("15:04")
 	}
 
-	return "", nil
+	return currentStartAt, nil
 }
 
 func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (string, error) {
+	if err := s.checkTag(schedule.GoogleId, schedule.TagId); err != nil {
+		return "", err
+	}
+
 	recurrenceId, err := s.scheduleRepo.GetNextRecurrenceId()
 	if err != nil {
 		return "", fmt.Errorf("failed to get next recurrence ID: %v", err)
 	}
 	schedule.RecurrenceId = recurrenceId
 
-	recurrenceCount := map[string]int{
-		"daily":   365,
-		"weekly":  52,
-		"monthly": 12,
-		"yearly":  5,
-	}[schedule.Recurrence]
+	rule, err := repository.ParseRecurrence(schedule.Recurrence)
+	if err != nil {
+		return "", err
+	}
+	schedule.Recurrence = rule.String()
+
+	startDate, err := time.Parse("02-01-2006", schedule.Date)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse start date: %v", err)
+	}
 
-	if recurrenceCount == 0 {
-		return "", fmt.Errorf("invalid recurrence type: %v", schedule.Recurrence)
+	series := newRecurrenceSeries(schedule, startDate)
+	err = s.seriesRepo.InsertSeries(series)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert recurrence series: %v", err)
 	}
 
-	dates, err := s.scheduleRepo.CalculateNextRecurrenceDate(schedule.Date, schedule.Recurrence, recurrenceCount)
+	err = s.materializeSeries(series)
 	if err != nil {
 		return "", err
 	}
 
+	return "", nil
+}
+
+// insertOccurrences inserts a group of schedules for schedule for each of the
+// occurrences, along with the logs that keep their travel up to date.
+func (s *scheduleService) insertOccurrences(schedule *ScheduleInput, occurrences []seriesOccurrence) error {
 	var travelDuration time.Duration
+	var err error
 	if schedule.IsHaveLocation {
 		travelDuration, err = s.calculateTravelDurationOnce(schedule)
 		if err != nil {
-			return "", fmt.Errorf("failed to calculate travel duration: %v", err)
+			return fmt.Errorf("failed to calculate travel duration: %v", err)
 		}
 	}
 
 	routineLists, err := s.tagRepo.GetRoutinesByTagId(schedule.TagId)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch user routine lists: %v", err)
+		return fmt.Errorf("failed to fetch user routine lists: %v", err)
 	}
 
 	var routines []*RoutineResponse
 	for _, routineId := range routineLists {
 		routine, err := s.routineRepo.GetRoutineById(routineId)
 		if err != nil {
-			return "", err
+			return err
+		}
+		if routine == nil {
+			continue
 		}
 		routines = append(routines, &RoutineResponse{
 			Id:       routine.Id,
@@ -593,28 +643,28 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 		})
 	}
 
+	loc := userLocation(s.userRepo, schedule.GoogleId)
+
 	const batchSize = 100
 	allSchedules := make([]repository.Schedule, 0)
-	scheduleLogs := make([]repository.ScheduleLog, 0, len(dates))
+	scheduleLogs := make([]repository.ScheduleLog, 0, len(occurrences))
 
-	for _, date := range dates {
+	for _, occurrence := range occurrences {
+		date := occurrence.PlacedOn.Format("02-01-2006")
 		groupId, err := s.scheduleRepo.GetNextGroupId()
 		if err != nil {
-			return "", fmt.Errorf("failed to get next group ID: %v", err)
+			return fmt.Errorf("failed to get next group ID: %v", err)
 		}
 		schedule.GroupId = groupId
 
-		parsedDate, err := time.Parse("02-01-2006", date)
+		startAt, endAt, err := scheduleInstants(date, schedule.StartTime, schedule.EndTime, loc)
 		if err != nil {
-			return "", fmt.Errorf("failed to parse schedule date: %v", err)
+			return fmt.Errorf("failed to parse schedule time: %v", err)
 		}
 
 		mainSchedule := repository.Schedule{
 			GoogleId:        schedule.GoogleId,
 			Name:            schedule.Name,
-			Date:            parsedDate,
-			StartTime:       schedule.StartTime,
-			EndTime:         schedule.EndTime,
 			IsHaveEndTime:   schedule.IsHaveEndTime,
 			OriName:         schedule.OriName,
 			OriLatitude:     schedule.OriLatitude,
@@ -632,38 +682,20 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 			TagId:           schedule.TagId,
 			Recurrence:      schedule.Recurrence,
 			RecurrenceId:    schedule.RecurrenceId,
+			OccurrenceDate:  occurrence.Date,
+			IsException:     occurrence.IsException,
 		}
-
-		currentTime, err := time.Parse("15:04", mainSchedule.StartTime)
-		if err != nil {
-			return "", fmt.Errorf("failed to parse start time: %v", err)
-		}
+		setScheduleSpan(&mainSchedule, startAt, endAt, loc)
 
 		dateSchedules := []repository.Schedule{mainSchedule}
+		currentStartAt := startAt
 
 		if schedule.IsHaveLocation {
-			currentEndTime := currentTime
-			currentTime = currentTime.Add(-travelDuration)
-
-			if currentTime.Year() < currentEndTime.Year() {
-				newDate, err := time.Parse("02-01-2006", date)
-				if err == nil {
-					newDate = newDate.AddDate(0, 0, -1)
-					date = newDate.Format("02-01-2006")
-				}
-			}
-
-			parsedDate, err := time.Parse("02-01-2006", date)
-			if err != nil {
-				return "", fmt.Errorf("failed to parse schedule date: %v", err)
-			}
+			currentStartAt = startAt.Add(-travelDuration)
 
 			travelSchedule := repository.Schedule{
 				GoogleId:        schedule.GoogleId,
 				Name:            "Leave From " + schedule.OriName,
-				Date:            parsedDate,
-				StartTime:       currentTime.Format("15:04"),
-				EndTime:         mainSchedule.StartTime,
 				IsHaveEndTime:   true,
 				OriName:         schedule.OriName,
 				OriLatitude:     schedule.OriLatitude,
@@ -678,6 +710,7 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 				IsUpdated:       false,
 				RecurrenceId:    schedule.RecurrenceId,
 			}
+			setScheduleSpan(&travelSchedule, currentStartAt, startAt, loc)
 			dateSchedules = append([]repository.Schedule{travelSchedule}, dateSchedules...)
 		}
 
@@ -686,31 +719,16 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 				routine := routines[i]
 				routineDuration, err := parseDuration(fmt.Sprintf("%d min", routine.Duration))
 				if err != nil {
-					return "", fmt.Errorf("failed to parse routine duration: %v", err)
+					return fmt.Errorf("failed to parse routine duration: %v", err)
 				}
 
-				endTime := currentTime
-				currentTime = currentTime.Add(-routineDuration)
+				routineEndAt := currentStartAt
+				currentStartAt = currentStartAt.Add(-routineDuration)
 
-				if currentTime.Year() < endTime.Year() {
-					newDate, err := time.Parse("02-01-2006", date)
-					if err == nil {
-						newDate = newDate.AddDate(0, 0, -1)
-						date = newDate.Format("02-01-2006")
-					}
-				}
-
-				parsedDate, err := time.Parse("02-01-2006", date)
-				if err != nil {
-					return "", fmt.Errorf("failed to parse schedule date: %v", err)
-				}
 				routineSchedule := repository.Schedule{
 					GoogleId:        schedule.GoogleId,
 					RoutineId:       routine.Id,
 					Name:            routine.Name,
-					Date:            parsedDate,
-					StartTime:       currentTime.Format("15:04"),
-					EndTime:         endTime.Format("15:04"),
 					GroupId:         schedule.GroupId,
 					IsHaveEndTime:   true,
 					IsHaveLocation:  false,
@@ -719,11 +737,14 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 					IsUpdated:       false,
 					RecurrenceId:    schedule.RecurrenceId,
 				}
+				setScheduleSpan(&routineSchedule, currentStartAt, routineEndAt, loc)
 				dateSchedules = append([]repository.Schedule{routineSchedule}, dateSchedules...)
 			}
 		}
 
 		if schedule.IsHaveLocation {
+			checkAt := currentStartAt.Add(-travelDuration)
+
 			scheduleLog := repository.ScheduleLog{
 				GroupId:       schedule.GroupId,
 				RecurrenceId:  schedule.RecurrenceId,
@@ -731,8 +752,9 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 				OriLongitude:  schedule.OriLongitude,
 				DestLatitude:  schedule.DestLatitude,
 				DestLongitude: schedule.DestLongitude,
-				Date:          parsedDate,
-				CheckTime:     currentTime.Add(-travelDuration).Format("15:04"),
+				Date:          localDate(checkAt, loc),
+				CheckTime:     checkAt.In(loc).Format("15:04"),
+				CheckAt:       checkAt.UTC(),
 			}
 			scheduleLogs = append(scheduleLogs, scheduleLog)
 		}
@@ -781,34 +803,29 @@ func (s *scheduleService) InsertRecurrenceSchedule(schedule *ScheduleInput) (str
 	}()
 
 	if err := <-errCh; err != nil {
-		return "", err
+		return err
 	}
 
-	return "", nil
+	return nil
 }
 
 func (s *scheduleService) calculateTravelDurationOnce(schedule *ScheduleInput) (time.Duration, error) {
-	departureTime := schedule.DepartTime
-	if departureTime == "" {
-		departureTime = "now"
-	}
-
 	if schedule.Transportation != "walking" && schedule.Transportation != "driving" && schedule.Transportation != "transit" {
 		schedule.Transportation = "driving"
 	}
-	travelTimeText, err := s.scheduleRepo.GetTravelTime(
-		fmt.Sprintf("%f", schedule.OriLatitude),
-		fmt.Sprintf("%f", schedule.OriLongitude),
-		fmt.Sprintf("%f", schedule.DestLatitude),
-		fmt.Sprintf("%f", schedule.DestLongitude),
+	travelTime, err := s.travelTimeProvider.GetTravelTime(
+		schedule.OriLatitude,
+		schedule.OriLongitude,
+		schedule.DestLatitude,
+		schedule.DestLongitude,
 		schedule.Transportation,
-		departureTime,
+		parseDepartTime(schedule.DepartTime),
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get travel time: %v", err)
 	}
 
-	return parseDuration(travelTimeText)
+	return travelTime.Duration, nil
 }
 
 func (s *scheduleService) GetAllSchedules(gId string, date string) ([]*ScheduleResponse, error) {
@@ -844,6 +861,8 @@ func (s *scheduleService) GetAllSchedules(gId string, date string) ([]*ScheduleR
 			TagId:           schedule.TagId,
 			Recurrence:      schedule.Recurrence,
 			RecurrenceId:    schedule.RecurrenceId,
+			StartAt:         schedule.StartAt,
+			EndAt:           schedule.EndAt,
 		})
 	}
 
@@ -855,6 +874,9 @@ func (s *scheduleService) GetScheduleById(id string) (*ScheduleResponse, error)
 	if err != nil {
 		return nil, err
 	}
+	if schedule == nil {
+		return nil, nil
+	}
 
 	return &ScheduleResponse{
 		Id:              schedule.Id,
@@ -879,6 +901,8 @@ func (s *scheduleService) GetScheduleById(id string) (*ScheduleResponse, error)
 		TagId:           schedule.TagId,
 		Recurrence:      schedule.Recurrence,
 		RecurrenceId:    schedule.RecurrenceId,
+		StartAt:         schedule.StartAt,
+		EndAt:           schedule.EndAt,
 	}, nil
 }
 
@@ -926,20 +950,21 @@ func (s *scheduleService) UpdateSchedule(id string, schedule *ScheduleUpdateInpu
 	if err != nil {
 		return fmt.Errorf("failed to fetch current schedule: %v", err)
 	}
+	if currentSchedule == nil {
+		return ErrResourceNotFound
+	}
 
 	// Prepare the updated schedule structure
-	parsedDate, err := time.Parse("02-01-2006", schedule.Date)
+	loc := userLocation(s.userRepo, currentSchedule.GoogleId)
+	startAt, endAt, err := scheduleInstants(schedule.Date, schedule.StartTime, schedule.EndTime, loc)
 	if err != nil {
-		return fmt.Errorf("failed to parse date: %v", err)
+		return fmt.Errorf("failed to parse schedule time: %v", err)
 	}
 	updatedSchedule := &repository.Schedule{
 		Id:              currentSchedule.Id,
 		RoutineId:       currentSchedule.RoutineId,
 		GoogleId:        currentSchedule.GoogleId,
 		Name:            schedule.Name,
-		Date:            parsedDate,
-		StartTime:       schedule.StartTime,
-		EndTime:         schedule.EndTime,
 		IsHaveEndTime:   schedule.IsHaveEndTime,
 		OriName:         currentSchedule.OriName,
 		OriLatitude:     currentSchedule.OriLatitude,
@@ -957,151 +982,172 @@ func (s *scheduleService) UpdateSchedule(id string, schedule *ScheduleUpdateInpu
 		TagId:           currentSchedule.TagId,
 		Recurrence:      currentSchedule.Recurrence,
 		RecurrenceId:    currentSchedule.RecurrenceId,
+		OccurrenceDate:  currentSchedule.OccurrenceDate,
+		IsException:     currentSchedule.IsException,
+	}
+	setScheduleSpan(updatedSchedule, startAt, endAt, loc)
+
+	// An occurrence of a series keeps this edit through later series edits
+	if currentSchedule.Recurrence != "" {
+		recorded, err := s.recordException(currentSchedule.RecurrenceId, repository.RecurrenceException{
+			Date:          occurrenceDate(currentSchedule),
+			NewDate:       updatedSchedule.Date,
+			Name:          schedule.Name,
+			StartTime:     schedule.StartTime,
+			EndTime:       schedule.EndTime,
+			IsHaveEndTime: schedule.IsHaveEndTime,
+		})
+		if err != nil {
+			return err
+		}
+		if recorded {
+			updatedSchedule.OccurrenceDate = occurrenceDate(currentSchedule)
+			updatedSchedule.IsException = true
+		}
 	}
 
-	// Check if the start time has changed
-	startTimeChanged := currentSchedule.StartTime != updatedSchedule.StartTime
-	if startTimeChanged {
-		// Fetch all schedules for the same date, ordered by StartTime
+	// Check if the start has changed
+	currentStartAt, _, err := scheduleSpan(currentSchedule, loc)
+	if err != nil || !currentStartAt.Equal(updatedSchedule.StartAt) {
 		allSchedules, err := s.scheduleRepo.GetSchedulesByGroupId(currentSchedule.GroupId)
 		if err != nil {
 			return fmt.Errorf("failed to fetch schedules for the day: %v", err)
 		}
 
-		// Adjust times from the updated schedule backward
-		// Starting from the end of the list and moving toward the current schedule
-		currentStartTime, err := time.Parse("15:04", updatedSchedule.StartTime)
+		err = s.rechainSchedules(allSchedules, startAt, currentSchedule.RecurrenceId, loc)
 		if err != nil {
-			return fmt.Errorf("failed to parse new start time: %v", err)
+			return err
 		}
+	}
 
-		date := parsedDate
+	// Update the primary schedule entry
+	err = s.scheduleRepo.UpdateSchedule(id, updatedSchedule)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule: %v", err)
+	}
 
-		for i := 1; i < len(allSchedules); i++ {
-			sch := allSchedules[i]
+	s.publishScheduleUpdated(updatedSchedule, websocket.ReasonUserEdit)
 
-			// Determine the duration of the current schedule
-			var duration time.Duration
-			if sch.IsHaveEndTime && sch.EndTime != "" {
-				startTime, err := time.Parse("15:04", sch.StartTime)
-				if err != nil {
-					return fmt.Errorf("failed to parse start time: %v", err)
-				}
-				endTime, err := time.Parse("15:04", sch.EndTime)
-				if err != nil {
-					return fmt.Errorf("failed to parse end time: %v", err)
-				}
-				duration = endTime.Sub(startTime)
-			} else {
-				// Default to a minimal duration if no end time is set
-				duration = 5 * time.Minute
-			}
+	return nil
+}
 
-			// Calculate the new end time as the current start time
-			endTime := currentStartTime
-			sch.EndTime = endTime.Format("15:04")
+// rechainSchedules moves the entries leading up to a group's main schedule,
+// allSchedules[0], so that they end back to back at startAt. Travel entries
+// are re-timed for the current travel time, and the group's log is moved to
+// the check before the earliest of them.
+func (s *scheduleService) rechainSchedules(allSchedules []*repository.Schedule, startAt time.Time, recurrenceId int, loc *time.Location) error {
+	currentStartAt := startAt
+	var travelDuration time.Duration
 
-			// Adjust the current start time by subtracting the routine duration
-			currentStartTime = currentStartTime.Add(-duration)
+	for i := 1; i < len(allSchedules); i++ {
+		sch := allSchedules[i]
 
-			if currentStartTime.Year() < endTime.Year() {
-				date = date.AddDate(0, 0, -1)
+		// Default to a minimal duration if no end time is set
+		duration := 5 * time.Minute
+		if sch.IsHaveEndTime {
+			schStartAt, schEndAt, err := scheduleSpan(sch, loc)
+			if err != nil {
+				return err
 			}
-
-			if allSchedules[i-1].Transportation != "walking" && allSchedules[i-1].Transportation != "driving" && allSchedules[i-1].Transportation != "transit" {
-				allSchedules[i-1].Transportation = "driving"
+			if !schEndAt.IsZero() {
+				duration = schEndAt.Sub(schStartAt)
 			}
-			if sch.IsTraveling {
-				travelTimeText, err := s.scheduleRepo.GetTravelTime(
-					fmt.Sprintf("%f", allSchedules[i-1].OriLatitude),
-					fmt.Sprintf("%f", allSchedules[i-1].OriLongitude),
-					fmt.Sprintf("%f", allSchedules[i-1].DestLatitude),
-					fmt.Sprintf("%f", allSchedules[i-1].DestLongitude),
-					allSchedules[i-1].Transportation,
-					"now",
-				)
+		}
 
-				if err != nil {
-					return fmt.Errorf("failed to get travel time: %v", err)
-				}
+		endAt := currentStartAt
+		currentStartAt = currentStartAt.Add(-duration)
 
-				travelDuration, err := parseDuration(travelTimeText)
-				if err != nil {
-					return fmt.Errorf("failed to parse travel duration: %v", err)
-				}
+		if allSchedules[i-1].Transportation != "walking" && allSchedules[i-1].Transportation != "driving" && allSchedules[i-1].Transportation != "transit" {
+			allSchedules[i-1].Transportation = "driving"
+		}
+		if sch.IsTraveling {
+			travelTime, err := s.travelTimeProvider.GetTravelTime(
+				allSchedules[i-1].OriLatitude,
+				allSchedules[i-1].OriLongitude,
+				allSchedules[i-1].DestLatitude,
+				allSchedules[i-1].DestLongitude,
+				allSchedules[i-1].Transportation,
+				time.Time{},
+			)
 
-				leaveTime := endTime.Add(-travelDuration)
-				currentStartTime = leaveTime
+			if err != nil {
+				return fmt.Errorf("failed to get travel time: %v", err)
 			}
 
-			// Update the schedule times
-			sch.StartTime = currentStartTime.Format("15:04")
-
-			// Save the adjusted schedule back to the database
-			err = s.scheduleRepo.UpdateSchedule(sch.Id, &repository.Schedule{
-				Id:              sch.Id,
-				RoutineId:       sch.RoutineId,
-				GoogleId:        sch.GoogleId,
-				Name:            sch.Name,
-				Date:            date,
-				StartTime:       sch.StartTime,
-				EndTime:         sch.EndTime,
-				IsHaveEndTime:   sch.IsHaveEndTime,
-				OriName:         sch.OriName,
-				OriLatitude:     sch.OriLatitude,
-				OriLongitude:    sch.OriLongitude,
-				DestName:        sch.DestName,
-				DestLatitude:    sch.DestLatitude,
-				DestLongitude:   sch.DestLongitude,
-				GroupId:         sch.GroupId,
-				Transportation:  sch.Transportation,
-				Priority:        sch.Priority,
-				IsHaveLocation:  sch.IsHaveLocation,
-				IsFirstSchedule: sch.IsFirstSchedule,
-				IsTraveling:     sch.IsTraveling,
-				IsUpdated:       false,
-				TagId:           sch.TagId,
-				Recurrence:      sch.Recurrence,
-				RecurrenceId:    sch.RecurrenceId,
-			})
+			currentStartAt = endAt.Add(-travelTime.Duration)
+			travelDuration = travelTime.Duration
+		}
 
-			if err != nil {
-				fmt.Printf("Failed to adjust schedule times for %s: %v\n", sch.Name, err)
-				return fmt.Errorf("failed to adjust schedule times: %v", err)
-			} else {
-				fmt.Printf("Successfully updated schedule: %s\n", sch.Name)
-			}
-			updateMessage := map[string]interface{}{
-				"id":            sch.Id,
-				"name":          sch.Name,
-				"date":          date,
-				"startTime":     sch.StartTime,
-				"endTime":       sch.EndTime,
-				"isHaveEndTime": sch.IsHaveEndTime,
-			}
-			message, _ := json.Marshal(updateMessage)
-			websocket.SendUpdate(message, sch.GoogleId)
+		setScheduleSpan(sch, currentStartAt, endAt, loc)
+		sch.IsUpdated = false
+		sch.RecurrenceId = recurrenceId
+
+		// Save the adjusted schedule back to the database
+		err := s.scheduleRepo.UpdateSchedule(sch.Id, sch)
+		if err != nil {
+			fmt.Printf("Failed to adjust schedule times for %s: %v\n", sch.Name, err)
+			return fmt.Errorf("failed to adjust schedule times: %v", err)
+		} else {
+			fmt.Printf("Successfully updated schedule: %s\n", sch.Name)
 		}
+		s.publishScheduleUpdated(sch, websocket.ReasonUserEdit)
 	}
 
-	// Update the primary schedule entry
-	err = s.scheduleRepo.UpdateSchedule(id, updatedSchedule)
-	if err != nil {
-		return fmt.Errorf("failed to update schedule: %v", err)
+	if len(allSchedules) > 0 && allSchedules[0].IsHaveLocation {
+		return moveScheduleLog(s.scheduleLogRepo, allSchedules[0].GroupId, recurrenceId, currentStartAt.Add(-travelDuration), loc)
 	}
+	return nil
+}
 
-	updateMessage := updatedSchedule
-	message, _ := json.Marshal(updateMessage)
-	websocket.SendUpdate(message, currentSchedule.GoogleId)
+// moveGroupToSeries moves the entries leading up to a group's main schedule,
+// allSchedules[0], and the group's log to the series recurrenceId without
+// re-timing them.
+func (s *scheduleService) moveGroupToSeries(allSchedules []*repository.Schedule, recurrenceId int, loc *time.Location) error {
+	if len(allSchedules) == 0 {
+		return nil
+	}
+	for _, sch := range allSchedules[1:] {
+		sch.RecurrenceId = recurrenceId
+		err := s.scheduleRepo.UpdateSchedule(sch.Id, sch)
+		if err != nil {
+			return fmt.Errorf("failed to update schedule: %v", err)
+		}
+	}
 
-	return nil
+	scheduleLog, err := s.scheduleLogRepo.GetScheduleLogByGroupId(allSchedules[0].GroupId)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule log: %v", err)
+	}
+	if scheduleLog == nil {
+		return nil
+	}
+	checkAt, err := scheduleLogCheckAt(scheduleLog, loc)
+	if err != nil {
+		return fmt.Errorf("failed to read check time: %v", err)
+	}
+	return moveScheduleLog(s.scheduleLogRepo, allSchedules[0].GroupId, recurrenceId, checkAt, loc)
 }
 
-func (s *scheduleService) UpdateScheduleByRecurrenceId(recurrenceId string, inputSchedule *ScheduleUpdateInput, date string) error {
+func (s *scheduleService) UpdateScheduleByRecurrenceId(recurrenceId string, inputSchedule *ScheduleUpdateInput, date string, mode string) error {
 	id, err := strconv.Atoi(recurrenceId)
 	if err != nil {
 		return fmt.Errorf("invalid recurrenceId: %v", err)
 	}
+
+	switch mode {
+	case RecurrenceModeThis:
+		occurrence, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			return ErrInvalidOccurrence
+		}
+		return s.updateOccurrence(id, inputSchedule, occurrence)
+	case RecurrenceModeAll:
+		date = ""
+	case "", RecurrenceModeFollowing:
+	default:
+		return ErrInvalidRecurrenceMode
+	}
+
 	schedules, err := s.scheduleRepo.GetMainSchedulesByRecurrenceId(id, date)
 	if err != nil {
 		return fmt.Errorf("failed to get schedules by recurrence ID: %v", err)
@@ -1117,21 +1163,98 @@ func (s *scheduleService) UpdateScheduleByRecurrenceId(recurrenceId string, inpu
 		return fmt.Errorf("failed to parse input schedule date: %v", err)
 	}
 
+	var from time.Time
+	if date != "" {
+		from, err = time.Parse("02-01-2006", date)
+		if err != nil {
+			return fmt.Errorf("invalid date format: %v", err)
+		}
+	}
+
+	series, err := s.seriesRepo.GetSeriesByRecurrenceId(id)
+	if err != nil {
+		return fmt.Errorf("failed to get recurrence series: %v", err)
+	}
+
+	// The occurrences from the date on carry on as a new series expanded from
+	// the new date. Series created before masters were stored are only moved,
+	// not extended
+	var newSeries *repository.RecurrenceSeries
+	dateMap := make(map[time.Time]time.Time)
+	if series != nil {
+		newSeries, dateMap, err = s.splitSeries(series, from, newRecurrenceId, inputSchedule, inputDate)
+		if err != nil {
+			return err
+		}
+	} else if len(schedules) > 0 {
+		rule, err := repository.ParseRecurrence(schedules[0].Recurrence)
+		if err != nil {
+			return fmt.Errorf("failed to parse recurrence: %v", err)
+		}
+		rule.Count = 0
+		for i, nextDate := range rule.Occurrences(inputDate, len(schedules)) {
+			dateMap[occurrenceDate(schedules[i])] = nextDate
+		}
+	}
+
+	var loc *time.Location
+	var lastDate time.Time
 	for _, schedule := range schedules {
+		nextDate, ok := dateMap[occurrenceDate(schedule)]
+		// Occurrences the moved series no longer has
+		if !ok {
+			err = s.deleteGroup(schedule)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		currentSchedule, err := s.scheduleRepo.GetScheduleById(schedule.Id)
 		if err != nil {
 			return fmt.Errorf("failed to fetch current schedule: %v", err)
 		}
+		if currentSchedule == nil {
+			continue
+		}
+		if loc == nil {
+			loc = userLocation(s.userRepo, currentSchedule.GoogleId)
+		}
+
+		name := inputSchedule.Name
+		isHaveEndTime := inputSchedule.IsHaveEndTime
+		var startAt, endAt time.Time
+		if currentSchedule.IsException {
+			// Edited on its own, so it only moves along with its occurrence
+			currentStartAt, currentEndAt, err := scheduleSpan(currentSchedule, loc)
+			if err != nil {
+				return fmt.Errorf("failed to read schedule time: %v", err)
+			}
+			days := int(nextDate.Sub(occurrenceDate(currentSchedule)).Hours() / 24)
+			startAt = moveDays(currentStartAt, days, loc)
+			if !currentEndAt.IsZero() {
+				endAt = moveDays(currentEndAt, days, loc)
+			}
+			name = currentSchedule.Name
+			isHaveEndTime = currentSchedule.IsHaveEndTime
+		} else {
+			startAt, endAt, err = scheduleInstants(nextDate.Format("02-01-2006"), inputSchedule.StartTime, inputSchedule.EndTime, loc)
+			if err != nil {
+				return fmt.Errorf("failed to parse schedule time: %v", err)
+			}
+		}
+
+		recurrence := currentSchedule.Recurrence
+		if newSeries != nil {
+			recurrence = newSeries.Recurrence
+		}
 
 		updatedSchedule := &repository.Schedule{
 			Id:              currentSchedule.Id,
 			RoutineId:       currentSchedule.RoutineId,
 			GoogleId:        currentSchedule.GoogleId,
-			Name:            inputSchedule.Name,
-			Date:            inputDate,
-			StartTime:       inputSchedule.StartTime,
-			EndTime:         inputSchedule.EndTime,
-			IsHaveEndTime:   inputSchedule.IsHaveEndTime,
+			Name:            name,
+			IsHaveEndTime:   isHaveEndTime,
 			OriName:         currentSchedule.OriName,
 			OriLatitude:     currentSchedule.OriLatitude,
 			OriLongitude:    currentSchedule.OriLongitude,
@@ -1146,123 +1269,26 @@ func (s *scheduleService) UpdateScheduleByRecurrenceId(recurrenceId string, inpu
 			IsTraveling:     currentSchedule.IsTraveling,
 			IsUpdated:       false,
 			TagId:           currentSchedule.TagId,
-			Recurrence:      currentSchedule.Recurrence,
+			Recurrence:      recurrence,
 			RecurrenceId:    newRecurrenceId,
+			OccurrenceDate:  nextDate,
+			IsException:     currentSchedule.IsException,
 		}
+		setScheduleSpan(updatedSchedule, startAt, endAt, loc)
 
-		startTimeChanged := currentSchedule.StartTime != updatedSchedule.StartTime
-		if startTimeChanged {
-			allSchedules, err := s.scheduleRepo.GetSchedulesByGroupId(currentSchedule.GroupId)
-			if err != nil {
-				return fmt.Errorf("failed to fetch schedules for the day: %v", err)
-			}
-
-			currentStartTime, err := time.Parse("15:04", updatedSchedule.StartTime)
-			if err != nil {
-				return fmt.Errorf("failed to parse new start time: %v", err)
-			}
-
-			newDate := inputDate
-
-			for i := 1; i < len(allSchedules); i++ {
-				sch := allSchedules[i]
-
-				var duration time.Duration
-				if sch.IsHaveEndTime && sch.EndTime != "" {
-					startTime, err := time.Parse("15:04", sch.StartTime)
-					if err != nil {
-						return fmt.Errorf("failed to parse start time: %v", err)
-					}
-					endTime, err := time.Parse("15:04", sch.EndTime)
-					if err != nil {
-						return fmt.Errorf("failed to parse end time: %v", err)
-					}
-					duration = endTime.Sub(startTime)
-				} else {
-					duration = 5 * time.Minute
-				}
-
-				endTime := currentStartTime
-				sch.EndTime = endTime.Format("15:04")
-
-				currentStartTime = currentStartTime.Add(-duration)
-
-				if currentStartTime.Year() < endTime.Year() {
-					newDate = newDate.AddDate(0, 0, -1)
-				}
-
-				if allSchedules[i-1].Transportation != "walking" && allSchedules[i-1].Transportation != "driving" && allSchedules[i-1].Transportation != "transit" {
-					allSchedules[i-1].Transportation = "driving"
-				}
-				if sch.IsTraveling {
-					travelTimeText, err := s.scheduleRepo.GetTravelTime(
-						fmt.Sprintf("%f", allSchedules[i-1].OriLatitude),
-						fmt.Sprintf("%f", allSchedules[i-1].OriLongitude),
-						fmt.Sprintf("%f", allSchedules[i-1].DestLatitude),
-						fmt.Sprintf("%f", allSchedules[i-1].DestLongitude),
-						allSchedules[i-1].Transportation,
-						"now",
-					)
-
-					if err != nil {
-						return fmt.Errorf("failed to get travel time: %v", err)
-					}
-
-					travelDuration, err := parseDuration(travelTimeText)
-					if err != nil {
-						return fmt.Errorf("failed to parse travel duration: %v", err)
-					}
-
-					leaveTime := endTime.Add(-travelDuration)
-					currentStartTime = leaveTime
-				}
-
-				sch.StartTime = currentStartTime.Format("15:04")
-
-				err = s.scheduleRepo.UpdateSchedule(sch.Id, &repository.Schedule{
-					Id:              sch.Id,
-					RoutineId:       sch.RoutineId,
-					GoogleId:        sch.GoogleId,
-					Name:            sch.Name,
-					Date:            newDate,
-					StartTime:       sch.StartTime,
-					EndTime:         sch.EndTime,
-					IsHaveEndTime:   sch.IsHaveEndTime,
-					OriName:         sch.OriName,
-					OriLatitude:     sch.OriLatitude,
-					OriLongitude:    sch.OriLongitude,
-					DestName:        sch.DestName,
-					DestLatitude:    sch.DestLatitude,
-					DestLongitude:   sch.DestLongitude,
-					GroupId:         sch.GroupId,
-					Transportation:  sch.Transportation,
-					Priority:        sch.Priority,
-					IsHaveLocation:  sch.IsHaveLocation,
-					IsFirstSchedule: sch.IsFirstSchedule,
-					IsTraveling:     sch.IsTraveling,
-					IsUpdated:       false,
-					TagId:           sch.TagId,
-					Recurrence:      sch.Recurrence,
-					RecurrenceId:    newRecurrenceId,
-				})
-
-				if err != nil {
-					fmt.Printf("Failed to adjust schedule times for %s: %v\n", sch.Name, err)
-					return fmt.Errorf("failed to adjust schedule times: %v", err)
-				} else {
-					fmt.Printf("Successfully updated schedule: %s\n", sch.Name)
-				}
-				updateMessage := map[string]interface{}{
-					"id":            sch.Id,
-					"name":          sch.Name,
-					"date":          newDate,
-					"startTime":     sch.StartTime,
-					"endTime":       sch.EndTime,
-					"isHaveEndTime": sch.IsHaveEndTime,
-				}
-				message, _ := json.Marshal(updateMessage)
-				websocket.SendUpdate(message, sch.GoogleId)
-			}
+		// The rest of the group and its log move to the new series as well
+		allSchedules, err := s.scheduleRepo.GetSchedulesByGroupId(currentSchedule.GroupId)
+		if err != nil {
+			return fmt.Errorf("failed to fetch schedules for the day: %v", err)
+		}
+		currentStartAt, _, err := scheduleSpan(currentSchedule, loc)
+		if err != nil || !currentStartAt.Equal(updatedSchedule.StartAt) {
+			err = s.rechainSchedules(allSchedules, startAt, newRecurrenceId, loc)
+		} else {
+			err = s.moveGroupToSeries(allSchedules, newRecurrenceId, loc)
+		}
+		if err != nil {
+			return err
 		}
 
 		err = s.scheduleRepo.UpdateSchedule(schedule.Id, updatedSchedule)
@@ -1270,29 +1296,96 @@ func (s *scheduleService) UpdateScheduleByRecurrenceId(recurrenceId string, inpu
 			return fmt.Errorf("failed to update schedule: %v", err)
 		}
 
-		updateMessage := updatedSchedule
-		message, _ := json.Marshal(updateMessage)
-		websocket.SendUpdate(message, currentSchedule.GoogleId)
+		s.publishScheduleUpdated(updatedSchedule, websocket.ReasonUserEdit)
+
+		if nextDate.After(lastDate) {
+			lastDate = nextDate
+		}
+	}
 
-		if currentSchedule.Recurrence == "daily" {
-			inputDate = inputDate.AddDate(0, 0, 1)
-		} else if currentSchedule.Recurrence == "weekly" {
-			inputDate = inputDate.AddDate(0, 0, 7)
-		} else if currentSchedule.Recurrence == "monthly" {
-			inputDate = inputDate.AddDate(0, 1, 0)
-		} else if currentSchedule.Recurrence == "yearly" {
-			inputDate = inputDate.AddDate(1, 0, 0)
+	if newSeries != nil {
+		if lastDate.After(newSeries.MaterializedUntil) {
+			newSeries.MaterializedUntil = lastDate
+		}
+		err = s.seriesRepo.InsertSeries(newSeries)
+		if err != nil {
+			return fmt.Errorf("failed to insert recurrence series: %v", err)
+		}
+		err = s.materializeSeries(newSeries)
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// deleteGroup removes the group schedule belongs to along with its log.
+func (s *scheduleService) deleteGroup(schedule *repository.Schedule) error {
+	schedules, err := s.scheduleRepo.GetSchedulesByGroupId(schedule.GroupId)
+	if err != nil {
+		return fmt.Errorf("failed to get schedules by group ID: %v", err)
+	}
+	err = s.scheduleRepo.DeleteSchedule(schedule.GroupId)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %v", err)
+	}
+	err = s.scheduleLogRepo.DeleteScheduleLog(schedule.GroupId)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule log: %v", err)
+	}
+
+	s.publishScheduleDeleted(schedules, websocket.ScheduleDeletedPayload{GroupId: schedule.GroupId})
+	return nil
+}
+
+func (s *scheduleService) publishScheduleUpdated(schedule *repository.Schedule, reason string) {
+	s.hub.Publish(schedule.GoogleId, websocket.NewEvent(websocket.EventScheduleUpdated, websocket.ScheduleUpdatedPayload{
+		Id:            schedule.Id,
+		Name:          schedule.Name,
+		Date:          schedule.Date.Format("02-01-2006"),
+		StartTime:     schedule.StartTime,
+		EndTime:       schedule.EndTime,
+		StartAt:       optionalInstant(schedule.StartAt),
+		EndAt:         optionalInstant(schedule.EndAt),
+		IsHaveEndTime: schedule.IsHaveEndTime,
+		GroupId:       schedule.GroupId,
+		RecurrenceId:  schedule.RecurrenceId,
+		Reason:        reason,
+	}))
+}
+
+// publishScheduleDeleted notifies the owners of the schedules that were
+// removed. It has to be given the schedules as read before the delete.
+func (s *scheduleService) publishScheduleDeleted(schedules []*repository.Schedule, payload websocket.ScheduleDeletedPayload) {
+	notified := make(map[string]bool)
+	for _, schedule := range schedules {
+		if notified[schedule.GoogleId] {
+			continue
+		}
+		notified[schedule.GoogleId] = true
+		s.hub.Publish(schedule.GoogleId, websocket.NewEvent(websocket.EventScheduleDeleted, payload))
+	}
+}
+
 func (s *scheduleService) DeleteSchedule(groupId string) error {
 	id, err := strconv.Atoi(groupId)
 	if err != nil {
 		return fmt.Errorf("invalid groupId: %v", err)
 	}
+	schedules, err := s.scheduleRepo.GetSchedulesByGroupId(id)
+	if err != nil {
+		return fmt.Errorf("failed to get schedules by group ID: %v", err)
+	}
+
+	// Keep the series from materializing the occurrence again
+	if len(schedules) > 0 && schedules[0].Recurrence != "" {
+		_, err = s.recordException(schedules[0].RecurrenceId, repository.RecurrenceException{Date: occurrenceDate(schedules[0]), IsCancelled: true})
+		if err != nil {
+			return err
+		}
+	}
+
 	err = s.scheduleRepo.DeleteSchedule(id)
 	if err != nil {
 		return fmt.Errorf("failed to delete schedule: %v", err)
@@ -1302,22 +1395,184 @@ func (s *scheduleService) DeleteSchedule(groupId string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete schedule log: %v", err)
 	}
+
+	s.publishScheduleDeleted(schedules, websocket.ScheduleDeletedPayload{GroupId: id})
 	return nil
 }
 
-func (s *scheduleService) DeleteScheduleByRecurrenceId(recurrenceId string, date string) error {
+func (s *scheduleService) DeleteScheduleByRecurrenceId(recurrenceId string, date string, mode string) error {
 	id, err := strconv.Atoi(recurrenceId)
 	if err != nil {
 		return fmt.Errorf("invalid recurrenceId: %v", err)
 	}
+
+	switch mode {
+	case RecurrenceModeThis:
+		occurrence, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			return ErrInvalidOccurrence
+		}
+		return s.deleteOccurrence(id, occurrence)
+	case RecurrenceModeAll:
+		date = ""
+	case "", RecurrenceModeFollowing:
+	default:
+		return ErrInvalidRecurrenceMode
+	}
+
+	schedules, err := s.scheduleRepo.GetSchedulesByRecurrenceId(id, date)
+	if err != nil {
+		return fmt.Errorf("failed to get schedules by recurrence ID: %v", err)
+	}
 	err = s.scheduleRepo.DeleteScheduleByRecurrenceId(id, date)
 	if err != nil {
 		return fmt.Errorf("failed to delete schedule: %v", err)
 	}
 
-	err = s.scheduleLogRepo.DeleteScheduleLogByRecurrenceId(id)
+	// Earlier occurrences keep their logs
+	if date == "" {
+		err = s.scheduleLogRepo.DeleteScheduleLogByRecurrenceId(id)
+		if err != nil {
+			return fmt.Errorf("failed to delete schedule log: %v", err)
+		}
+	} else {
+		deleted := make(map[int]bool)
+		for _, schedule := range schedules {
+			if deleted[schedule.GroupId] {
+				continue
+			}
+			deleted[schedule.GroupId] = true
+			err = s.scheduleLogRepo.DeleteScheduleLog(schedule.GroupId)
+			if err != nil {
+				return fmt.Errorf("failed to delete schedule log: %v", err)
+			}
+		}
+	}
+
+	series, err := s.seriesRepo.GetSeriesByRecurrenceId(id)
 	if err != nil {
-		return fmt.Errorf("failed to delete schedule log: %v", err)
+		return fmt.Errorf("failed to get recurrence series: %v", err)
+	}
+	if series != nil {
+		var from time.Time
+		if date != "" {
+			from, _ = time.Parse("02-01-2006", date)
+		}
+		err = s.endSeries(series, from)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.publishScheduleDeleted(schedules, websocket.ScheduleDeletedPayload{RecurrenceId: id, FromDate: date})
+	return nil
+}
+
+// SnoozeSchedule pushes a schedule back by minutes. Like the travel time
+// updates it marks the schedule as updated so the cron job leaves it alone.
+func (s *scheduleService) SnoozeSchedule(id string, minutes int) error {
+	schedule, err := s.scheduleRepo.GetScheduleById(id)
+	if err != nil {
+		return fmt.Errorf("failed to fetch schedule: %v", err)
+	}
+	if schedule == nil {
+		return ErrResourceNotFound
+	}
+
+	loc := userLocation(s.userRepo, schedule.GoogleId)
+	startAt, endAt, err := scheduleSpan(schedule, loc)
+	if err != nil {
+		return fmt.Errorf("failed to read schedule time: %v", err)
+	}
+
+	shift := time.Duration(minutes) * time.Minute
+	if !endAt.IsZero() {
+		endAt = endAt.Add(shift)
+	}
+	setScheduleSpan(schedule, startAt.Add(shift), endAt, loc)
+
+	err = s.scheduleRepo.UpdateScheduleTime(schedule.Id, schedule)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule time: %v", err)
+	}
+
+	s.publishScheduleUpdated(schedule, websocket.ReasonSnoozed)
+	return nil
+}
+
+// LeaveNow records that the user set off for the schedule's group: the travel
+// entry starts at the current time and the group is no longer adjusted for
+// travel time on that day.
+func (s *scheduleService) LeaveNow(id string) error {
+	schedule, err := s.scheduleRepo.GetScheduleById(id)
+	if err != nil {
+		return fmt.Errorf("failed to fetch schedule: %v", err)
+	}
+	if schedule == nil {
+		return ErrResourceNotFound
+	}
+
+	schedules, err := s.scheduleRepo.GetSchedulesByGroupId(schedule.GroupId)
+	if err != nil {
+		return fmt.Errorf("failed to get schedules by group ID: %v", err)
+	}
+
+	loc := userLocation(s.userRepo, schedule.GoogleId)
+	departedAt := time.Now()
+	for _, sch := range schedules {
+		startAt, endAt, err := scheduleSpan(sch, loc)
+		if err != nil {
+			return fmt.Errorf("failed to read schedule time: %v", err)
+		}
+		if sch.IsTraveling {
+			startAt = departedAt
+		}
+		setScheduleSpan(sch, startAt, endAt, loc)
+
+		err = s.scheduleRepo.UpdateScheduleTime(sch.Id, sch)
+		if err != nil {
+			return fmt.Errorf("failed to update schedule time: %v", err)
+		}
+		if sch.IsTraveling {
+			s.publishScheduleUpdated(sch, websocket.ReasonDeparted)
+		}
+	}
+
+	return nil
+}
+
+// MigrateScheduleInstants fills in the start and end instants of schedules
+// stored before they existed, reading their date and clock times in the
+// owner's time zone. Schedules that cannot be read are logged and skipped.
+func (s *scheduleService) MigrateScheduleInstants() error {
+	schedules, err := s.scheduleRepo.GetSchedulesWithoutInstants()
+	if err != nil {
+		return fmt.Errorf("failed to get schedules to migrate: %v", err)
+	}
+
+	locations := make(map[string]*time.Location)
+	migrated := 0
+	for _, schedule := range schedules {
+		loc, ok := locations[schedule.GoogleId]
+		if !ok {
+			loc = userLocation(s.userRepo, schedule.GoogleId)
+			locations[schedule.GoogleId] = loc
+		}
+
+		startAt, endAt, err := scheduleSpan(schedule, loc)
+		if err != nil {
+			log.Printf("Skipping schedule %s: %v", schedule.Id, err)
+			continue
+		}
+		err = s.scheduleRepo.SetScheduleInstants(schedule.Id, startAt.UTC(), endAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to migrate schedule %s: %v", schedule.Id, err)
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Stored start and end instants for %d schedules", migrated)
 	}
 	return nil
 }
@@ -1,6 +1,14 @@
 package service
 
-import "etalert-backend/repository"
+import (
+	"errors"
+	"etalert-backend/repository"
+)
+
+var (
+	ErrUnknownTag     = errors.New("unknown tag")
+	ErrUnknownRoutine = errors.New("unknown routine")
+)
 
 type tagService struct {
 	tagRepo repository.TagRepository
@@ -12,6 +20,10 @@ func NewTagService(tagRepo repository.TagRepository, routineRepo repository.Rout
 }
 
 func (s tagService) InsertTag(tag *TagInput) error {
+	if err := s.checkRoutines(tag.GoogleId, tag.Routines); err != nil {
+		return err
+	}
+
 	err := s.tagRepo.InsertTag(&repository.Tag{
 		GoogleId: tag.GoogleId,
 		Name:     tag.Name,
@@ -55,6 +67,9 @@ func (s *tagService) GetRoutinesByTagId(id string) ([]*RoutineResponse, error) {
 		if err != nil {
 			return nil, err
 		}
+		if routine == nil {
+			continue
+		}
 		routineResponses = append(routineResponses, &RoutineResponse{
 			Id:       routine.Id,
 			Name:     routine.Name,
@@ -67,7 +82,18 @@ func (s *tagService) GetRoutinesByTagId(id string) ([]*RoutineResponse, error) {
 }
 
 func (s *tagService) UpdateTag(id string, tag *TagUpdateInput) error {
-	err := s.tagRepo.UpdateTag(id, &repository.Tag{
+	existing, err := s.tagRepo.GetTagById(id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrResourceNotFound
+	}
+	if err := s.checkRoutines(existing.GoogleId, tag.Routines); err != nil {
+		return err
+	}
+
+	err = s.tagRepo.UpdateTag(id, &repository.Tag{
 		Name:     tag.Name,
 		Routines: tag.Routines,
 	})
@@ -83,4 +109,19 @@ func (s *tagService) DeleteTag(id string) error {
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// checkRoutines rejects routine ids that do not belong to googleId, so a tag
+// cannot hand another user's routines out.
+func (s tagService) checkRoutines(googleId string, routineIds []string) error {
+	for _, routineId := range routineIds {
+		routine, err := s.routineRepo.GetRoutineById(routineId)
+		if err != nil {
+			return err
+		}
+		if routine == nil || routine.GoogleId != googleId {
+			return ErrUnknownRoutine
+		}
+	}
+	return nil
+}
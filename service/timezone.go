@@ -0,0 +1,48 @@
+package service
+
+import (
+	"etalert-backend/repository"
+	"log"
+	"time"
+)
+
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		name = repository.DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Unknown time zone %q, using %s: %v", name, repository.DefaultTimeZone, err)
+		loc, _ = time.LoadLocation(repository.DefaultTimeZone)
+	}
+	return loc
+}
+
+func userLocation(userRepo repository.UserRepository, googleId string) *time.Location {
+	user, err := userRepo.GetUserInfo(googleId)
+	if err != nil {
+		log.Printf("Failed to get time zone of user %s: %v", googleId, err)
+	}
+	if user == nil {
+		return loadLocation("")
+	}
+	return loadLocation(user.TimeZone)
+}
+
+// localInstant is the moment the clocks in loc read clock on date, a
+// "02-01-2006" date. Around DST changes the offset in force on that day and
+// time is used, so a 07:00 check stays at 07:00 local time all year.
+func localInstant(date string, clock string, loc *time.Location) (time.Time, error) {
+	parsed, err := time.Parse("02-01-2006 15:04", date+" "+clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(parsed.Year(), parsed.Month(), parsed.Day(), parsed.Hour(), parsed.Minute(), 0, 0, loc), nil
+}
+
+// localDate turns an instant into the UTC midnight of its date in loc, the
+// way schedule dates are stored.
+func localDate(instant time.Time, loc *time.Location) time.Time {
+	local := instant.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
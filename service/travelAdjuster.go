@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"etalert-backend/repository"
+	"time"
+)
+
+// TravelConditions is what is known about a route when it is re-checked.
+// Traffic and Forecast are nil when they could not be fetched.
+type TravelConditions struct {
+	OriLatitude    float64
+	OriLongitude   float64
+	DestLatitude   float64
+	DestLongitude  float64
+	Transportation string
+	Base           *repository.TravelTime
+	Traffic        *repository.TrafficResponse
+	Forecast       *repository.Forecast
+}
+
+type TravelAdjustment struct {
+	Duration time.Duration
+	Adjuster string
+}
+
+// TravelAdjuster turns the routing provider's estimate into the travel time
+// the schedule should plan for.
+type TravelAdjuster interface {
+	AdjustTravelTime(ctx context.Context, conditions *TravelConditions) (*TravelAdjustment, error)
+}
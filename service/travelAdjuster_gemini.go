@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+const (
+	geminiModel   = "gemini-1.5-flash"
+	geminiTimeout = 10 * time.Second
+
+	// Replies outside these multiples of the base estimate are discarded
+	geminiMinFactor = 0.8
+	geminiMaxFactor = 3.0
+)
+
+var geminiMinutesPattern = regexp.MustCompile(`^\d{1,4}(\.\d+)?$`)
+
+// geminiTravelAdjuster asks Gemini for the adjusted travel time and falls
+// back to the wrapped adjuster whenever the model fails, times out or
+// replies with something that is not a plausible number of minutes.
+type geminiTravelAdjuster struct {
+	client   *genai.Client
+	fallback TravelAdjuster
+}
+
+func NewGeminiTravelAdjuster(ctx context.Context, apiKey string, fallback TravelAdjuster) (TravelAdjuster, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %v", err)
+	}
+	return &geminiTravelAdjuster{client: client, fallback: fallback}, nil
+}
+
+func (a *geminiTravelAdjuster) AdjustTravelTime(ctx context.Context, conditions *TravelConditions) (*TravelAdjustment, error) {
+	fallback, err := a.fallback.AdjustTravelTime(ctx, conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := a.ask(ctx, conditions)
+	if err != nil {
+		log.Printf("Gemini travel adjustment failed, using %s: %v", fallback.Adjuster, err)
+		return fallback, nil
+	}
+	return &TravelAdjustment{Duration: duration, Adjuster: "gemini"}, nil
+}
+
+func (a *geminiTravelAdjuster) ask(ctx context.Context, conditions *TravelConditions) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, geminiTimeout)
+	defer cancel()
+
+	var traffic []Traffic
+	if conditions.Traffic != nil {
+		traffic = trafficDetails(conditions.Traffic)
+	}
+	var weather []Weather
+	if conditions.Forecast != nil {
+		weather = weatherDetails(conditions.Forecast)
+	}
+
+	model := a.client.GenerativeModel(geminiModel)
+	resp, err := model.GenerateContent(ctx, genai.Text(fmt.Sprintf(`
+			Based on the following travel details, calculate the adjusted travel time between the two locations:
+
+			- Origin coordinates: Latitude %f, Longitude %f
+			- Destination coordinates: Latitude %f, Longitude %f
+			- Estimated travel time from the %s routing provider: %.0f mins
+			- Transportation method: %s
+			- Traffic data: %v
+			- Weather data along the route: %v
+
+			Adjust the travel time by accounting for the effects of traffic and weather conditions on the route. Return only the adjusted travel time as a numeric value in minutes, without any additional text or explanation.
+		  `, conditions.OriLatitude, conditions.OriLongitude, conditions.DestLatitude, conditions.DestLongitude, conditions.Base.Provider, conditions.Base.Duration.Minutes(), conditions.Transportation, traffic, weather)))
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return 0, fmt.Errorf("empty reply")
+	}
+
+	text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
+	if !ok {
+		return 0, fmt.Errorf("reply is not text")
+	}
+	reply := strings.TrimSpace(string(text))
+	if !geminiMinutesPattern.MatchString(reply) {
+		return 0, fmt.Errorf("reply is not a number of minutes: %q", reply)
+	}
+	minutes, err := strconv.ParseFloat(reply, 64)
+	if err != nil {
+		return 0, fmt.Errorf("reply is not a number of minutes: %q", reply)
+	}
+
+	duration := time.Duration(minutes * float64(time.Minute)).Round(time.Minute)
+	base := conditions.Base.Duration
+	if duration < time.Duration(float64(base)*geminiMinFactor) || duration > time.Duration(float64(base)*geminiMaxFactor) {
+		return 0, fmt.Errorf("reply of %.0f mins is out of bounds for a base of %.0f mins", minutes, base.Minutes())
+	}
+	return duration, nil
+}
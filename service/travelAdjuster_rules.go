@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Delay assumed for an incident without a reported delay, by its magnitude
+// (Ty): unknown, minor, moderate, major and undefined, which Azure uses for
+// closures.
+var incidentDelays = []time.Duration{
+	0: 0,
+	1: 2 * time.Minute,
+	2: 5 * time.Minute,
+	3: 10 * time.Minute,
+	4: 15 * time.Minute,
+}
+
+// Icon categories (Ic) for closures, which delay traffic even when the
+// magnitude is unknown.
+const (
+	incidentRoadClosed = 8
+	incidentLaneClosed = 7
+)
+
+// Slowdown for the worst weather hazard on the route, by MaxHazardIndex.
+var hazardFactors = []float64{
+	0: 1.0,
+	1: 1.05,
+	2: 1.15,
+	3: 1.3,
+	4: 1.5,
+}
+
+// ruleTravelAdjuster adds the delay of reported traffic incidents and slows
+// the trip down by the weather hazard. The same conditions always give the
+// same result.
+type ruleTravelAdjuster struct{}
+
+func NewRuleTravelAdjuster() TravelAdjuster {
+	return &ruleTravelAdjuster{}
+}
+
+func (a *ruleTravelAdjuster) AdjustTravelTime(ctx context.Context, conditions *TravelConditions) (*TravelAdjustment, error) {
+	base := conditions.Base.Duration
+
+	factor := hazardFactors[0]
+	if conditions.Forecast != nil {
+		for _, waypoint := range conditions.Forecast.Waypoints {
+			hazard := waypoint.Hazards.MaxHazardIndex
+			if hazard >= 0 && hazard < len(hazardFactors) && hazardFactors[hazard] > factor {
+				factor = hazardFactors[hazard]
+			}
+		}
+	}
+
+	var delay time.Duration
+	if conditions.Traffic != nil {
+		for _, incident := range conditions.Traffic.Tm.Poi {
+			delay += incidentDelay(incident.Dl, incident.Ty, incident.Ic)
+		}
+	}
+	// Incidents are looked up for the whole bounding box and may not all be
+	// on the route, so never more than double the trip for them
+	if delay > base {
+		delay = base
+	}
+
+	adjusted := time.Duration(float64(base)*factor) + delay
+	return &TravelAdjustment{Duration: adjusted.Round(time.Minute), Adjuster: "rules"}, nil
+}
+
+func incidentDelay(delaySeconds int, magnitude int, iconCategory int) time.Duration {
+	if delaySeconds > 0 {
+		return time.Duration(delaySeconds) * time.Second
+	}
+	if magnitude > 0 && magnitude < len(incidentDelays) {
+		return incidentDelays[magnitude]
+	}
+	switch iconCategory {
+	case incidentRoadClosed:
+		return incidentDelays[4]
+	case incidentLaneClosed:
+		return incidentDelays[2]
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package service
+
+type TravelObservationResponse struct {
+	ScheduleId       string  `json:"scheduleId"`
+	Date             string  `json:"date"`
+	Provider         string  `json:"provider"`
+	Adjuster         string  `json:"adjuster"`
+	ProviderMinutes  float64 `json:"providerMinutes"`
+	AdjustedMinutes  float64 `json:"adjustedMinutes"`
+	Correction       float64 `json:"correction"`
+	PredictedMinutes float64 `json:"predictedMinutes"`
+	ActualMinutes    float64 `json:"actualMinutes"`
+}
+
+// TravelAccuracy summarises prediction errors in minutes. Bias is the mean of
+// actual minus predicted, so a positive bias means trips ran longer than planned.
+type TravelAccuracy struct {
+	Observations              int     `json:"observations"`
+	MeanAbsoluteError         float64 `json:"meanAbsoluteError"`
+	Bias                      float64 `json:"bias"`
+	WithinFiveMinutes         float64 `json:"withinFiveMinutes"`
+	ProviderMeanAbsoluteError float64 `json:"providerMeanAbsoluteError"`
+}
+
+type TravelAccuracyPeriod struct {
+	StartDate string `json:"startDate"`
+	TravelAccuracy
+}
+
+type TravelAccuracyResponse struct {
+	TravelAccuracy
+	Weeks []TravelAccuracyPeriod `json:"weeks"`
+}
+
+type TravelObservationService interface {
+	ReportArrival(scheduleId string, arrivedAt string) (*TravelObservationResponse, error)
+	GetTravelAccuracy(googleId string, days int) (*TravelAccuracyResponse, error)
+}
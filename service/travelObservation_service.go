@@ -0,0 +1,223 @@
+package service
+
+import (
+	"errors"
+	"etalert-backend/repository"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInvalidArrival  = errors.New("arrival time does not match the trip")
+	ErrArrivalReported = errors.New("arrival already reported")
+)
+
+const (
+	// Reported trips on a route considered when learning its correction
+	correctionSampleSize = 20
+	// Reported trips needed before a route's correction is trusted
+	correctionMinSamples = 3
+
+	minCorrection = 0.5
+	maxCorrection = 2.0
+
+	maxTripDuration = 12 * time.Hour
+)
+
+type travelObservationService struct {
+	observationRepo repository.TravelObservationRepository
+	scheduleRepo    repository.ScheduleRepository
+	userRepo        repository.UserRepository
+}
+
+func NewTravelObservationService(observationRepo repository.TravelObservationRepository, scheduleRepo repository.ScheduleRepository, userRepo repository.UserRepository) TravelObservationService {
+	return &travelObservationService{observationRepo: observationRepo, scheduleRepo: scheduleRepo, userRepo: userRepo}
+}
+
+// ReportArrival accepts the id of the travel schedule or of any schedule in
+// its group. The trip is measured from the travel schedule's start, which
+// LeaveNow moves to the actual departure, to arrivedAt, a "15:04" clock time
+// in the user's time zone.
+func (t *travelObservationService) ReportArrival(scheduleId string, arrivedAt string) (*TravelObservationResponse, error) {
+	schedule, err := t.scheduleRepo.GetScheduleById(scheduleId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schedule: %v", err)
+	}
+	if schedule == nil {
+		return nil, ErrResourceNotFound
+	}
+
+	travelSchedule := schedule
+	if !schedule.IsTraveling {
+		schedules, err := t.scheduleRepo.GetSchedulesByGroupId(schedule.GroupId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get schedules by group ID: %v", err)
+		}
+		// A group has a single travel leg, which may start the day before
+		// the schedule it leads to
+		travelSchedule = nil
+		for _, sch := range schedules {
+			if sch.IsTraveling {
+				travelSchedule = sch
+				break
+			}
+		}
+		if travelSchedule == nil {
+			return nil, ErrResourceNotFound
+		}
+	}
+
+	loc := userLocation(t.userRepo, travelSchedule.GoogleId)
+	departure, _, err := scheduleSpan(travelSchedule, loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read departure time: %v", err)
+	}
+	// The arrival is a clock time on the day of departure or, past
+	// midnight, the day after
+	arrival, err := localInstant(localDate(departure, loc).Format("02-01-2006"), arrivedAt, loc)
+	if err != nil {
+		return nil, ErrInvalidArrival
+	}
+	if arrival.Before(departure) {
+		arrival = moveDays(arrival, 1, loc)
+	}
+	actual := arrival.Sub(departure)
+	if actual == 0 || actual > maxTripDuration {
+		return nil, ErrInvalidArrival
+	}
+
+	observation, err := t.observationRepo.GetObservationByScheduleId(travelSchedule.Id)
+	if err != nil {
+		return nil, err
+	}
+	if observation == nil {
+		return nil, ErrResourceNotFound
+	}
+
+	reported, err := t.observationRepo.ReportArrival(travelSchedule.Id, int(actual.Seconds()), time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !reported {
+		return nil, ErrArrivalReported
+	}
+	observation.ActualSeconds = int(actual.Seconds())
+
+	return newTravelObservationResponse(observation), nil
+}
+
+// GetTravelAccuracy covers trips of the last days days, grouped by the week
+// (starting Monday) they were scheduled in.
+func (t *travelObservationService) GetTravelAccuracy(googleId string, days int) (*TravelAccuracyResponse, error) {
+	today := localDate(time.Now(), userLocation(t.userRepo, googleId))
+	since := today.AddDate(0, 0, -days)
+
+	observations, err := t.observationRepo.GetReportedObservationsSince(googleId, since)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &TravelAccuracyResponse{
+		TravelAccuracy: summariseAccuracy(observations),
+		Weeks:          []TravelAccuracyPeriod{},
+	}
+
+	var week []*repository.TravelObservation
+	var weekStart time.Time
+	for _, observation := range observations {
+		start := startOfWeek(observation.Date)
+		if !start.Equal(weekStart) && len(week) > 0 {
+			response.Weeks = append(response.Weeks, TravelAccuracyPeriod{StartDate: weekStart.Format("02-01-2006"), TravelAccuracy: summariseAccuracy(week)})
+			week = nil
+		}
+		weekStart = start
+		week = append(week, observation)
+	}
+	if len(week) > 0 {
+		response.Weeks = append(response.Weeks, TravelAccuracyPeriod{StartDate: weekStart.Format("02-01-2006"), TravelAccuracy: summariseAccuracy(week)})
+	}
+
+	return response, nil
+}
+
+func summariseAccuracy(observations []*repository.TravelObservation) TravelAccuracy {
+	accuracy := TravelAccuracy{Observations: len(observations)}
+	if len(observations) == 0 {
+		return accuracy
+	}
+
+	var absoluteError, bias, providerError float64
+	within := 0
+	for _, observation := range observations {
+		diff := float64(observation.ActualSeconds-observation.PredictedSeconds) / 60
+		absoluteError += math.Abs(diff)
+		bias += diff
+		if math.Abs(diff) <= 5 {
+			within++
+		}
+		providerError += math.Abs(float64(observation.ActualSeconds-observation.ProviderSeconds) / 60)
+	}
+
+	n := float64(len(observations))
+	accuracy.MeanAbsoluteError = roundTo(absoluteError/n, 1)
+	accuracy.Bias = roundTo(bias/n, 1)
+	accuracy.WithinFiveMinutes = roundTo(float64(within)/n, 2)
+	accuracy.ProviderMeanAbsoluteError = roundTo(providerError/n, 1)
+	return accuracy
+}
+
+// routeCorrection is the median ratio of actual to adjusted travel time over
+// the user's recent reported trips on routeKey, or 1 while there are too few.
+// The adjusted time is used rather than the corrected prediction so the
+// correction does not feed back into itself.
+func routeCorrection(observationRepo repository.TravelObservationRepository, googleId string, routeKey string) (float64, error) {
+	observations, err := observationRepo.GetReportedObservations(googleId, routeKey, correctionSampleSize)
+	if err != nil {
+		return 1, err
+	}
+
+	ratios := make([]float64, 0, len(observations))
+	for _, observation := range observations {
+		if observation.AdjustedSeconds <= 0 {
+			continue
+		}
+		ratios = append(ratios, float64(observation.ActualSeconds)/float64(observation.AdjustedSeconds))
+	}
+	if len(ratios) < correctionMinSamples {
+		return 1, nil
+	}
+
+	sort.Float64s(ratios)
+	median := ratios[len(ratios)/2]
+	if len(ratios)%2 == 0 {
+		median = (ratios[len(ratios)/2-1] + median) / 2
+	}
+
+	return roundTo(math.Max(minCorrection, math.Min(maxCorrection, median)), 2), nil
+}
+
+func newTravelObservationResponse(observation *repository.TravelObservation) *TravelObservationResponse {
+	return &TravelObservationResponse{
+		ScheduleId:       observation.ScheduleId,
+		Date:             observation.Date.Format("02-01-2006"),
+		Provider:         observation.Provider,
+		Adjuster:         observation.Adjuster,
+		ProviderMinutes:  roundTo(float64(observation.ProviderSeconds)/60, 1),
+		AdjustedMinutes:  roundTo(float64(observation.AdjustedSeconds)/60, 1),
+		Correction:       observation.Correction,
+		PredictedMinutes: roundTo(float64(observation.PredictedSeconds)/60, 1),
+		ActualMinutes:    roundTo(float64(observation.ActualSeconds)/60, 1),
+	}
+}
+
+func startOfWeek(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
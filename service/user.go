@@ -5,17 +5,23 @@ type UserInput struct {
 	Email    string `bson:"email"`
 	Name     string `bson:"name"`
 	Image    string `bson:"image"`
+	TimeZone string `bson:"timeZone"`
+	Country  string `bson:"country"`
 }
 
 type UserUpdater struct {
-	Name  string `bson:"name"`
-	Image string `bson:"image"`
+	Name     string `bson:"name"`
+	Image    string `bson:"image"`
+	TimeZone string `bson:"timeZone"`
+	Country  string `bson:"country"`
 }
 
 type UserInfoResponse struct {
-	Name  string `bson:"name"`
-	Image string `bson:"image"`
-	Email string `bson:"email"`
+	Name     string `bson:"name"`
+	Image    string `bson:"image"`
+	Email    string `bson:"email"`
+	TimeZone string `bson:"timeZone"`
+	Country  string `bson:"country"`
 }
 
 type UserService interface {
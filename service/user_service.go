@@ -3,21 +3,31 @@ package service
 import (
 	"errors"
 	"etalert-backend/repository"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 )
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo        repository.UserRepository
+	scheduleRepo    repository.ScheduleRepository
+	scheduleLogRepo repository.ScheduleLogRepository
 }
 
 type InsertUserResponse struct {
 	IsExist bool `json:"isExist"`
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserRepository, scheduleRepo repository.ScheduleRepository, scheduleLogRepo repository.ScheduleLogRepository) UserService {
+	return &userService{userRepo: userRepo, scheduleRepo: scheduleRepo, scheduleLogRepo: scheduleLogRepo}
 }
 
-var ErrUserAlreadyExists = errors.New("user already exists")
+var (
+	ErrUserAlreadyExists = errors.New("user already exists")
+	ErrInvalidTimeZone   = errors.New("invalid time zone")
+	ErrInvalidCountry    = errors.New("invalid country")
+)
 
 func (s userService) InsertUser(user *UserInput) (*InsertUserResponse, error) {
 	existingUser, err := s.userRepo.GetUserInfo(user.GoogleId)
@@ -28,11 +38,20 @@ func (s userService) InsertUser(user *UserInput) (*InsertUserResponse, error) {
 		return &InsertUserResponse{IsExist: true}, ErrUserAlreadyExists
 	}
 
+	if err := validateTimeZone(user.TimeZone); err != nil {
+		return nil, err
+	}
+	if err := validateCountry(user.Country); err != nil {
+		return nil, err
+	}
+
 	err = s.userRepo.InsertUser(&repository.User{
 		GoogleId: user.GoogleId,
 		Email:    user.Email,
 		Name:     user.Name,
 		Image:    user.Image,
+		TimeZone: user.TimeZone,
+		Country:  strings.ToUpper(user.Country),
 	})
 	if err != nil {
 		return nil, err
@@ -45,23 +64,152 @@ func (s userService) GetUserInfo(gId string) (*UserInfoResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	if user == nil {
+		return nil, nil
+	}
+
+	timeZone := user.TimeZone
+	if timeZone == "" {
+		timeZone = repository.DefaultTimeZone
+	}
+
+	country := user.Country
+	if country == "" {
+		country = repository.DefaultCountry
+	}
 
 	userResponse := UserInfoResponse{
-		Name:  user.Name,
-		Image: user.Image,
-		Email: user.Email,
+		Name:     user.Name,
+		Image:    user.Image,
+		Email:    user.Email,
+		TimeZone: timeZone,
+		Country:  country,
 	}
 
 	return &userResponse, nil
 }
 
+// UpdateUser also moves the upcoming schedules of a user who changes time
+// zone, so they keep the clock times they were planned at.
 func (s userService) UpdateUser(gId string, user *UserUpdater) error {
-	err := s.userRepo.UpdateUser(gId, &repository.User{
-		Name:  user.Name,
-		Image: user.Image,
+	if err := validateTimeZone(user.TimeZone); err != nil {
+		return err
+	}
+	if err := validateCountry(user.Country); err != nil {
+		return err
+	}
+
+	current, err := s.userRepo.GetUserInfo(gId)
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.UpdateUser(gId, &repository.User{
+		Name:     user.Name,
+		Image:    user.Image,
+		TimeZone: user.TimeZone,
+		Country:  strings.ToUpper(user.Country),
 	})
 	if err != nil {
 		return err
 	}
+
+	if current != nil && user.TimeZone != "" && loadLocation(current.TimeZone).String() != loadLocation(user.TimeZone).String() {
+		return s.retimeSchedules(gId, loadLocation(current.TimeZone), loadLocation(user.TimeZone))
+	}
+	return nil
+}
+
+// retimeSchedules keeps the upcoming schedules of gId at the dates and clock
+// times they were planned for, now read in loc instead of oldLoc. Their
+// pending travel checks move along with the earliest schedule of their
+// group. Schedules that already started and checks that already ran keep
+// the instants they happened at.
+func (s userService) retimeSchedules(gId string, oldLoc *time.Location, loc *time.Location) error {
+	now := time.Now()
+	schedules, err := s.scheduleRepo.GetSchedulesStartingFrom(gId, now)
+	if err != nil {
+		return fmt.Errorf("failed to get upcoming schedules: %v", err)
+	}
+
+	// How far the earliest upcoming schedule of each group moved
+	var groupIds []int
+	firstStartAt := make(map[int]time.Time)
+	shifts := make(map[int]time.Duration)
+	for _, schedule := range schedules {
+		startAt, endAt, err := scheduleInstants(schedule.Date.Format("02-01-2006"), schedule.StartTime, schedule.EndTime, loc)
+		if err != nil {
+			log.Printf("Skipping schedule %s: %v", schedule.Id, err)
+			continue
+		}
+		err = s.scheduleRepo.SetScheduleInstants(schedule.Id, startAt.UTC(), endAt.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to update schedule %s: %v", schedule.Id, err)
+		}
+
+		first, ok := firstStartAt[schedule.GroupId]
+		if !ok {
+			groupIds = append(groupIds, schedule.GroupId)
+		}
+		if !ok || schedule.StartAt.Before(first) {
+			firstStartAt[schedule.GroupId] = schedule.StartAt
+			shifts[schedule.GroupId] = startAt.Sub(schedule.StartAt)
+		}
+	}
+
+	for _, groupId := range groupIds {
+		scheduleLog, err := s.scheduleLogRepo.GetScheduleLogByGroupId(groupId)
+		if err != nil {
+			return fmt.Errorf("failed to get schedule log: %v", err)
+		}
+		if scheduleLog == nil {
+			continue
+		}
+		checkAt, err := scheduleLogCheckAt(scheduleLog, oldLoc)
+		if err != nil {
+			log.Printf("Skipping schedule log of group %d: %v", groupId, err)
+			continue
+		}
+		if checkAt.Before(now) {
+			continue
+		}
+		err = moveScheduleLog(s.scheduleLogRepo, groupId, scheduleLog.RecurrenceId, checkAt.Add(shifts[groupId]), loc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTimeZone accepts IANA names such as "Europe/London". An empty name
+// leaves the user on repository.DefaultTimeZone.
+func validateTimeZone(name string) error {
+	if name == "" {
+		return nil
+	}
+	// LoadLocation also accepts "Local", which means the server's zone
+	if name == "Local" {
+		return ErrInvalidTimeZone
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ErrInvalidTimeZone
+	}
+	return nil
+}
+
+// validateCountry accepts two-letter ISO 3166 codes such as "TH". An empty
+// code leaves the user on repository.DefaultCountry.
+func validateCountry(code string) error {
+	if code == "" {
+		return nil
+	}
+	if len(code) != 2 {
+		return ErrInvalidCountry
+	}
+	for _, r := range strings.ToUpper(code) {
+		if r < 'A' || r > 'Z' {
+			return ErrInvalidCountry
+		}
+	}
 	return nil
 }
@@ -2,6 +2,7 @@ package service
 
 import (
 	"etalert-backend/repository"
+	"etalert-backend/websocket"
 	"fmt"
 	"time"
 
@@ -15,10 +16,11 @@ type weeklyReportService struct {
 	weeklyReportListRepo repository.WeeklyReportListRepository
 	routineLogRepo       repository.RoutineLogRepository
 	tagRepo              repository.TagRepository
+	hub                  *websocket.Hub
 }
 
-func NewWeeklyReportService(weeklyReportRepo repository.WeeklyReportRepository, userRepo repository.UserRepository, routineRepo repository.RoutineRepository, weeklyReportListRepo repository.WeeklyReportListRepository, routineLogRepo repository.RoutineLogRepository, tagRepo repository.TagRepository) WeeklyReportService {
-	return &weeklyReportService{weeklyReportRepo: weeklyReportRepo, userRepo: userRepo, routineRepo: routineRepo, weeklyReportListRepo: weeklyReportListRepo, routineLogRepo: routineLogRepo, tagRepo: tagRepo}
+func NewWeeklyReportService(weeklyReportRepo repository.WeeklyReportRepository, userRepo repository.UserRepository, routineRepo repository.RoutineRepository, weeklyReportListRepo repository.WeeklyReportListRepository, routineLogRepo repository.RoutineLogRepository, tagRepo repository.TagRepository, hub *websocket.Hub) WeeklyReportService {
+	return &weeklyReportService{weeklyReportRepo: weeklyReportRepo, userRepo: userRepo, routineRepo: routineRepo, weeklyReportListRepo: weeklyReportListRepo, routineLogRepo: routineLogRepo, tagRepo: tagRepo, hub: hub}
 }
 
 func (w *weeklyReportService) StartCronJob() {
@@ -27,69 +29,86 @@ func (w *weeklyReportService) StartCronJob() {
 	c.Start()
 }
 
+// generateWeeklyReport runs every minute and reports on the past week to each
+// user whose Monday has just begun in their own time zone.
 func (w *weeklyReportService) generateWeeklyReport() {
-	now := time.Now().UTC().Add(7 * time.Hour)
-	if now.Weekday() == time.Monday && now.Hour() == 0 && now.Minute() == 0 {
-		users, err := w.userRepo.GetAllUsersId()
-		if err != nil {
-			fmt.Println(err)
-			return
+	nowUTC := time.Now().UTC()
+	// Time zones are offset from UTC by multiples of 15 minutes
+	if nowUTC.Minute()%15 != 0 {
+		return
+	}
+
+	users, err := w.userRepo.GetAllUsers()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, user := range users {
+		now := nowUTC.In(loadLocation(user.TimeZone))
+		if now.Weekday() == time.Monday && now.Hour() == 0 && now.Minute() == 0 {
+			w.generateUserWeeklyReport(user.GoogleId, now)
 		}
+	}
+}
 
-		for _, user := range users {
-			routines, err := w.routineRepo.GetAllRoutines(user)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
+func (w *weeklyReportService) generateUserWeeklyReport(googleId string, now time.Time) {
+	routines, err := w.routineRepo.GetAllRoutines(googleId)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-			aWeekAgo := now.AddDate(0, 0, -7)
-			routineReports, err := w.routineLogRepo.GetRoutineLogs(user, aWeekAgo.Format("02-01-2006"))
+	aWeekAgo := now.AddDate(0, 0, -7)
+	routineReports, err := w.routineLogRepo.GetRoutineLogs(googleId, aWeekAgo.Format("02-01-2006"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if len((routines)) != 0 && len(routineReports) != 0 {
+		w.weeklyReportListRepo.InsertWeeklyReportList(&repository.WeeklyReportList{
+			GoogleId:  googleId,
+			StartDate: aWeekAgo.Format("02-01-2006"),
+			EndDate:   now.AddDate(0, 0, -1).Format("02-01-2006"),
+		})
+
+		var weeklyReportDetails []*repository.WeeklyReportDetail
+		for _, routine := range routines {
+			tag, err := w.tagRepo.GetTagByRoutineId(routine.Id)
 			if err != nil {
 				fmt.Println(err)
-				return
 			}
-
-			if len((routines)) != 0 && len(routineReports) != 0 {
-				w.weeklyReportListRepo.InsertWeeklyReportList(&repository.WeeklyReportList{
-					GoogleId:  user,
-					StartDate: aWeekAgo.Format("02-01-2006"),
-					EndDate:   now.AddDate(0, 0, -1).Format("02-01-2006"),
-				})
-
-				var weeklyReportDetails []*repository.WeeklyReportDetail
-				for _, routine := range routines {
-					tag, err := w.tagRepo.GetTagByRoutineId(routine.Id)
-					if err != nil {
-						fmt.Println(err)
+			for _, routineReport := range routineReports {
+				if routine.Id == routineReport.RoutineId {
+					weeklyReportDetail := &repository.WeeklyReportDetail{
+						Date:          routineReport.Date,
+						StartTime:     routineReport.StartTime,
+						EndTime:       routineReport.EndTime,
+						ActualEndTime: routineReport.ActualEndTime,
+						Skewness:      routineReport.Skewness,
 					}
-					for _, routineReport := range routineReports {
-						if routine.Id == routineReport.RoutineId {
-							weeklyReportDetail := &repository.WeeklyReportDetail{
-								Date:          routineReport.Date,
-								StartTime:     routineReport.StartTime,
-								EndTime:       routineReport.EndTime,
-								ActualEndTime: routineReport.ActualEndTime,
-								Skewness:      routineReport.Skewness,
-							}
-							weeklyReportDetails = append(weeklyReportDetails, weeklyReportDetail)
-						}
-					}
-
-					weeklyReport := &repository.WeeklyReport{
-						GoogleId:  user,
-						Name:      routine.Name,
-						StartDate: aWeekAgo.Format("02-01-2006"),
-						EndDate:   now.AddDate(0, 0, -1).Format("02-01-2006"),
-						Tag:       tag.Name,
-						Details:   weeklyReportDetails,
-					}
-					w.weeklyReportRepo.InsertWeeklyReport(weeklyReport)
+					weeklyReportDetails = append(weeklyReportDetails, weeklyReportDetail)
 				}
 			}
-			fmt.Printf("Weekly report generated for %s \n", user)
+
+			weeklyReport := &repository.WeeklyReport{
+				GoogleId:  googleId,
+				Name:      routine.Name,
+				StartDate: aWeekAgo.Format("02-01-2006"),
+				EndDate:   now.AddDate(0, 0, -1).Format("02-01-2006"),
+				Tag:       tag.Name,
+				Details:   weeklyReportDetails,
+			}
+			w.weeklyReportRepo.InsertWeeklyReport(weeklyReport)
 		}
+
+		w.hub.Publish(googleId, websocket.NewEvent(websocket.EventReportReady, websocket.ReportReadyPayload{
+			StartDate: aWeekAgo.Format("02-01-2006"),
+			EndDate:   now.AddDate(0, 0, -1).Format("02-01-2006"),
+		}))
 	}
+	fmt.Printf("Weekly report generated for %s \n", googleId)
 }
 
 func (w *weeklyReportService) GetWeeklyReports(googleId string, date string) ([]WeeklyReportResponse, error) {
@@ -0,0 +1,47 @@
+package websocket
+
+import "sync"
+
+// DeliverFunc hands a message that arrived over the backplane to the local hub.
+type DeliverFunc func(googleId string, seq int, message []byte)
+
+// Backplane carries messages between every instance running a hub, so an
+// update produced on one instance reaches the user's socket on any other.
+// Publish must also deliver the message to the instance that published it.
+type Backplane interface {
+	Publish(googleId string, seq int, message []byte) error
+	Subscribe(deliver DeliverFunc) error
+	Close() error
+}
+
+// localBackplane is used when only one instance runs; it delivers straight
+// back into the hub.
+type localBackplane struct {
+	mu      sync.RWMutex
+	deliver DeliverFunc
+}
+
+func NewLocalBackplane() Backplane {
+	return &localBackplane{}
+}
+
+func (b *localBackplane) Publish(googleId string, seq int, message []byte) error {
+	b.mu.RLock()
+	deliver := b.deliver
+	b.mu.RUnlock()
+	if deliver != nil {
+		deliver(googleId, seq, message)
+	}
+	return nil
+}
+
+func (b *localBackplane) Subscribe(deliver DeliverFunc) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deliver = deliver
+	return nil
+}
+
+func (b *localBackplane) Close() error {
+	return nil
+}
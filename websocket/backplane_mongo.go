@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Size of the capped collection; old messages are overwritten in place
+	backplaneCollectionSize = 16 * 1024 * 1024
+
+	// Wait before reopening the tailable cursor after it dies
+	backplaneRetryInterval = time.Second
+)
+
+type backplaneMessage struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty"`
+	GoogleId  string             `bson:"googleId"`
+	Seq       int                `bson:"seq"`
+	Message   string             `bson:"message"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// mongoBackplane fans messages out through a capped collection that every
+// instance tails. Unlike a change stream it also works on a standalone mongod.
+type mongoBackplane struct {
+	collection *mongo.Collection
+	cancel     context.CancelFunc
+}
+
+func NewMongoBackplane(client *mongo.Client, dbName string, collName string) (Backplane, error) {
+	ctx := context.Background()
+	database := client.Database(dbName)
+
+	err := database.CreateCollection(ctx, collName, options.CreateCollection().SetCapped(true).SetSizeInBytes(backplaneCollectionSize))
+	if err != nil {
+		var commandErr mongo.CommandError
+		// NamespaceExists: another instance created it first
+		if !errors.As(err, &commandErr) || !commandErr.HasErrorCode(48) {
+			return nil, fmt.Errorf("failed to create backplane collection: %v", err)
+		}
+	}
+
+	collection := database.Collection(collName)
+
+	// A tailable cursor on an empty capped collection dies immediately
+	count, err := collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count backplane messages: %v", err)
+	}
+	if count == 0 {
+		if _, err := collection.InsertOne(ctx, backplaneMessage{CreatedAt: time.Now()}); err != nil {
+			return nil, fmt.Errorf("failed to seed backplane collection: %v", err)
+		}
+	}
+
+	return &mongoBackplane{collection: collection}, nil
+}
+
+func (b *mongoBackplane) Publish(googleId string, seq int, message []byte) error {
+	ctx := context.Background()
+	_, err := b.collection.InsertOne(ctx, backplaneMessage{
+		GoogleId:  googleId,
+		Seq:       seq,
+		Message:   string(message),
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+func (b *mongoBackplane) Subscribe(deliver DeliverFunc) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	go b.tail(ctx, deliver)
+	return nil
+}
+
+// tail follows the collection from the moment of subscribing. When the cursor
+// is lost it resumes after the last message seen.
+func (b *mongoBackplane) tail(ctx context.Context, deliver DeliverFunc) {
+	lastId := primitive.NewObjectIDFromTimestamp(time.Now())
+
+	for ctx.Err() == nil {
+		cursor, err := b.collection.Find(ctx, bson.M{"_id": bson.M{"$gt": lastId}}, options.Find().SetCursorType(options.TailableAwait))
+		if err != nil {
+			log.Printf("Failed to tail websocket backplane: %v", err)
+		} else {
+			for cursor.Next(ctx) {
+				var message backplaneMessage
+				if err := cursor.Decode(&message); err != nil {
+					log.Printf("Failed to decode backplane message: %v", err)
+					continue
+				}
+				lastId = message.Id
+				if message.GoogleId != "" {
+					deliver(message.GoogleId, message.Seq, []byte(message.Message))
+				}
+			}
+			if err := cursor.Err(); err != nil && ctx.Err() == nil {
+				log.Printf("Websocket backplane cursor closed: %v", err)
+			}
+			cursor.Close(context.Background())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backplaneRetryInterval):
+		}
+	}
+}
+
+func (b *mongoBackplane) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+)
+
+const (
+	EventCommandAck   = "command.ack"
+	EventCommandError = "command.error"
+)
+
+// Codes carried in CommandErrorPayload.Code.
+const (
+	CodeInvalidMessage = "invalid_message"
+	CodeUnknownCommand = "unknown_command"
+	CodeInvalidPayload = "invalid_payload"
+	CodeNotFound       = "not_found"
+	CodeForbidden      = "forbidden"
+	CodeConflict       = "conflict"
+	CodeInternal       = "internal_error"
+)
+
+// Command is a message sent by the client. Its Id is echoed back in the
+// ack or error frame that answers it.
+type Command struct {
+	Id      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type CommandAckPayload struct {
+	CommandId string      `json:"commandId"`
+	Result    interface{} `json:"result,omitempty"`
+}
+
+type CommandErrorPayload struct {
+	CommandId string `json:"commandId,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// CommandError is returned by a CommandHandler to choose the code the client
+// sees. Any other error is reported as an internal error.
+type CommandError struct {
+	Code    string
+	Message string
+}
+
+func (e *CommandError) Error() string {
+	return e.Message
+}
+
+func NewCommandError(code string, message string) *CommandError {
+	return &CommandError{Code: code, Message: message}
+}
+
+// CommandHandler runs a command for the authenticated googleId and returns
+// the result to put in the ack.
+type CommandHandler func(googleId string, payload json.RawMessage) (interface{}, error)
+
+// HandleCommand registers the handler for a command type. Handlers must be
+// registered before the server starts accepting connections.
+func (h *Hub) HandleCommand(commandType string, handler CommandHandler) {
+	h.commands[commandType] = handler
+}
+
+// dispatch runs one client message. Commands of a connection run one at a
+// time, in the order they were sent.
+func (c *Client) dispatch(message []byte) {
+	var command Command
+	if err := json.Unmarshal(message, &command); err != nil || command.Id == "" || command.Type == "" {
+		c.reply(NewEvent(EventCommandError, CommandErrorPayload{
+			CommandId: command.Id,
+			Code:      CodeInvalidMessage,
+			Message:   "commands need an id and a type",
+		}))
+		return
+	}
+
+	handler, ok := c.hub.commands[command.Type]
+	if !ok {
+		c.reply(NewEvent(EventCommandError, CommandErrorPayload{
+			CommandId: command.Id,
+			Code:      CodeUnknownCommand,
+			Message:   "unknown command: " + command.Type,
+		}))
+		return
+	}
+
+	result, err := handler(c.googleId, command.Payload)
+	if err != nil {
+		var commandErr *CommandError
+		if !errors.As(err, &commandErr) {
+			log.Printf("Command %s from %s failed: %v", command.Type, c.googleId, err)
+			commandErr = NewCommandError(CodeInternal, "command failed")
+		}
+		c.reply(NewEvent(EventCommandError, CommandErrorPayload{
+			CommandId: command.Id,
+			Code:      commandErr.Code,
+			Message:   commandErr.Message,
+		}))
+		return
+	}
+
+	c.reply(NewEvent(EventCommandAck, CommandAckPayload{CommandId: command.Id, Result: result}))
+}
+
+// reply queues a frame for this connection only. Replies are not logged for
+// replay since they only make sense to the socket that sent the command.
+func (c *Client) reply(event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", event.Type, err)
+		return
+	}
+
+	select {
+	case c.replies <- message:
+	default:
+		log.Printf("Dropping %s reply to %s: queue full", event.Type, c.googleId)
+		c.hub.metrics.dropped.Add(1)
+	}
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config controls how long a connection may stay silent before it is
+// considered dead or idle.
+type Config struct {
+	// How often the server pings the client
+	PingInterval time.Duration
+	// How long to wait for any frame, pongs included, before dropping the connection
+	PongWait time.Duration
+	// Time allowed to write a single message to the peer
+	WriteWait time.Duration
+	// Close connections that exchanged no messages, heartbeats aside, for
+	// this long. Zero keeps them open.
+	IdleTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PingInterval: 30 * time.Second,
+		PongWait:     60 * time.Second,
+		WriteWait:    10 * time.Second,
+	}
+}
+
+// ConfigFromEnv overrides the defaults with WS_PING_INTERVAL, WS_PONG_WAIT,
+// WS_WRITE_WAIT and WS_IDLE_TIMEOUT, given as Go durations such as "45s".
+func ConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	settings := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"WS_PING_INTERVAL", &config.PingInterval},
+		{"WS_PONG_WAIT", &config.PongWait},
+		{"WS_WRITE_WAIT", &config.WriteWait},
+		{"WS_IDLE_TIMEOUT", &config.IdleTimeout},
+	}
+	for _, setting := range settings {
+		raw := os.Getenv(setting.name)
+		if raw == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(raw)
+		if err != nil || duration < 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q", setting.name, raw)
+		}
+		*setting.value = duration
+	}
+
+	if config.PingInterval <= 0 || config.PongWait <= 0 || config.WriteWait <= 0 {
+		return Config{}, fmt.Errorf("websocket ping interval, pong wait and write wait must be positive")
+	}
+	if config.PingInterval >= config.PongWait {
+		return Config{}, fmt.Errorf("WS_PING_INTERVAL must be shorter than WS_PONG_WAIT")
+	}
+	return config, nil
+}
@@ -0,0 +1,138 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"etalert-backend/repository"
+	"log"
+	"time"
+)
+
+// EventVersion is bumped whenever an existing payload changes shape, so
+// clients can ignore events they do not understand.
+const EventVersion = 1
+
+const (
+	EventScheduleUpdated = "schedule.updated"
+	EventScheduleDeleted = "schedule.deleted"
+	EventRoutineChanged  = "routine.changed"
+	EventReportReady     = "report.ready"
+
+	// Sent instead of a replay when the missed events are no longer logged;
+	// the client should refetch its data and continue from the event's seq
+	EventResyncRequired = "resync.required"
+)
+
+// Reasons a schedule was moved, carried in ScheduleUpdatedPayload.Reason.
+const (
+	ReasonTravelTime = "travel_time"
+	ReasonUserEdit   = "user_edit"
+	ReasonSnoozed    = "snoozed"
+	ReasonDeparted   = "departed"
+)
+
+// Actions carried in RoutineChangedPayload.Action.
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// Event is the envelope of every message the server pushes to a client. Seq
+// increases by one for every event published to the same user.
+type Event struct {
+	Type      string      `json:"type"`
+	Seq       int         `json:"seq,omitempty"`
+	Version   int         `json:"version"`
+	Id        string      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Payload   interface{} `json:"payload"`
+}
+
+type ScheduleUpdatedPayload struct {
+	Id            string     `json:"id"`
+	Name          string     `json:"name"`
+	Date          string     `json:"date"`
+	StartTime     string     `json:"startTime"`
+	EndTime       string     `json:"endTime"`
+	StartAt       *time.Time `json:"startAt,omitempty"`
+	EndAt         *time.Time `json:"endAt,omitempty"`
+	IsHaveEndTime bool       `json:"isHaveEndTime"`
+	GroupId       int        `json:"groupId"`
+	RecurrenceId  int        `json:"recurrenceId"`
+	Reason        string     `json:"reason"`
+}
+
+type ScheduleDeletedPayload struct {
+	GroupId      int    `json:"groupId,omitempty"`
+	RecurrenceId int    `json:"recurrenceId,omitempty"`
+	FromDate     string `json:"fromDate,omitempty"`
+}
+
+type RoutineChangedPayload struct {
+	Id       string `json:"id"`
+	Action   string `json:"action"`
+	Name     string `json:"name,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+	Order    int    `json:"order,omitempty"`
+}
+
+type ReportReadyPayload struct {
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+type ResyncRequiredPayload struct {
+	LastSeq int `json:"lastSeq"`
+}
+
+func NewEvent(eventType string, payload interface{}) Event {
+	return Event{
+		Type:      eventType,
+		Version:   EventVersion,
+		Id:        newEventId(),
+		Timestamp: time.Now().UTC(),
+		Payload:   payload,
+	}
+}
+
+func newEventId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().String()))[:24]
+	}
+	return hex.EncodeToString(b)
+}
+
+// Publish assigns event the user's next sequence number, logs it for replay
+// and sends it to every connection of googleId on any instance. If the log is
+// unavailable the event is still delivered live, without a sequence number.
+func (h *Hub) Publish(googleId string, event Event) {
+	seq, err := h.eventLog.GetNextSequence(googleId)
+	if err != nil {
+		log.Printf("Failed to assign sequence to %s event: %v", event.Type, err)
+	}
+	event.Seq = seq
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", event.Type, err)
+		return
+	}
+
+	if seq != 0 {
+		err = h.eventLog.InsertEvent(&repository.EventLog{
+			GoogleId:  googleId,
+			Seq:       seq,
+			Message:   string(message),
+			CreatedAt: event.Timestamp,
+			ExpiresAt: event.Timestamp.Add(eventRetention),
+		})
+		if err != nil {
+			log.Printf("Failed to log %s event: %v", event.Type, err)
+		}
+	}
+
+	h.broadcast(googleId, seq, message)
+}
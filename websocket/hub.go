@@ -0,0 +1,308 @@
+package websocket
+
+import (
+	"etalert-backend/repository"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	// Messages queued for a connection before it is treated as a slow consumer
+	sendBufferSize = 64
+
+	// Command replies queued for a connection; the reader never blocks on them
+	replyBufferSize = 16
+)
+
+type outboundMessage struct {
+	googleId string
+	seq      int
+	message  []byte
+}
+
+// Hub owns every live connection. Its maps are only touched by the Run
+// goroutine; connection goroutines and services talk to it over channels.
+type Hub struct {
+	clients    map[string]map[*Client]bool
+	register   chan *Client
+	unregister chan *Client
+	outbound   chan outboundMessage
+	eventLog   repository.EventLogRepository
+	sessions   repository.SessionRepository
+	backplane  Backplane
+	commands   map[string]CommandHandler
+	config     Config
+	metrics    *metrics
+}
+
+// Client is one socket of a user. A user may hold several, one per device.
+type Client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	googleId  string
+	sessionId string
+	send      chan outboundMessage
+	replies   chan []byte
+	done      chan struct{}
+
+	// Highest sequence already written by replay; the writer skips queued
+	// events at or below it so nothing is delivered twice
+	replayedSeq int
+
+	// Unix nanoseconds of the last message read or written, heartbeats aside
+	lastActivity atomic.Int64
+}
+
+func NewHub(eventLog repository.EventLogRepository, sessions repository.SessionRepository, backplane Backplane, config Config) *Hub {
+	return &Hub{
+		clients:    make(map[string]map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		outbound:   make(chan outboundMessage, 256),
+		eventLog:   eventLog,
+		sessions:   sessions,
+		backplane:  backplane,
+		commands:   make(map[string]CommandHandler),
+		config:     config,
+		metrics:    newMetrics(),
+	}
+}
+
+func (h *Hub) Run() {
+	if err := h.backplane.Subscribe(h.deliver); err != nil {
+		log.Printf("Failed to subscribe to websocket backplane: %v", err)
+	}
+
+	for {
+		select {
+		case client := <-h.register:
+			if h.clients[client.googleId] == nil {
+				h.clients[client.googleId] = make(map[*Client]bool)
+			}
+			h.clients[client.googleId][client] = true
+			h.metrics.connected(client.googleId)
+
+		case client := <-h.unregister:
+			h.remove(client)
+
+		case out := <-h.outbound:
+			for client := range h.clients[out.googleId] {
+				select {
+				case client.send <- out:
+				default:
+					log.Printf("Evicting slow websocket consumer for user %s", client.googleId)
+					h.metrics.dropped.Add(1)
+					h.remove(client)
+				}
+			}
+		}
+	}
+}
+
+// remove closes the client's send queue, which makes its writer close the
+// socket. It is safe to call for a client that is already gone.
+func (h *Hub) remove(client *Client) {
+	userClients := h.clients[client.googleId]
+	if !userClients[client] {
+		return
+	}
+	delete(userClients, client)
+	if len(userClients) == 0 {
+		delete(h.clients, client.googleId)
+	}
+	h.metrics.disconnected(client.googleId)
+	close(client.send)
+}
+
+// SendUpdate sends a message to every connection of targetUserId on any
+// instance. Messages sent this way are not logged and cannot be replayed; use
+// Publish for events.
+func (h *Hub) SendUpdate(updateMessage []byte, targetUserId string) {
+	h.broadcast(targetUserId, 0, updateMessage)
+}
+
+func (h *Hub) broadcast(googleId string, seq int, message []byte) {
+	if err := h.backplane.Publish(googleId, seq, message); err != nil {
+		// Still reach the sockets held by this instance
+		log.Printf("Failed to publish to websocket backplane: %v", err)
+		h.deliver(googleId, seq, message)
+	}
+}
+
+// deliver queues a message for the connections of googleId held by this instance.
+func (h *Hub) deliver(googleId string, seq int, message []byte) {
+	h.outbound <- outboundMessage{googleId: googleId, seq: seq, message: message}
+}
+
+// HandleConnections serves a socket whose upgrade request was authenticated
+// by middlewares.ValidateWebSocket, which leaves the token's googleId,
+// session and expiry in the connection locals.
+func (h *Hub) HandleConnections(c *websocket.Conn) {
+	userId, _ := c.Locals("userId").(string)
+	sessionId, _ := c.Locals("sessionId").(string)
+	expiresAt, _ := c.Locals("tokenExpiresAt").(time.Time)
+	if userId == "" || sessionId == "" || expiresAt.IsZero() {
+		closeConnection(c, websocket.ClosePolicyViolation, "unauthenticated")
+		return
+	}
+
+	client := &Client{
+		hub:       h,
+		conn:      c,
+		googleId:  userId,
+		sessionId: sessionId,
+		send:      make(chan outboundMessage, sendBufferSize),
+		replies:   make(chan []byte, replyBufferSize),
+		done:      make(chan struct{}),
+	}
+	client.touch()
+	h.register <- client
+	log.Printf("Registered userId %s with connection", userId)
+
+	// Live events queue up in send while the missed ones are written, and
+	// the writer is only started afterwards to keep a single writer
+	if lastSeq := c.Query("lastSeq"); lastSeq != "" {
+		client.replay(lastSeq)
+	}
+
+	go client.writePump()
+
+	// Drop the connection once the access token it was opened with expires;
+	// the client is expected to refresh its token and reconnect
+	expiry := time.AfterFunc(time.Until(expiresAt), func() {
+		closeConnection(c, websocket.ClosePolicyViolation, "access token expired")
+	})
+
+	client.readPump()
+
+	expiry.Stop()
+	h.unregister <- client
+	// The connection is recycled once this handler returns, so wait for the writer
+	<-client.done
+}
+
+// readPump returns once nothing, not even a pong, has arrived within PongWait.
+func (c *Client) readPump() {
+	pongWait := c.hub.config.PongWait
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			log.Printf("error: %v", err)
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		c.touch()
+
+		c.dispatch(message)
+	}
+}
+
+func (c *Client) writePump() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.hub.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var message []byte
+		select {
+		case <-ticker.C:
+			if c.isIdle() {
+				log.Printf("Closing idle websocket connection for user %s", c.googleId)
+				closeConnection(c.conn, websocket.CloseGoingAway, "idle timeout")
+				c.drain()
+				return
+			}
+			// A revoked session keeps its access token valid until it
+			// expires, so the session is checked again on every ping
+			if c.isRevoked() {
+				log.Printf("Closing websocket connection of revoked session for user %s", c.googleId)
+				closeConnection(c.conn, websocket.ClosePolicyViolation, "session revoked")
+				c.drain()
+				return
+			}
+			deadline := time.Now().Add(c.hub.config.WriteWait)
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				log.Printf("error: %v", err)
+				c.conn.Close()
+				c.drain()
+				return
+			}
+			continue
+		case out, ok := <-c.send:
+			if !ok {
+				// The hub closed the queue: either the reader is gone or this client was evicted
+				closeConnection(c.conn, websocket.CloseNormalClosure, "")
+				return
+			}
+			if out.seq != 0 && out.seq <= c.replayedSeq {
+				continue
+			}
+			message = out.message
+		case message = <-c.replies:
+		}
+
+		if err := c.write(message); err != nil {
+			log.Printf("error: %v", err)
+			c.conn.Close()
+			c.drain()
+			return
+		}
+	}
+}
+
+// drain discards queued messages until the hub closes the queue, which it
+// does once the reader has seen the closed connection.
+func (c *Client) drain() {
+	for range c.send {
+	}
+}
+
+func (c *Client) write(message []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.hub.config.WriteWait))
+	if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		return err
+	}
+	c.hub.metrics.sent.Add(1)
+	c.touch()
+	return nil
+}
+
+// isRevoked reports whether the session the socket was opened with has been
+// logged out. A failed lookup keeps the connection open.
+func (c *Client) isRevoked() bool {
+	session, err := c.hub.sessions.GetSessionById(c.sessionId)
+	if err != nil {
+		log.Printf("Failed to check websocket session: %v", err)
+		return false
+	}
+	return session == nil || session.IsRevoked
+}
+
+func (c *Client) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (c *Client) isIdle() bool {
+	idleTimeout := c.hub.config.IdleTimeout
+	if idleTimeout <= 0 {
+		return false
+	}
+	return time.Since(time.Unix(0, c.lastActivity.Load())) > idleTimeout
+}
+
+func closeConnection(c *websocket.Conn, code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	if err := c.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second)); err != nil {
+		log.Printf("error: %v", err)
+	}
+	c.Close()
+}
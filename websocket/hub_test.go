@@ -0,0 +1,325 @@
+package websocket
+
+import (
+	"encoding/json"
+	"etalert-backend/repository"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	fastws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+type fakeEventLog struct {
+	mu     sync.Mutex
+	seqs   map[string]int
+	events []*repository.EventLog
+}
+
+func newFakeEventLog() *fakeEventLog {
+	return &fakeEventLog{seqs: make(map[string]int)}
+}
+
+func (l *fakeEventLog) GetNextSequence(googleId string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seqs[googleId]++
+	return l.seqs[googleId], nil
+}
+
+func (l *fakeEventLog) GetCurrentSequence(googleId string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seqs[googleId], nil
+}
+
+func (l *fakeEventLog) InsertEvent(event *repository.EventLog) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	return nil
+}
+
+func (l *fakeEventLog) GetEventsAfter(googleId string, seq int, limit int64) ([]*repository.EventLog, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []*repository.EventLog
+	for _, event := range l.events {
+		if event.GoogleId == googleId && event.Seq > seq && int64(len(events)) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// fakeSessions treats every session as active until it is revoked.
+type fakeSessions struct {
+	mu      sync.Mutex
+	revoked map[string]bool
+}
+
+func newFakeSessions() *fakeSessions {
+	return &fakeSessions{revoked: make(map[string]bool)}
+}
+
+func (s *fakeSessions) GetSessionById(id string) (*repository.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &repository.Session{Id: id, IsRevoked: s.revoked[id]}, nil
+}
+
+func (s *fakeSessions) RevokeSession(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[id] = true
+	return nil
+}
+
+func (s *fakeSessions) InsertSession(session *repository.Session) error { return nil }
+func (s *fakeSessions) GetActiveSessions(googleId string) ([]*repository.Session, error) {
+	return nil, nil
+}
+func (s *fakeSessions) RotateRefreshToken(id string, oldJti string, newJti string, usedAt time.Time, expiresAt time.Time) (bool, error) {
+	return true, nil
+}
+func (s *fakeSessions) TouchSession(id string, usedAt time.Time) error { return nil }
+func (s *fakeSessions) RevokeAllSessions(googleId string) error         { return nil }
+
+func newTestHub(config Config) (*Hub, *fakeSessions) {
+	sessions := newFakeSessions()
+	hub := NewHub(newFakeEventLog(), sessions, NewLocalBackplane(), config)
+	go hub.Run()
+	return hub, sessions
+}
+
+// serveHub serves hub on a local port, authenticating every socket as the
+// googleId and sessionId query parameters, and returns its ws:// address.
+func serveHub(t *testing.T, hub *Hub) string {
+	t.Helper()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws", func(c *fiber.Ctx) error {
+		c.Locals("userId", c.Query("googleId"))
+		c.Locals("sessionId", c.Query("sessionId"))
+		c.Locals("tokenExpiresAt", time.Now().Add(time.Hour))
+		return c.Next()
+	}, websocket.New(hub.HandleConnections))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+	return "ws://" + listener.Addr().String() + "/ws"
+}
+
+func dial(t *testing.T, url string, googleId string, sessionId string) *fastws.Conn {
+	t.Helper()
+	conn, _, err := fastws.DefaultDialer.Dial(fmt.Sprintf("%s?googleId=%s&sessionId=%s", url, googleId, sessionId), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readEvent(t *testing.T, conn *fastws.Conn) Event {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal(message, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+// waitFor polls the hub until check accepts its stats.
+func waitFor(t *testing.T, hub *Hub, check func(Stats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !check(hub.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("hub did not reach the expected state, stats %+v", hub.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHubRegistersDevicesPerUser(t *testing.T) {
+	hub, _ := newTestHub(DefaultConfig())
+	url := serveHub(t, hub)
+
+	phone := dial(t, url, "user-1", "session-1")
+	laptop := dial(t, url, "user-1", "session-2")
+	other := dial(t, url, "user-2", "session-3")
+	waitFor(t, hub, func(stats Stats) bool {
+		return stats.Connections == 3 && stats.ConnectionsPerUser["user-1"] == 2 && stats.ConnectionsPerUser["user-2"] == 1
+	})
+
+	hub.Publish("user-1", NewEvent(EventReportReady, ReportReadyPayload{StartDate: "01-06-2026"}))
+	hub.Publish("user-2", NewEvent(EventReportReady, ReportReadyPayload{StartDate: "08-06-2026"}))
+	for _, conn := range []*fastws.Conn{phone, laptop} {
+		if event := readEvent(t, conn); event.Payload.(map[string]interface{})["startDate"] != "01-06-2026" {
+			t.Errorf("user-1 device got %+v", event)
+		}
+	}
+	if event := readEvent(t, other); event.Payload.(map[string]interface{})["startDate"] != "08-06-2026" {
+		t.Errorf("user-2 got %+v", event)
+	}
+
+	phone.Close()
+	waitFor(t, hub, func(stats Stats) bool {
+		return stats.Connections == 2 && stats.ConnectionsPerUser["user-1"] == 1
+	})
+
+	hub.Publish("user-1", NewEvent(EventRoutineChanged, RoutineChangedPayload{Id: "routine-1", Action: ActionDeleted}))
+	if event := readEvent(t, laptop); event.Type != EventRoutineChanged || event.Seq != 2 {
+		t.Errorf("remaining device got %+v, want routine.changed with seq 2", event)
+	}
+
+	laptop.Close()
+	other.Close()
+	waitFor(t, hub, func(stats Stats) bool {
+		return stats.Connections == 0 && len(stats.ConnectionsPerUser) == 0
+	})
+}
+
+func TestHubDeliversInOrder(t *testing.T) {
+	hub, _ := newTestHub(DefaultConfig())
+	url := serveHub(t, hub)
+
+	conn := dial(t, url, "user-1", "session-1")
+	waitFor(t, hub, func(stats Stats) bool { return stats.Connections == 1 })
+
+	// Stay within the send buffer so the burst cannot evict the reader
+	const count = sendBufferSize - 1
+	for i := 1; i <= count; i++ {
+		hub.Publish("user-1", NewEvent(EventRoutineChanged, RoutineChangedPayload{Id: "routine-1", Action: ActionUpdated, Order: i}))
+	}
+
+	for i := 1; i <= count; i++ {
+		event := readEvent(t, conn)
+		order := event.Payload.(map[string]interface{})["order"]
+		if event.Seq != i || order != float64(i) {
+			t.Fatalf("event %d arrived with seq %d and order %v", i, event.Seq, order)
+		}
+	}
+	if sent := hub.Stats().MessagesSent; sent != count {
+		t.Errorf("sent %d messages, want %d", sent, count)
+	}
+}
+
+func TestHubEvictsSlowConsumer(t *testing.T) {
+	hub, _ := newTestHub(DefaultConfig())
+
+	// Nothing reads the queue, the way a stalled writer would leave it
+	slow := &Client{hub: hub, googleId: "user-1", send: make(chan outboundMessage, sendBufferSize)}
+	fast := &Client{hub: hub, googleId: "user-1", send: make(chan outboundMessage, sendBufferSize*2)}
+	hub.register <- slow
+	hub.register <- fast
+
+	for i := 1; i <= sendBufferSize+1; i++ {
+		hub.deliver("user-1", i, []byte(fmt.Sprintf("message %d", i)))
+	}
+	waitFor(t, hub, func(stats Stats) bool {
+		return stats.MessagesDropped == 1 && stats.ConnectionsPerUser["user-1"] == 1
+	})
+
+	// The slow client keeps what fit, then its queue is closed
+	received := 0
+	for range slow.send {
+		received++
+	}
+	if received != sendBufferSize {
+		t.Errorf("slow client got %d messages before eviction, want %d", received, sendBufferSize)
+	}
+	if len(fast.send) != sendBufferSize+1 {
+		t.Errorf("other device got %d messages, want %d", len(fast.send), sendBufferSize+1)
+	}
+
+	// Evicting twice is harmless
+	hub.unregister <- slow
+	waitFor(t, hub, func(stats Stats) bool { return stats.Connections == 1 })
+}
+
+func TestHubConcurrentPublish(t *testing.T) {
+	hub, _ := newTestHub(DefaultConfig())
+	url := serveHub(t, hub)
+
+	conns := []*fastws.Conn{dial(t, url, "user-1", "session-1"), dial(t, url, "user-1", "session-2")}
+	waitFor(t, hub, func(stats Stats) bool { return stats.Connections == 2 })
+
+	const publishers = 4
+	const perPublisher = sendBufferSize / publishers
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 1; i <= perPublisher; i++ {
+				hub.Publish("user-1", NewEvent(EventRoutineChanged, RoutineChangedPayload{Id: fmt.Sprint(p), Action: ActionUpdated, Order: i}))
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	for _, conn := range conns {
+		seqs := make(map[int]bool)
+		lastOrder := make(map[string]float64)
+		for i := 0; i < publishers*perPublisher; i++ {
+			event := readEvent(t, conn)
+			if seqs[event.Seq] {
+				t.Fatalf("seq %d delivered twice", event.Seq)
+			}
+			seqs[event.Seq] = true
+
+			// Each publisher's events keep the order they were published in
+			payload := event.Payload.(map[string]interface{})
+			publisher, order := payload["id"].(string), payload["order"].(float64)
+			if order <= lastOrder[publisher] {
+				t.Fatalf("publisher %s: order %v arrived after %v", publisher, order, lastOrder[publisher])
+			}
+			lastOrder[publisher] = order
+		}
+		for seq := 1; seq <= publishers*perPublisher; seq++ {
+			if !seqs[seq] {
+				t.Errorf("seq %d never arrived", seq)
+			}
+		}
+	}
+	if stats := hub.Stats(); stats.MessagesDropped != 0 || stats.Connections != 2 {
+		t.Errorf("unexpected stats after concurrent publish: %+v", stats)
+	}
+}
+
+func TestHubClosesRevokedSession(t *testing.T) {
+	config := DefaultConfig()
+	config.PingInterval = 20 * time.Millisecond
+	hub, sessions := newTestHub(config)
+	url := serveHub(t, hub)
+
+	revoked := dial(t, url, "user-1", "session-1")
+	kept := dial(t, url, "user-1", "session-2")
+	waitFor(t, hub, func(stats Stats) bool { return stats.Connections == 2 })
+
+	sessions.RevokeSession("session-1")
+	revoked.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := revoked.ReadMessage()
+	if !fastws.IsCloseError(err, fastws.ClosePolicyViolation) {
+		t.Fatalf("got %v, want a policy violation close", err)
+	}
+	waitFor(t, hub, func(stats Stats) bool { return stats.Connections == 1 })
+
+	hub.Publish("user-1", NewEvent(EventReportReady, ReportReadyPayload{}))
+	if event := readEvent(t, kept); event.Type != EventReportReady {
+		t.Errorf("device of the active session got %+v", event)
+	}
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Stats is a snapshot of the connections held by this instance.
+type Stats struct {
+	Connections        int            `json:"connections"`
+	ConnectionsPerUser map[string]int `json:"connectionsPerUser"`
+	MessagesSent       int64          `json:"messagesSent"`
+	MessagesDropped    int64          `json:"messagesDropped"`
+}
+
+type metrics struct {
+	mu          sync.Mutex
+	connections map[string]int
+	sent        atomic.Int64
+	dropped     atomic.Int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{connections: make(map[string]int)}
+}
+
+func (m *metrics) connected(googleId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections[googleId]++
+}
+
+func (m *metrics) disconnected(googleId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections[googleId]--
+	if m.connections[googleId] <= 0 {
+		delete(m.connections, googleId)
+	}
+}
+
+// Stats reports the live connections and message counters of this instance.
+// A message is counted as dropped when it does not fit in a connection's
+// queue, which evicts that connection, or a command reply is discarded.
+func (h *Hub) Stats() Stats {
+	h.metrics.mu.Lock()
+	defer h.metrics.mu.Unlock()
+
+	stats := Stats{
+		ConnectionsPerUser: make(map[string]int, len(h.metrics.connections)),
+		MessagesSent:       h.metrics.sent.Load(),
+		MessagesDropped:    h.metrics.dropped.Load(),
+	}
+	for googleId, count := range h.metrics.connections {
+		stats.ConnectionsPerUser[googleId] = count
+		stats.Connections += count
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	// How long published events stay available for replay
+	eventRetention = 24 * time.Hour
+
+	// Larger gaps are cheaper to close with a resync than a replay
+	maxReplayEvents = 200
+)
+
+// replay writes every logged event after lastSeq straight to the socket. When
+// the log cannot close the gap, because events expired or lastSeq is not one
+// the server handed out, the client is told to resync instead.
+func (c *Client) replay(lastSeq string) {
+	seq, err := strconv.Atoi(lastSeq)
+	if err != nil || seq < 0 {
+		c.resync(0)
+		return
+	}
+
+	current, err := c.hub.eventLog.GetCurrentSequence(c.googleId)
+	if err != nil {
+		log.Printf("Failed to get event sequence for %s: %v", c.googleId, err)
+		c.resync(seq)
+		return
+	}
+	if seq == current {
+		c.replayedSeq = current
+		return
+	}
+	if seq > current {
+		c.resync(seq)
+		return
+	}
+
+	events, err := c.hub.eventLog.GetEventsAfter(c.googleId, seq, maxReplayEvents+1)
+	if err != nil {
+		log.Printf("Failed to get missed events for %s: %v", c.googleId, err)
+		c.resync(seq)
+		return
+	}
+	if len(events) == 0 || len(events) > maxReplayEvents {
+		c.resync(seq)
+		return
+	}
+	for i, event := range events {
+		if event.Seq != seq+i+1 {
+			c.resync(seq)
+			return
+		}
+	}
+
+	for _, event := range events {
+		if err := c.write([]byte(event.Message)); err != nil {
+			log.Printf("error: %v", err)
+			return
+		}
+		c.replayedSeq = event.Seq
+	}
+	log.Printf("Replayed %d events to %s", len(events), c.googleId)
+}
+
+// resync tells the client its state is stale. The event carries the current
+// sequence so the client can resume from it once it has refetched.
+func (c *Client) resync(lastSeq int) {
+	current, err := c.hub.eventLog.GetCurrentSequence(c.googleId)
+	if err != nil {
+		log.Printf("Failed to get event sequence for %s: %v", c.googleId, err)
+	}
+
+	event := NewEvent(EventResyncRequired, ResyncRequiredPayload{LastSeq: lastSeq})
+	event.Seq = current
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to marshal %s event: %v", event.Type, err)
+		return
+	}
+	if err := c.write(message); err != nil {
+		log.Printf("error: %v", err)
+		return
+	}
+	c.replayedSeq = current
+}